@@ -0,0 +1,89 @@
+package feature
+
+import (
+	"fmt"
+
+	"github.com/lightningnetwork/lnd/lnwire"
+)
+
+// Capability is a high-level feature a node may support, named as its feature
+// bit pair is within lnwire.Features, e.g. "anchor-commitments".
+type Capability string
+
+// ErrUnknownCapability is an error signaling that a capability doesn't match
+// the name of any known feature bit.
+type ErrUnknownCapability struct {
+	capability Capability
+}
+
+// Error returns a human-readable description of the unknown capability error.
+func (e ErrUnknownCapability) Error() string {
+	return fmt.Sprintf("unknown capability: %v", e.capability)
+}
+
+// BuildFeatureVector returns the minimal feature vector that signals all of
+// the given capabilities, along with their transitive dependencies. If
+// required is true, the required variant of each feature is set, otherwise
+// its optional variant. This allows a list of capabilities, e.g. taken from
+// the config, to be turned into a valid feature vector for the Init message.
+//
+// NOTE: Features such as initial-routing-sync only have an optional variant,
+// so they can't be requested as required.
+func BuildFeatureVector(caps []Capability,
+	required bool) (*lnwire.RawFeatureVector, error) {
+
+	// We'll first map each capability to the optional variant of its
+	// feature bit, as that's how the dependency graph is described.
+	byName := make(map[Capability]lnwire.FeatureBit, len(lnwire.Features))
+	for bit, name := range lnwire.Features {
+		byName[Capability(name)] = mapToOptional(bit)
+	}
+
+	features := make(featureSet, len(caps))
+	for _, capability := range caps {
+		bit, ok := byName[capability]
+		if !ok {
+			return nil, ErrUnknownCapability{capability}
+		}
+		addWithDeps(features, bit)
+	}
+
+	raw := lnwire.NewRawFeatureVector()
+	for bit := range features {
+		if required {
+			name := lnwire.Features[bit]
+			bit = mapToRequired(bit)
+			if _, ok := lnwire.Features[bit]; !ok {
+				return nil, fmt.Errorf("capability %v can't "+
+					"be required", name)
+			}
+		}
+
+		if err := raw.SafeSet(bit); err != nil {
+			return nil, err
+		}
+	}
+
+	return raw, nil
+}
+
+// addWithDeps adds the optional feature bit, along with all of its transitive
+// dependencies, to the feature set.
+func addWithDeps(features featureSet, bit lnwire.FeatureBit) {
+	if _, ok := features[bit]; ok {
+		return
+	}
+	features[bit] = struct{}{}
+
+	for dep := range deps[bit] {
+		addWithDeps(features, dep)
+	}
+}
+
+// mapToRequired returns the required variant of a given feature bit pair.
+func mapToRequired(bit lnwire.FeatureBit) lnwire.FeatureBit {
+	if !bit.IsRequired() {
+		bit ^= 0x01
+	}
+	return bit
+}
@@ -0,0 +1,57 @@
+package feature
+
+import (
+	"testing"
+
+	"github.com/lightningnetwork/lnd/lnwire"
+	"github.com/stretchr/testify/require"
+)
+
+// TestBuildFeatureVector asserts that a list of capabilities is translated into
+// the minimal feature vector setting them along with their transitive
+// dependencies, using the requested variant of each feature bit.
+func TestBuildFeatureVector(t *testing.T) {
+	t.Parallel()
+
+	caps := []Capability{"anchor-commitments", "multi-path-payments"}
+
+	raw, err := BuildFeatureVector(caps, false)
+	require.NoError(t, err)
+	require.Equal(t, lnwire.NewRawFeatureVector(
+		lnwire.AnchorsOptional,
+		lnwire.StaticRemoteKeyOptional,
+		lnwire.MPPOptional,
+		lnwire.PaymentAddrOptional,
+		lnwire.TLVOnionPayloadOptional,
+	), raw)
+
+	raw, err = BuildFeatureVector(caps, true)
+	require.NoError(t, err)
+	require.Equal(t, lnwire.NewRawFeatureVector(
+		lnwire.AnchorsRequired,
+		lnwire.StaticRemoteKeyRequired,
+		lnwire.MPPRequired,
+		lnwire.PaymentAddrRequired,
+		lnwire.TLVOnionPayloadRequired,
+	), raw)
+
+	// The resulting vector must satisfy the dependency graph it was
+	// built from.
+	fv := lnwire.NewFeatureVector(raw, lnwire.Features)
+	require.NoError(t, ValidateDeps(fv))
+
+	// Features without a required variant can only be requested as
+	// optional.
+	caps = []Capability{"initial-routing-sync"}
+	raw, err = BuildFeatureVector(caps, false)
+	require.NoError(t, err)
+	require.Equal(t, lnwire.NewRawFeatureVector(
+		lnwire.InitialRoutingSync,
+	), raw)
+
+	_, err = BuildFeatureVector(caps, true)
+	require.Error(t, err)
+
+	_, err = BuildFeatureVector([]Capability{"route-blinding"}, false)
+	require.Equal(t, ErrUnknownCapability{"route-blinding"}, err)
+}
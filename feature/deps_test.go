@@ -142,6 +142,20 @@ var depTests = []depTest{
 		),
 		expErr: ErrMissingFeatureDep{lnwire.PaymentAddrOptional},
 	},
+	{
+		name: "anchors with static remote key",
+		raw: lnwire.NewRawFeatureVector(
+			lnwire.StaticRemoteKeyRequired,
+			lnwire.AnchorsZeroFeeHtlcTxOptional,
+		),
+	},
+	{
+		name: "anchors missing static remote key",
+		raw: lnwire.NewRawFeatureVector(
+			lnwire.AnchorsZeroFeeHtlcTxRequired,
+		),
+		expErr: ErrMissingFeatureDep{lnwire.StaticRemoteKeyOptional},
+	},
 }
 
 // TestValidateDeps tests that ValidateDeps correctly asserts whether or not the
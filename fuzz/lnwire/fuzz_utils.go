@@ -38,7 +38,7 @@ func harness(data []byte, emptyMsg lnwire.Message) int {
 		return 1
 	}
 
-	msg, err := lnwire.ReadMessage(r, 0)
+	msg, err := lnwire.ReadMessage(r, lnwire.ProtocolVersionLatest)
 	if err != nil {
 		// go-fuzz generated []byte that cannot be represented as a
 		// wire message but we will return 0 so go-fuzz can modify the
@@ -48,7 +48,8 @@ func harness(data []byte, emptyMsg lnwire.Message) int {
 
 	// We will serialize the message into a new bytes buffer.
 	var b bytes.Buffer
-	if _, err := lnwire.WriteMessage(&b, msg, 0); err != nil {
+	_, err = lnwire.WriteMessage(&b, msg, lnwire.ProtocolVersionLatest)
+	if err != nil {
 		// Could not serialize message into bytes buffer, panic
 		panic(err)
 	}
@@ -56,7 +57,7 @@ func harness(data []byte, emptyMsg lnwire.Message) int {
 	// Deserialize the message from the serialized bytes buffer, and then
 	// assert that the original message is equal to the newly deserialized
 	// message.
-	newMsg, err := lnwire.ReadMessage(&b, 0)
+	newMsg, err := lnwire.ReadMessage(&b, lnwire.ProtocolVersionLatest)
 	if err != nil {
 		// Could not deserialize message from bytes buffer, panic
 		panic(err)
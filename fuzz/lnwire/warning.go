@@ -0,0 +1,20 @@
+// +build gofuzz
+
+package lnwirefuzz
+
+import (
+	"github.com/lightningnetwork/lnd/lnwire"
+)
+
+// Fuzz_warning is used by go-fuzz.
+func Fuzz_warning(data []byte) int {
+	// Prefix with MsgWarning.
+	data = prefixWithMsgType(data, lnwire.MsgWarning)
+
+	// Create an empty message so that the FuzzHarness func can check
+	// if the max payload constraint is violated.
+	emptyMsg := lnwire.Warning{}
+
+	// Pass the message into our general fuzz harness for wire messages!
+	return harness(data, &emptyMsg)
+}
@@ -0,0 +1,36 @@
+// +build gofuzz
+
+package torfuzz
+
+import (
+	"strings"
+
+	"github.com/lightningnetwork/lnd/tor"
+)
+
+// Fuzz_parse_tor_reply is used by go-fuzz.
+func Fuzz_parse_tor_reply(data []byte) int {
+	reply := string(data)
+
+	// The parser should never panic, regardless of how the reply is
+	// quoted or escaped.
+	params := tor.ParseTorReply(reply)
+	_, _ = tor.ProtocolInfo(reply)
+
+	// Every parsed key must have been found within the reply, and keys
+	// can never contain the separator between keys and values.
+	for key := range params {
+		if strings.Contains(key, "=") {
+			panic("parsed key contains separator: " + key)
+		}
+		if !strings.Contains(reply, key+"=") {
+			panic("parsed key not found in reply: " + key)
+		}
+	}
+
+	if len(params) == 0 {
+		return 0
+	}
+
+	return 1
+}
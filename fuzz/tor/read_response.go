@@ -0,0 +1,32 @@
+// +build gofuzz
+
+package torfuzz
+
+import (
+	"bufio"
+	"bytes"
+	"net/textproto"
+
+	"github.com/lightningnetwork/lnd/tor"
+)
+
+// Fuzz_read_response is used by go-fuzz.
+func Fuzz_read_response(data []byte) int {
+	r := textproto.NewReader(bufio.NewReader(bytes.NewReader(data)))
+
+	// Reading a reply should never panic, and whatever was read should be
+	// safe to parse.
+	code, reply, err := tor.ReadResponse(r)
+	_ = tor.ParseTorReply(reply)
+
+	// A successful read must always carry the expected code.
+	if err == nil && code != 250 {
+		panic("unexpected code for successful reply")
+	}
+
+	if err != nil {
+		return 0
+	}
+
+	return 1
+}
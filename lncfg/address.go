@@ -161,6 +161,32 @@ func IsPrivate(addr net.Addr) bool {
 	}
 }
 
+// FilterUnusableAddrs returns the subset of the given addresses a remote node
+// could actually connect to, e.g. when advertised within a NodeAnnouncement.
+// Loopback and unspecified addresses are always dropped, while private ones
+// are only kept if keepPrivate is set, as is useful on regtest where nodes
+// commonly run on a private network. Addresses other than TCP ones, such as
+// onion addresses, are kept as is.
+func FilterUnusableAddrs(addrs []net.Addr, keepPrivate bool) []net.Addr {
+	usable := make([]net.Addr, 0, len(addrs))
+	for _, addr := range addrs {
+		if tcpAddr, ok := addr.(*net.TCPAddr); ok {
+			ip := tcpAddr.IP
+			if len(ip) == 0 || ip.IsLoopback() || ip.IsUnspecified() {
+				continue
+			}
+
+			if !keepPrivate && IsPrivate(tcpAddr) {
+				continue
+			}
+		}
+
+		usable = append(usable, addr)
+	}
+
+	return usable
+}
+
 // ParseAddressString converts an address in string format to a net.Addr that is
 // compatible with lnd. UDP is not supported because lnd needs reliable
 // connections. We accept a custom function to resolve any TCP addresses so
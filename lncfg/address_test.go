@@ -9,6 +9,7 @@ import (
 	"testing"
 
 	"github.com/btcsuite/btcd/btcec"
+	"github.com/lightningnetwork/lnd/tor"
 	"github.com/stretchr/testify/require"
 )
 
@@ -320,3 +321,33 @@ func TestIsPrivate(t *testing.T) {
 		})
 	}
 }
+
+// TestFilterUnusableAddrs asserts that loopback, unspecified and, unless
+// requested otherwise, private addresses are filtered out.
+func TestFilterUnusableAddrs(t *testing.T) {
+	public := &net.TCPAddr{IP: net.IPv4(8, 8, 8, 8), Port: 9735}
+	private := &net.TCPAddr{IP: net.IPv4(192, 168, 0, 1), Port: 9735}
+	onion := &tor.OnionAddr{
+		OnionService: "3g2upl4pq6kufc4m.onion",
+		Port:         9735,
+	}
+
+	addrs := []net.Addr{
+		public,
+		&net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 9735},
+		&net.TCPAddr{IP: net.IPv6loopback, Port: 9735},
+		&net.TCPAddr{IP: net.IPv4zero, Port: 9735},
+		&net.TCPAddr{IP: net.IPv6unspecified, Port: 9735},
+		&net.TCPAddr{Port: 9735},
+		private,
+		onion,
+	}
+
+	require.Equal(
+		t, []net.Addr{public, onion}, FilterUnusableAddrs(addrs, false),
+	)
+	require.Equal(
+		t, []net.Addr{public, private, onion},
+		FilterUnusableAddrs(addrs, true),
+	)
+}
@@ -484,6 +484,14 @@ func (c *ChanCloser) ProcessCloseMsg(msg lnwire.Message) ([]lnwire.Message,
 				"instead have %v", spew.Sdump(msg))
 		}
 
+		// Before going any further, we'll ensure the proposed fee is
+		// sane. The fee is paid out of the funding output, so it can
+		// never exceed the capacity of the channel.
+		err := closeSignedMsg.ValidateFee(0, c.cfg.Channel.Capacity)
+		if err != nil {
+			return nil, false, err
+		}
+
 		// We'll compare the proposed total fee, to what we've proposed during
 		// the negotiations. If it doesn't match any of our prior offers, then
 		// we'll attempt to ratchet the fee closer to
@@ -1,8 +1,12 @@
 package lnwire
 
 import (
+	"errors"
+	"fmt"
 	"io"
 	"io/ioutil"
+
+	"github.com/btcsuite/btcd/btcec"
 )
 
 // AnnounceSignatures is a direct message between two endpoints of a
@@ -40,13 +44,21 @@ type AnnounceSignatures struct {
 	// properly validate the set of signatures that cover these new fields,
 	// and ensure we're able to make upgrades to the network in a forwards
 	// compatible manner.
-	ExtraOpaqueData []byte
+	ExtraOpaqueData ExtraOpaqueData
 }
 
 // A compile time check to ensure AnnounceSignatures implements the
 // lnwire.Message interface.
 var _ Message = (*AnnounceSignatures)(nil)
 
+// A compile time check to ensure AnnounceSignatures implements the
+// lnwire.OptionalRecordsMessage interface.
+var _ OptionalRecordsMessage = (*AnnounceSignatures)(nil)
+
+// A compile time check to ensure AnnounceSignatures implements the
+// lnwire.UnknownRecordsMessage interface.
+var _ UnknownRecordsMessage = (*AnnounceSignatures)(nil)
+
 // Decode deserializes a serialized AnnounceSignatures stored in the passed
 // io.Reader observing the specified protocol version.
 //
@@ -106,3 +118,86 @@ func (a *AnnounceSignatures) MsgType() MessageType {
 func (a *AnnounceSignatures) MaxPayloadLength(pver uint32) uint32 {
 	return 65533
 }
+
+// ParsedOptionalRecords returns the sorted set of TLV types carried within the
+// ExtraOpaqueData of the message.
+//
+// This is part of the lnwire.OptionalRecordsMessage interface.
+func (a *AnnounceSignatures) ParsedOptionalRecords() []uint64 {
+	return a.ExtraOpaqueData.parsedOptionalRecords()
+}
+
+// UnknownRecords returns the TLV records carried within the ExtraOpaqueData of
+// the message, none of which we know of.
+//
+// This is part of the lnwire.UnknownRecordsMessage interface.
+func (a *AnnounceSignatures) UnknownRecords() []UnknownRecord {
+	return a.ExtraOpaqueData.unknownRecords()
+}
+
+// VerifyForAnnouncement checks that the signatures carried by the
+// AnnounceSignatures are valid signatures over the passed unsigned
+// ChannelAnnouncement by the node identified by nodeID, and the bitcoin key
+// that node contributed to the funding output. This should be used before
+// stitching the signatures into the ChannelAnnouncement, so a peer sending
+// bogus signatures is caught early rather than producing an invalid
+// announcement.
+func (a *AnnounceSignatures) VerifyForAnnouncement(ann *ChannelAnnouncement,
+	nodeID [33]byte) error {
+
+	if a.ShortChannelID != ann.ShortChannelID {
+		return fmt.Errorf("short channel id mismatch: announce "+
+			"signatures for %v, channel announcement for %v",
+			a.ShortChannelID, ann.ShortChannelID)
+	}
+
+	// Depending on which end of the channel the announcing node is, we'll
+	// need to verify its bitcoin signature under a different key.
+	var bitcoinKey [33]byte
+	switch nodeID {
+	case ann.NodeID1:
+		bitcoinKey = ann.BitcoinKey1
+	case ann.NodeID2:
+		bitcoinKey = ann.BitcoinKey2
+	default:
+		return errors.New("node is not part of the channel " +
+			"announcement")
+	}
+
+	// Both signatures commit to the same digest of the announcement, so
+	// we'll compute it once.
+	dataHash, err := ann.DigestToSign()
+	if err != nil {
+		return err
+	}
+
+	if err := verifySig(a.NodeSignature, nodeID, dataHash); err != nil {
+		return fmt.Errorf("invalid node signature: %v", err)
+	}
+	err = verifySig(a.BitcoinSignature, bitcoinKey, dataHash)
+	if err != nil {
+		return fmt.Errorf("invalid bitcoin signature: %v", err)
+	}
+
+	return nil
+}
+
+// verifySig checks that sig is a valid signature over hash under the given
+// serialized public key.
+func verifySig(sig Sig, pubKey [33]byte, hash []byte) error {
+	signature, err := sig.ToSignature()
+	if err != nil {
+		return err
+	}
+
+	key, err := btcec.ParsePubKey(pubKey[:], btcec.S256())
+	if err != nil {
+		return err
+	}
+
+	if !signature.Verify(hash, key) {
+		return errors.New("signature doesn't cover digest")
+	}
+
+	return nil
+}
@@ -0,0 +1,76 @@
+package lnwire
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/stretchr/testify/require"
+)
+
+// signAnnouncement signs the digest of the channel announcement with the
+// given private key and returns the wire signature.
+func signAnnouncement(t *testing.T, priv *btcec.PrivateKey,
+	ann *ChannelAnnouncement) Sig {
+
+	data, err := ann.DataToSign()
+	require.NoError(t, err)
+
+	sig, err := priv.Sign(chainhash.DoubleHashB(data))
+	require.NoError(t, err)
+
+	wireSig, err := NewSigFromSignature(sig)
+	require.NoError(t, err)
+
+	return wireSig
+}
+
+// TestAnnounceSignaturesVerifyForAnnouncement asserts that the signatures of
+// an AnnounceSignatures message are properly checked against the channel
+// announcement they're meant for.
+func TestAnnounceSignaturesVerifyForAnnouncement(t *testing.T) {
+	t.Parallel()
+
+	newKey := func() *btcec.PrivateKey {
+		priv, err := btcec.NewPrivateKey(btcec.S256())
+		require.NoError(t, err)
+		return priv
+	}
+	nodeKey1, nodeKey2 := newKey(), newKey()
+	bitcoinKey1, bitcoinKey2 := newKey(), newKey()
+
+	ann := &ChannelAnnouncement{
+		Features:       NewRawFeatureVector(),
+		ShortChannelID: NewShortChanIDFromInt(1234),
+	}
+	copy(ann.NodeID1[:], nodeKey1.PubKey().SerializeCompressed())
+	copy(ann.NodeID2[:], nodeKey2.PubKey().SerializeCompressed())
+	copy(ann.BitcoinKey1[:], bitcoinKey1.PubKey().SerializeCompressed())
+	copy(ann.BitcoinKey2[:], bitcoinKey2.PubKey().SerializeCompressed())
+
+	annSigs := &AnnounceSignatures{
+		ShortChannelID:   ann.ShortChannelID,
+		NodeSignature:    signAnnouncement(t, nodeKey2, ann),
+		BitcoinSignature: signAnnouncement(t, bitcoinKey2, ann),
+	}
+
+	// The signatures were made by the second node, so they should only
+	// verify for it.
+	require.NoError(t, annSigs.VerifyForAnnouncement(ann, ann.NodeID2))
+	require.Error(t, annSigs.VerifyForAnnouncement(ann, ann.NodeID1))
+
+	// A node that isn't part of the channel should be rejected outright.
+	var unknownNode [33]byte
+	copy(unknownNode[:], newKey().PubKey().SerializeCompressed())
+	require.Error(t, annSigs.VerifyForAnnouncement(ann, unknownNode))
+
+	// Signatures for a different channel should be rejected.
+	otherChan := *annSigs
+	otherChan.ShortChannelID = NewShortChanIDFromInt(5678)
+	require.Error(t, otherChan.VerifyForAnnouncement(ann, ann.NodeID2))
+
+	// Finally, a bitcoin signature made by the wrong key should be caught.
+	badBitcoinSig := *annSigs
+	badBitcoinSig.BitcoinSignature = signAnnouncement(t, bitcoinKey1, ann)
+	require.Error(t, badBitcoinSig.VerifyForAnnouncement(ann, ann.NodeID2))
+}
@@ -0,0 +1,139 @@
+package lnwire
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+)
+
+// SigCheck is a single signature to be verified as part of a batch, along with
+// the key that must have produced it and the digest it must commit to.
+type SigCheck struct {
+	// PubKey is the serialized public key the signature must be valid
+	// under.
+	PubKey [33]byte
+
+	// Digest is the digest the signature must commit to.
+	Digest []byte
+
+	// Sig is the signature to verify.
+	Sig Sig
+}
+
+// ErrBatchVerify is returned by BatchVerify when one of the signatures of the
+// batch is invalid.
+type ErrBatchVerify struct {
+	index int
+	err   error
+}
+
+// Error returns a human readable string describing the error.
+//
+// NOTE: implements the error interface.
+func (e ErrBatchVerify) Error() string {
+	return fmt.Sprintf("invalid signature at index %d: %v", e.index, e.err)
+}
+
+// Index returns the index within the batch of the invalid signature.
+func (e ErrBatchVerify) Index() int {
+	return e.index
+}
+
+// SigChecks returns the checks for the four signatures of the announcement.
+func (a *ChannelAnnouncement) SigChecks() ([]SigCheck, error) {
+	digest, err := a.DigestToSign()
+	if err != nil {
+		return nil, err
+	}
+
+	return []SigCheck{
+		{PubKey: a.NodeID1, Digest: digest, Sig: a.NodeSig1},
+		{PubKey: a.NodeID2, Digest: digest, Sig: a.NodeSig2},
+		{PubKey: a.BitcoinKey1, Digest: digest, Sig: a.BitcoinSig1},
+		{PubKey: a.BitcoinKey2, Digest: digest, Sig: a.BitcoinSig2},
+	}, nil
+}
+
+// SigCheck returns the check for the signature of the announcement, which
+// must have been produced by the announced node.
+func (a *NodeAnnouncement) SigCheck() (SigCheck, error) {
+	digest, err := a.DigestToSign()
+	if err != nil {
+		return SigCheck{}, err
+	}
+
+	return SigCheck{
+		PubKey: a.NodeID,
+		Digest: digest,
+		Sig:    a.Signature,
+	}, nil
+}
+
+// SigCheck returns the check for the signature of the update. As the update
+// doesn't carry the key of the node that produced it, it must be passed in,
+// as found within the ChannelAnnouncement of the channel.
+func (a *ChannelUpdate) SigCheck(nodeKey [33]byte) (SigCheck, error) {
+	digest, err := a.DigestToSign()
+	if err != nil {
+		return SigCheck{}, err
+	}
+
+	return SigCheck{
+		PubKey: nodeKey,
+		Digest: digest,
+		Sig:    a.Signature,
+	}, nil
+}
+
+// BatchVerify verifies all signatures of the batch, returning ErrBatchVerify
+// for the first invalid one. The btcec version we rely on doesn't support
+// batch verification of ECDSA signatures, so the signatures are instead
+// verified individually, spread across all available CPUs.
+func BatchVerify(checks []SigCheck) error {
+	numWorkers := runtime.NumCPU()
+	if numWorkers > len(checks) {
+		numWorkers = len(checks)
+	}
+
+	// Each worker verifies every numWorkers-th signature, and reports the
+	// lowest index it found to be invalid, if any.
+	var (
+		wg       sync.WaitGroup
+		mtx      sync.Mutex
+		firstErr *ErrBatchVerify
+	)
+	for w := 0; w < numWorkers; w++ {
+		wg.Add(1)
+		go func(start int) {
+			defer wg.Done()
+
+			for i := start; i < len(checks); i += numWorkers {
+				check := checks[i]
+				err := verifySig(
+					check.Sig, check.PubKey, check.Digest,
+				)
+				if err == nil {
+					continue
+				}
+
+				mtx.Lock()
+				if firstErr == nil || i < firstErr.index {
+					firstErr = &ErrBatchVerify{
+						index: i,
+						err:   err,
+					}
+				}
+				mtx.Unlock()
+
+				return
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return *firstErr
+	}
+
+	return nil
+}
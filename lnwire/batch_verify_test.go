@@ -0,0 +1,96 @@
+package lnwire
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/stretchr/testify/require"
+)
+
+// TestBatchVerify asserts that a batch of gossip signatures is only accepted if
+// all of them are valid, and that the first invalid one is reported
+// otherwise.
+func TestBatchVerify(t *testing.T) {
+	t.Parallel()
+
+	var keys [4]*btcec.PrivateKey
+	for i := range keys {
+		key, err := btcec.NewPrivateKey(btcec.S256())
+		require.NoError(t, err)
+		keys[i] = key
+	}
+
+	var pubKeys [4][33]byte
+	for i, key := range keys {
+		copy(pubKeys[i][:], key.PubKey().SerializeCompressed())
+	}
+
+	sign := func(key *btcec.PrivateKey, data []byte) Sig {
+		sig, err := key.Sign(chainhash.DoubleHashB(data))
+		require.NoError(t, err)
+		wireSig, err := NewSigFromSignature(sig)
+		require.NoError(t, err)
+		return wireSig
+	}
+
+	ann := &ChannelAnnouncement{
+		Features:       NewRawFeatureVector(),
+		ShortChannelID: NewShortChanIDFromInt(1),
+		NodeID1:        pubKeys[0],
+		NodeID2:        pubKeys[1],
+		BitcoinKey1:    pubKeys[2],
+		BitcoinKey2:    pubKeys[3],
+	}
+	data, err := ann.DataToSign()
+	require.NoError(t, err)
+	ann.NodeSig1 = sign(keys[0], data)
+	ann.NodeSig2 = sign(keys[1], data)
+	ann.BitcoinSig1 = sign(keys[2], data)
+	ann.BitcoinSig2 = sign(keys[3], data)
+
+	nodeAnn := &NodeAnnouncement{
+		Features: NewRawFeatureVector(),
+		NodeID:   pubKeys[0],
+	}
+	data, err = nodeAnn.DataToSign()
+	require.NoError(t, err)
+	nodeAnn.Signature = sign(keys[0], data)
+
+	var updates []*ChannelUpdate
+	for i := 0; i < 20; i++ {
+		update := &ChannelUpdate{
+			ShortChannelID: ann.ShortChannelID,
+			Timestamp:      uint32(i),
+		}
+		data, err := update.DataToSign()
+		require.NoError(t, err)
+		update.Signature = sign(keys[1], data)
+		updates = append(updates, update)
+	}
+
+	checks, err := ann.SigChecks()
+	require.NoError(t, err)
+
+	check, err := nodeAnn.SigCheck()
+	require.NoError(t, err)
+	checks = append(checks, check)
+
+	for _, update := range updates {
+		check, err := update.SigCheck(pubKeys[1])
+		require.NoError(t, err)
+		checks = append(checks, check)
+	}
+
+	require.NoError(t, BatchVerify(checks))
+	require.NoError(t, BatchVerify(nil))
+
+	// Verifying the updates under the wrong key, or swapping in the
+	// signature of another update, should be caught, and the first
+	// invalid signature reported.
+	checks[10].PubKey = pubKeys[0]
+	checks[20].Sig = checks[21].Sig
+	err = BatchVerify(checks)
+	require.IsType(t, ErrBatchVerify{}, err)
+	require.Equal(t, 10, err.(ErrBatchVerify).Index())
+}
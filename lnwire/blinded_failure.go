@@ -0,0 +1,111 @@
+package lnwire
+
+import "fmt"
+
+// BlindedHopPosition is the position of our node within a blinded route that
+// an HTLC was received over, which determines how it's allowed to fail the
+// HTLC without revealing where within the route the failure occurred.
+type BlindedHopPosition uint8
+
+const (
+	// BlindedHopIntroduction is the position of the introduction node of
+	// a blinded route, which received the HTLC from a node outside of the
+	// route. It fails the HTLC with an UpdateFailHTLC, the reason of which
+	// is the invalid_onion_blinding failure encrypted for the sender.
+	BlindedHopIntroduction BlindedHopPosition = iota
+
+	// BlindedHopInner is the position of any node past the introduction
+	// node of a blinded route, including its recipient. Such a node must
+	// not provide an encrypted failure at all, and instead fails the HTLC
+	// with an UpdateFailMalformedHTLC carrying invalid_onion_blinding.
+	BlindedHopInner
+)
+
+// String returns a human readable string describing the position.
+func (p BlindedHopPosition) String() string {
+	switch p {
+	case BlindedHopIntroduction:
+		return "introduction"
+	case BlindedHopInner:
+		return "inner"
+	default:
+		return fmt.Sprintf("unknown(%d)", uint8(p))
+	}
+}
+
+// ErrInvalidBlindedFailure is returned when a failure for an HTLC received over
+// a blinded route isn't allowed for our position within the route, as it could
+// reveal where the failure occurred.
+type ErrInvalidBlindedFailure struct {
+	position BlindedHopPosition
+	msg      Message
+}
+
+// Error returns a human readable string describing the error.
+//
+// NOTE: implements the error interface.
+func (e ErrInvalidBlindedFailure) Error() string {
+	desc := e.msg.MsgType().String()
+	if malformed, ok := e.msg.(*UpdateFailMalformedHTLC); ok {
+		desc = fmt.Sprintf("%v with code %v", desc,
+			malformed.FailureCode)
+	}
+
+	return fmt.Sprintf("%v not allowed as failure of %v node of "+
+		"blinded route", desc, e.position)
+}
+
+// NewBlindedFailure creates the message failing an HTLC received over a
+// blinded route, in place of whatever the actual failure was. The introduction
+// node fails the HTLC with an UpdateFailHTLC carrying reason, which must be the
+// encrypted NewInvalidBlinding failure for the onion. Any other node fails it
+// with an UpdateFailMalformedHTLC carrying the hash of the onion, in which case
+// reason is ignored.
+func NewBlindedFailure(pos BlindedHopPosition, chanID ChannelID, id uint64,
+	onionBlob []byte, reason OpaqueReason) Message {
+
+	if pos == BlindedHopIntroduction {
+		return &UpdateFailHTLC{
+			ChanID: chanID,
+			ID:     id,
+			Reason: reason,
+		}
+	}
+
+	return NewBlindedFailMalformedHTLC(chanID, id, onionBlob)
+}
+
+// ValidateBlindedFailure ensures that msg is an allowed failure for an HTLC
+// received over a blinded route at the given position. The introduction node
+// may return an encrypted failure like any other node, or report its own onion
+// as malformed. Any other node may only return an UpdateFailMalformedHTLC
+// carrying invalid_onion_blinding, as an encrypted failure would reveal which
+// node of the route failed.
+func ValidateBlindedFailure(pos BlindedHopPosition, msg Message) error {
+	invalidErr := ErrInvalidBlindedFailure{position: pos, msg: msg}
+
+	switch m := msg.(type) {
+	case *UpdateFailHTLC:
+		if pos != BlindedHopIntroduction {
+			return invalidErr
+		}
+
+		return nil
+
+	case *UpdateFailMalformedHTLC:
+		if err := m.ValidateFailureCode(); err != nil {
+			return err
+		}
+
+		if pos != BlindedHopIntroduction &&
+			m.FailureCode != CodeInvalidBlinding {
+
+			return invalidErr
+		}
+
+		return nil
+
+	default:
+		return invalidErr
+	}
+}
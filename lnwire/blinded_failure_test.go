@@ -0,0 +1,99 @@
+package lnwire
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestNewBlindedFailure asserts that HTLCs received over a blinded route are
+// failed according to the position of the node within the route.
+func TestNewBlindedFailure(t *testing.T) {
+	t.Parallel()
+
+	onionBlob := bytes.Repeat([]byte{0x01}, OnionPacketSize)
+	reason := OpaqueReason{0x02, 0x03}
+
+	msg := NewBlindedFailure(
+		BlindedHopIntroduction, ChannelID{1}, 2, onionBlob, reason,
+	)
+	require.Equal(t, &UpdateFailHTLC{
+		ChanID: ChannelID{1},
+		ID:     2,
+		Reason: reason,
+	}, msg)
+	require.NoError(t, ValidateBlindedFailure(BlindedHopIntroduction, msg))
+
+	// Inner nodes don't pass on the encrypted reason.
+	msg = NewBlindedFailure(
+		BlindedHopInner, ChannelID{1}, 2, onionBlob, reason,
+	)
+	require.Equal(t, &UpdateFailMalformedHTLC{
+		ChanID:       ChannelID{1},
+		ID:           2,
+		ShaOnionBlob: sha256.Sum256(onionBlob),
+		FailureCode:  CodeInvalidBlinding,
+	}, msg)
+	require.NoError(t, ValidateBlindedFailure(BlindedHopInner, msg))
+}
+
+// TestValidateBlindedFailure asserts that the introduction node of a blinded
+// route may return richer failures than the nodes past it.
+func TestValidateBlindedFailure(t *testing.T) {
+	t.Parallel()
+
+	failHTLC := &UpdateFailHTLC{Reason: OpaqueReason{0x01}}
+	blindingMalformed := &UpdateFailMalformedHTLC{
+		FailureCode: CodeInvalidBlinding,
+	}
+	onionMalformed := &UpdateFailMalformedHTLC{
+		FailureCode: CodeInvalidOnionHmac,
+	}
+	missingBadOnion := &UpdateFailMalformedHTLC{
+		FailureCode: CodeTemporaryChannelFailure,
+	}
+
+	testCases := []struct {
+		name     string
+		msg      Message
+		introErr bool
+		innerErr bool
+	}{
+		{
+			name:     "fail htlc",
+			msg:      failHTLC,
+			innerErr: true,
+		},
+		{
+			name: "invalid blinding malformed",
+			msg:  blindingMalformed,
+		},
+		{
+			name:     "invalid hmac malformed",
+			msg:      onionMalformed,
+			innerErr: true,
+		},
+		{
+			name:     "malformed without bad onion flag",
+			msg:      missingBadOnion,
+			introErr: true,
+			innerErr: true,
+		},
+		{
+			name:     "not a failure",
+			msg:      NewPing(0),
+			introErr: true,
+			innerErr: true,
+		},
+	}
+
+	for _, test := range testCases {
+		err := ValidateBlindedFailure(BlindedHopIntroduction, test.msg)
+		require.Equal(t, test.introErr, err != nil, test.name)
+
+		err = ValidateBlindedFailure(BlindedHopInner, test.msg)
+		require.Equal(t, test.innerErr, err != nil, test.name)
+	}
+}
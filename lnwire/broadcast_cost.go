@@ -0,0 +1,47 @@
+package lnwire
+
+import "io/ioutil"
+
+// BroadcastCost returns the number of bytes sending the given messages to a
+// single peer takes, observing the specified protocol version. This includes
+// the types of the messages but excludes the overhead of the transport. It's
+// returned along with the number of messages of each type. The size of a
+// message that knows the length of its payload is computed without encoding
+// it, while others, such as those carrying compressed short channel ID's, are
+// encoded to learn their actual size.
+func BroadcastCost(msgs []Message, pver uint32) (int, map[MessageType]int,
+	error) {
+
+	var (
+		numBytes   int
+		typeCounts = make(map[MessageType]int)
+	)
+	for _, msg := range msgs {
+		size, err := messageSize(msg, pver)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		numBytes += size
+		typeCounts[msg.MsgType()]++
+	}
+
+	return numBytes, typeCounts, nil
+}
+
+// messageSize returns the number of bytes the message takes once written with
+// the given protocol version, including its type.
+func messageSize(msg Message, pver uint32) (int, error) {
+	if sized, ok := msg.(SizedMessage); ok {
+		if lenp, ok := sized.SerializedBodyLen(pver); ok {
+			return 2 + lenp, nil
+		}
+	}
+
+	cw := &countingWriter{w: ioutil.Discard}
+	if err := msg.Encode(cw, pver); err != nil {
+		return 0, err
+	}
+
+	return 2 + cw.n, nil
+}
@@ -0,0 +1,53 @@
+package lnwire
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestBroadcastCost asserts that the cost of broadcasting a batch of messages
+// matches the number of bytes written for them, including those whose short
+// channel ID's are compressed.
+func TestBroadcastCost(t *testing.T) {
+	t.Parallel()
+
+	scids := make([]ShortChannelID, 100)
+	for i := range scids {
+		scids[i] = NewShortChanIDFromInt(uint64(i))
+	}
+
+	msgs := []Message{
+		&ChannelUpdate{},
+		&ChannelUpdate{ExtraOpaqueData: ExtraOpaqueData{0x01, 0x00}},
+		&NodeAnnouncement{Features: NewRawFeatureVector()},
+		&ReplyChannelRange{
+			EncodingType: EncodingSortedPlain,
+			ShortChanIDs: scids,
+		},
+		&ReplyChannelRange{
+			EncodingType: EncodingSortedZlib,
+			ShortChanIDs: scids,
+		},
+	}
+
+	var b bytes.Buffer
+	for _, msg := range msgs {
+		_, err := WriteMessage(&b, msg, 0)
+		require.NoError(t, err)
+	}
+
+	numBytes, typeCounts, err := BroadcastCost(msgs, 0)
+	require.NoError(t, err)
+	require.Equal(t, b.Len(), numBytes)
+	require.Equal(t, map[MessageType]int{
+		MsgChannelUpdate:     2,
+		MsgNodeAnnouncement:  1,
+		MsgReplyChannelRange: 2,
+	}, typeCounts)
+
+	// A message that can't be encoded can't be accounted for.
+	_, _, err = BroadcastCost([]Message{&NodeAnnouncement{}}, 0)
+	require.Error(t, err)
+}
@@ -0,0 +1,34 @@
+package lnwire
+
+import (
+	"fmt"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// ErrUnsupportedChain is returned when a message references a chain that we
+// don't operate on.
+type ErrUnsupportedChain struct {
+	chainHash chainhash.Hash
+}
+
+// Error returns a human readable string describing the error.
+//
+// NOTE: implements the error interface.
+func (e ErrUnsupportedChain) Error() string {
+	return fmt.Sprintf("unsupported chain hash %v", e.chainHash)
+}
+
+// ValidateChainHash ensures that the chain hash carried by a message, such as
+// OpenChannel, ChannelAnnouncement, ChannelUpdate or QueryChannelRange, is one
+// of the chains we support. Messages for any other chain must be rejected, to
+// avoid confusing state across chains.
+func ValidateChainHash(h chainhash.Hash, supported []chainhash.Hash) error {
+	for _, supportedHash := range supported {
+		if h == supportedHash {
+			return nil
+		}
+	}
+
+	return ErrUnsupportedChain{chainHash: h}
+}
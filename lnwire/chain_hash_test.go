@@ -0,0 +1,29 @@
+package lnwire
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/stretchr/testify/require"
+)
+
+// TestValidateChainHash asserts that only the supported chain hashes are
+// accepted.
+func TestValidateChainHash(t *testing.T) {
+	t.Parallel()
+
+	mainnet := *chaincfg.MainNetParams.GenesisHash
+	testnet := *chaincfg.TestNet3Params.GenesisHash
+	regtest := *chaincfg.RegressionNetParams.GenesisHash
+
+	supported := []chainhash.Hash{mainnet, testnet}
+
+	require.NoError(t, ValidateChainHash(mainnet, supported))
+	require.NoError(t, ValidateChainHash(testnet, supported))
+	require.Equal(
+		t, ErrUnsupportedChain{chainHash: regtest},
+		ValidateChainHash(regtest, supported),
+	)
+	require.Error(t, ValidateChainHash(mainnet, nil))
+}
@@ -56,13 +56,21 @@ type ChannelAnnouncement struct {
 	// properly validate the set of signatures that cover these new fields,
 	// and ensure we're able to make upgrades to the network in a forwards
 	// compatible manner.
-	ExtraOpaqueData []byte
+	ExtraOpaqueData ExtraOpaqueData
 }
 
 // A compile time check to ensure ChannelAnnouncement implements the
 // lnwire.Message interface.
 var _ Message = (*ChannelAnnouncement)(nil)
 
+// A compile time check to ensure ChannelAnnouncement implements the
+// lnwire.OptionalRecordsMessage interface.
+var _ OptionalRecordsMessage = (*ChannelAnnouncement)(nil)
+
+// A compile time check to ensure ChannelAnnouncement implements the
+// lnwire.UnknownRecordsMessage interface.
+var _ UnknownRecordsMessage = (*ChannelAnnouncement)(nil)
+
 // Decode deserializes a serialized ChannelAnnouncement stored in the passed
 // io.Reader observing the specified protocol version.
 //
@@ -137,6 +145,22 @@ func (a *ChannelAnnouncement) MaxPayloadLength(pver uint32) uint32 {
 	return 65533
 }
 
+// ParsedOptionalRecords returns the sorted set of TLV types carried within the
+// ExtraOpaqueData of the message.
+//
+// This is part of the lnwire.OptionalRecordsMessage interface.
+func (a *ChannelAnnouncement) ParsedOptionalRecords() []uint64 {
+	return a.ExtraOpaqueData.parsedOptionalRecords()
+}
+
+// UnknownRecords returns the TLV records carried within the ExtraOpaqueData of
+// the message, none of which we know of.
+//
+// This is part of the lnwire.UnknownRecordsMessage interface.
+func (a *ChannelAnnouncement) UnknownRecords() []UnknownRecord {
+	return a.ExtraOpaqueData.unknownRecords()
+}
+
 // DataToSign is used to retrieve part of the announcement message which should
 // be signed.
 func (a *ChannelAnnouncement) DataToSign() ([]byte, error) {
@@ -158,3 +182,22 @@ func (a *ChannelAnnouncement) DataToSign() ([]byte, error) {
 
 	return w.Bytes(), nil
 }
+
+// ConflictsWith returns whether the other announcement is for the same
+// channel, i.e. the same short channel ID on the same chain, but reports a
+// different set of parties for it, through either their node IDs or bitcoin
+// keys. The capacity of a channel isn't part of its announcement, and must be
+// compared against the funding output instead. A second announcement
+// conflicting with one we already know of reuses the short channel ID with
+// different parties, and shouldn't be accepted.
+func (a *ChannelAnnouncement) ConflictsWith(other *ChannelAnnouncement) bool {
+	if a.ChainHash != other.ChainHash ||
+		a.ShortChannelID != other.ShortChannelID {
+
+		return false
+	}
+
+	return a.NodeID1 != other.NodeID1 || a.NodeID2 != other.NodeID2 ||
+		a.BitcoinKey1 != other.BitcoinKey1 ||
+		a.BitcoinKey2 != other.BitcoinKey2
+}
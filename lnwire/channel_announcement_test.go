@@ -0,0 +1,53 @@
+package lnwire
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestChannelAnnouncementConflictsWith asserts that two announcements only
+// conflict if they're for the same channel, but report different parties.
+func TestChannelAnnouncementConflictsWith(t *testing.T) {
+	t.Parallel()
+
+	ann := &ChannelAnnouncement{
+		ShortChannelID: NewShortChanIDFromInt(1),
+		NodeID1:        [33]byte{0x02, 0x01},
+		NodeID2:        [33]byte{0x02, 0x02},
+		BitcoinKey1:    [33]byte{0x02, 0x03},
+		BitcoinKey2:    [33]byte{0x02, 0x04},
+	}
+
+	// An identical announcement, even with different signatures, doesn't
+	// conflict.
+	same := *ann
+	same.NodeSig1[0] = 0x01
+	require.False(t, ann.ConflictsWith(&same))
+
+	// Neither does an announcement for another channel with different
+	// parties.
+	otherChan := *ann
+	otherChan.ShortChannelID = NewShortChanIDFromInt(2)
+	otherChan.NodeID1[1] = 0xff
+	require.False(t, ann.ConflictsWith(&otherChan))
+
+	otherChain := *ann
+	otherChain.ChainHash[0] = 0x01
+	otherChain.NodeID1[1] = 0xff
+	require.False(t, ann.ConflictsWith(&otherChain))
+
+	// Changing any of the parties for the same channel should conflict.
+	changes := []func(a *ChannelAnnouncement){
+		func(a *ChannelAnnouncement) { a.NodeID1[1] = 0xff },
+		func(a *ChannelAnnouncement) { a.NodeID2[1] = 0xff },
+		func(a *ChannelAnnouncement) { a.BitcoinKey1[1] = 0xff },
+		func(a *ChannelAnnouncement) { a.BitcoinKey2[1] = 0xff },
+	}
+	for i, change := range changes {
+		conflicting := *ann
+		change(&conflicting)
+		require.True(t, ann.ConflictsWith(&conflicting), "change %d", i)
+		require.True(t, conflicting.ConflictsWith(ann), "change %d", i)
+	}
+}
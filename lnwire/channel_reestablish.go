@@ -1,11 +1,65 @@
 package lnwire
 
 import (
+	"errors"
 	"io"
 
 	"github.com/btcsuite/btcd/btcec"
 )
 
+var (
+	// ErrReestablishLocalDataLoss is returned when the remote party knows
+	// of a newer state of our commitment chain than we do, meaning we've
+	// most likely lost data. We must not force close the channel, as
+	// broadcasting our stale commitment would allow the remote party to
+	// claim all funds, and should instead wait for them to do so.
+	ErrReestablishLocalDataLoss = errors.New("remote party knows of a " +
+		"newer local commitment, local data loss detected")
+
+	// ErrReestablishRemoteDataLoss is returned when the remote party is
+	// behind on states they already acknowledged, meaning they've most
+	// likely lost data. The channel should be force closed.
+	ErrReestablishRemoteDataLoss = errors.New("remote party is behind " +
+		"on acknowledged states, remote data loss detected")
+
+	// ErrReestablishCannotSync is returned when the remote party claims a
+	// state of their commitment chain we never signed. Since we can't
+	// tell whether we lost data or the remote party is lying, the channel
+	// should be failed, but not force closed.
+	ErrReestablishCannotSync = errors.New("unable to sync commitment " +
+		"chains")
+)
+
+// CommitChainHeights is our view of the commitment chains of a channel, as
+// needed to process a ChannelReestablish received from the remote party.
+type CommitChainHeights struct {
+	// LocalTailHeight is the height of our current, unrevoked local
+	// commitment.
+	LocalTailHeight uint64
+
+	// RemoteTailHeight is the height of the remote party's current,
+	// unrevoked commitment.
+	RemoteTailHeight uint64
+
+	// RemoteTipHeight is the height of the latest commitment we signed
+	// for the remote party. It's one more than RemoteTailHeight if they
+	// haven't revoked their prior commitment yet, and equal otherwise.
+	RemoteTipHeight uint64
+}
+
+// ReestablishDecision details the messages that must be retransmitted to the
+// remote party to resynchronize the commitment chains of a channel.
+type ReestablishDecision struct {
+	// RetransmitRevokeAndAck is true if the remote party never received
+	// our last RevokeAndAck, which must then be sent again.
+	RetransmitRevokeAndAck bool
+
+	// RetransmitCommitSig is true if the remote party never received our
+	// last CommitSig, which must then be sent again along with the updates
+	// it covers.
+	RetransmitCommitSig bool
+}
+
 // ChannelReestablish is a message sent between peers that have an existing
 // open channel upon connection reestablishment. This message allows both sides
 // to report their local state, and their current knowledge of the state of the
@@ -164,3 +218,52 @@ func (a *ChannelReestablish) MaxPayloadLength(pver uint32) uint32 {
 
 	return length
 }
+
+// Reconcile applies the BOLT #2 reestablishment rules to the ChannelReestablish
+// received from the remote party, given our view of the commitment chains. It
+// returns the messages that must be retransmitted, or one of
+// ErrReestablishLocalDataLoss, ErrReestablishRemoteDataLoss or
+// ErrReestablishCannotSync if the chains can't be resynchronized.
+func (a *ChannelReestablish) Reconcile(
+	heights CommitChainHeights) (*ReestablishDecision, error) {
+
+	var decision ReestablishDecision
+
+	// First, we'll compare the remote party's view of our commitment
+	// chain with our own, to determine whether they missed our last
+	// revocation.
+	switch {
+	case a.RemoteCommitTailHeight > heights.LocalTailHeight:
+		return nil, ErrReestablishLocalDataLoss
+
+	case a.RemoteCommitTailHeight+1 < heights.LocalTailHeight:
+		return nil, ErrReestablishRemoteDataLoss
+
+	case a.RemoteCommitTailHeight+1 == heights.LocalTailHeight:
+		decision.RetransmitRevokeAndAck = true
+	}
+
+	// Next, we'll compare the height the remote party expects their next
+	// commitment to have with the latest one we signed for them, to
+	// determine whether they missed our last signature.
+	switch {
+	case a.NextLocalCommitHeight > heights.RemoteTipHeight+1:
+		return nil, ErrReestablishCannotSync
+
+	case a.NextLocalCommitHeight <= heights.RemoteTailHeight:
+		return nil, ErrReestablishRemoteDataLoss
+
+	// They received our latest commitment.
+	case a.NextLocalCommitHeight == heights.RemoteTipHeight+1:
+
+	case a.NextLocalCommitHeight == heights.RemoteTipHeight:
+		decision.RetransmitCommitSig = true
+
+	// As the commitment chain can have at most two elements, no other
+	// state is possible.
+	default:
+		return nil, ErrReestablishCannotSync
+	}
+
+	return &decision, nil
+}
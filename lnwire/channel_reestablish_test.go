@@ -0,0 +1,122 @@
+package lnwire
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestChannelReestablishReconcile asserts that the BOLT #2 reestablishment
+// rules result in the expected retransmissions or desync errors.
+func TestChannelReestablishReconcile(t *testing.T) {
+	t.Parallel()
+
+	// Our local chain is at height 10, and we've signed the remote's
+	// commitment at height 7 without them revoking height 6 yet.
+	pending := CommitChainHeights{
+		LocalTailHeight:  10,
+		RemoteTailHeight: 6,
+		RemoteTipHeight:  7,
+	}
+
+	// Same as above, but with the remote chain fully acknowledged.
+	synced := CommitChainHeights{
+		LocalTailHeight:  10,
+		RemoteTailHeight: 6,
+		RemoteTipHeight:  6,
+	}
+
+	testCases := []struct {
+		name       string
+		heights    CommitChainHeights
+		nextLocal  uint64
+		remoteTail uint64
+		decision   *ReestablishDecision
+		err        error
+	}{
+		{
+			name:       "in sync",
+			heights:    synced,
+			nextLocal:  7,
+			remoteTail: 10,
+			decision:   &ReestablishDecision{},
+		},
+		{
+			name:       "received pending commitment",
+			heights:    pending,
+			nextLocal:  8,
+			remoteTail: 10,
+			decision:   &ReestablishDecision{},
+		},
+		{
+			name:       "missed revocation",
+			heights:    synced,
+			nextLocal:  7,
+			remoteTail: 9,
+			decision: &ReestablishDecision{
+				RetransmitRevokeAndAck: true,
+			},
+		},
+		{
+			name:       "missed commitment",
+			heights:    pending,
+			nextLocal:  7,
+			remoteTail: 10,
+			decision: &ReestablishDecision{
+				RetransmitCommitSig: true,
+			},
+		},
+		{
+			name:       "missed revocation and commitment",
+			heights:    pending,
+			nextLocal:  7,
+			remoteTail: 9,
+			decision: &ReestablishDecision{
+				RetransmitRevokeAndAck: true,
+				RetransmitCommitSig:    true,
+			},
+		},
+		{
+			name:       "local data loss",
+			heights:    synced,
+			nextLocal:  7,
+			remoteTail: 11,
+			err:        ErrReestablishLocalDataLoss,
+		},
+		{
+			name:       "remote behind on our chain",
+			heights:    synced,
+			nextLocal:  7,
+			remoteTail: 8,
+			err:        ErrReestablishRemoteDataLoss,
+		},
+		{
+			name:       "remote behind on their chain",
+			heights:    synced,
+			nextLocal:  6,
+			remoteTail: 10,
+			err:        ErrReestablishRemoteDataLoss,
+		},
+		{
+			name:       "remote ahead on their chain",
+			heights:    synced,
+			nextLocal:  8,
+			remoteTail: 10,
+			err:        ErrReestablishCannotSync,
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			msg := &ChannelReestablish{
+				NextLocalCommitHeight:  testCase.nextLocal,
+				RemoteCommitTailHeight: testCase.remoteTail,
+			}
+
+			decision, err := msg.Reconcile(testCase.heights)
+			require.Equal(t, testCase.err, err)
+			require.Equal(t, testCase.decision, decision)
+		})
+	}
+}
@@ -2,13 +2,70 @@ package lnwire
 
 import (
 	"bytes"
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"math"
+	"time"
 
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/lightningnetwork/lnd/tlv"
 )
 
+const (
+	// InboundFeeRecordType is the TLV type of the optional record within
+	// the ExtraOpaqueData of a ChannelUpdate that carries the inbound
+	// routing fees of the channel.
+	InboundFeeRecordType tlv.Type = 55555
+
+	// inboundFeeRecordSize is the size of an encoded InboundFee record:
+	// a 4 byte base fee followed by a 4 byte fee rate.
+	inboundFeeRecordSize = 8
+)
+
+// ErrVerbatimUpdate is returned when attempting to substitute the short
+// channel ID of a ChannelUpdate that must be forwarded verbatim, such as one
+// signed by a remote node, since any mutation would invalidate its signature
+// and we aren't able to produce a new one.
+var ErrVerbatimUpdate = errors.New("channel update must be forwarded " +
+	"verbatim")
+
+// ErrTimestampOverflow is returned when preparing a ChannelUpdate for signing
+// would require a timestamp that can't be represented on the wire.
+var ErrTimestampOverflow = errors.New("channel update timestamp overflows")
+
+// ErrClockBackwards is returned when preparing a ChannelUpdate for signing
+// with a time that lies before the time it was last prepared at.
+type ErrClockBackwards struct {
+	now          time.Time
+	lastPrepared time.Time
+}
+
+// Error returns a human readable string describing the error.
+//
+// NOTE: implements the error interface.
+func (e ErrClockBackwards) Error() string {
+	return fmt.Sprintf("current time %v is before time %v the channel "+
+		"update was last prepared at", e.now, e.lastPrepared)
+}
+
+// ErrUpdateSCIDMismatch is returned when a ChannelUpdate doesn't reference its
+// channel by the short channel ID appropriate for its destination.
+type ErrUpdateSCIDMismatch struct {
+	expected ShortChannelID
+	actual   ShortChannelID
+}
+
+// Error returns a human readable string describing the error.
+//
+// NOTE: implements the error interface.
+func (e ErrUpdateSCIDMismatch) Error() string {
+	return fmt.Sprintf("channel update references short channel id %v, "+
+		"expected %v", e.actual, e.expected)
+}
+
 // ChanUpdateMsgFlags is a bitfield that signals whether optional fields are
 // present in the ChannelUpdate.
 type ChanUpdateMsgFlags uint8
@@ -57,6 +114,59 @@ func (c ChanUpdateChanFlags) String() string {
 	return fmt.Sprintf("%08b", c)
 }
 
+// ValidateDirection checks that the direction bit of the update's channel
+// flags is consistent with the position of the signing node within the
+// channel, where node1 and node2 are the ordered node IDs from the channel's
+// ChannelAnnouncement. This prevents accepting an update that claims to be
+// from the other side of the channel than the one that signed it.
+func ValidateDirection(upd *ChannelUpdate, node1, node2,
+	signer [33]byte) error {
+
+	var expectedDirection ChanUpdateChanFlags
+	switch signer {
+	case node1:
+		expectedDirection = 0
+	case node2:
+		expectedDirection = ChanUpdateDirection
+	default:
+		return fmt.Errorf("signer %x is not part of channel %v",
+			signer, upd.ShortChannelID)
+	}
+
+	direction := upd.ChannelFlags & ChanUpdateDirection
+	if direction != expectedDirection {
+		return fmt.Errorf("channel update for %v has direction %d, but "+
+			"was signed by node %d", upd.ShortChannelID,
+			direction, expectedDirection+1)
+	}
+
+	return nil
+}
+
+// ValidateUpdateSCID checks that the update references its channel by the
+// short channel ID appropriate for the peer it's sent to. An update for a
+// private channel with an alias must use the alias, so that the existence of
+// the channel on-chain isn't leaked, while an update for a public channel, or
+// a private one without an alias, must use the real short channel ID. A zero
+// alias denotes that the channel doesn't have one.
+func ValidateUpdateSCID(upd *ChannelUpdate, alias, realSCID ShortChannelID,
+	private bool) error {
+
+	expected := realSCID
+	if private && alias != (ShortChannelID{}) {
+		expected = alias
+	}
+
+	if upd.ShortChannelID != expected {
+		return ErrUpdateSCIDMismatch{
+			expected: expected,
+			actual:   upd.ShortChannelID,
+		}
+	}
+
+	return nil
+}
+
 // ChannelUpdate message is used after channel has been initially announced.
 // Each side independently announces its fees and minimum expiry for HTLCs and
 // other parameters. Also this message is used to redeclare initially set
@@ -121,13 +231,21 @@ type ChannelUpdate struct {
 	// properly validate the set of signatures that cover these new fields,
 	// and ensure we're able to make upgrades to the network in a forwards
 	// compatible manner.
-	ExtraOpaqueData []byte
+	ExtraOpaqueData ExtraOpaqueData
 }
 
 // A compile time check to ensure ChannelUpdate implements the lnwire.Message
 // interface.
 var _ Message = (*ChannelUpdate)(nil)
 
+// A compile time check to ensure ChannelUpdate implements the
+// lnwire.OptionalRecordsMessage interface.
+var _ OptionalRecordsMessage = (*ChannelUpdate)(nil)
+
+// A compile time check to ensure ChannelUpdate implements the
+// lnwire.UnknownRecordsMessage interface.
+var _ UnknownRecordsMessage = (*ChannelUpdate)(nil)
+
 // Decode deserializes a serialized ChannelUpdate stored in the passed
 // io.Reader observing the specified protocol version.
 //
@@ -220,6 +338,22 @@ func (a *ChannelUpdate) MaxPayloadLength(pver uint32) uint32 {
 	return 65533
 }
 
+// ParsedOptionalRecords returns the sorted set of TLV types carried within the
+// ExtraOpaqueData of the message.
+//
+// This is part of the lnwire.OptionalRecordsMessage interface.
+func (a *ChannelUpdate) ParsedOptionalRecords() []uint64 {
+	return a.ExtraOpaqueData.parsedOptionalRecords()
+}
+
+// UnknownRecords returns the TLV records carried within the ExtraOpaqueData of
+// the message other than the inbound fee.
+//
+// This is part of the lnwire.UnknownRecordsMessage interface.
+func (a *ChannelUpdate) UnknownRecords() []UnknownRecord {
+	return a.ExtraOpaqueData.unknownRecords(InboundFeeRecordType)
+}
+
 // DataToSign is used to retrieve part of the announcement message which should
 // be signed.
 func (a *ChannelUpdate) DataToSign() ([]byte, error) {
@@ -256,3 +390,236 @@ func (a *ChannelUpdate) DataToSign() ([]byte, error) {
 
 	return w.Bytes(), nil
 }
+
+// CanonicalEncode serializes the target ChannelUpdate into the passed
+// io.Writer using the canonical layout: the fixed fields, followed by the
+// records within the ExtraOpaqueData sorted by their type. Unlike Encode, the
+// ExtraOpaqueData is required to be a valid TLV stream, as it's re-packed
+// rather than copied verbatim.
+//
+// As parsing the TLV stream already rejects records that are out of order,
+// duplicated or use non-minimal encodings, any update it succeeds for was
+// canonically encoded to begin with, and is re-emitted byte for byte. This
+// keeps the signature of a parsed update valid.
+func (a *ChannelUpdate) CanonicalEncode(w io.Writer, pver uint32) error {
+	canonical := *a
+
+	if len(a.ExtraOpaqueData) != 0 {
+		parsedTypes, err := a.ExtraOpaqueData.ExtractRecords()
+		if err != nil {
+			return err
+		}
+
+		tlvMap := make(map[uint64][]byte, len(parsedTypes))
+		for typ, value := range parsedTypes {
+			tlvMap[uint64(typ)] = value
+		}
+
+		canonical.ExtraOpaqueData = nil
+		err = canonical.ExtraOpaqueData.PackRecords(
+			tlv.MapToRecords(tlvMap)...,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return canonical.Encode(w, pver)
+}
+
+// ContentDigest returns a digest committing only to the policy of the update:
+// its flags, time lock delta, HTLC limits, fees and any extra opaque data.
+// Unlike DataToSign, it doesn't cover the timestamp, so two updates that only
+// differ in their timestamp and signature, e.g. due to a periodic refresh,
+// share the same digest. This allows detecting whether an update materially
+// changed the policy of a channel.
+func (a *ChannelUpdate) ContentDigest() (chainhash.Hash, error) {
+	var w bytes.Buffer
+	err := WriteElements(&w,
+		a.MessageFlags,
+		a.ChannelFlags,
+		a.TimeLockDelta,
+		a.HtlcMinimumMsat,
+		a.BaseFee,
+		a.FeeRate,
+	)
+	if err != nil {
+		return chainhash.Hash{}, err
+	}
+
+	if a.MessageFlags.HasMaxHtlc() {
+		if err := WriteElements(&w, a.HtlcMaximumMsat); err != nil {
+			return chainhash.Hash{}, err
+		}
+	}
+
+	if err := WriteElements(&w, a.ExtraOpaqueData); err != nil {
+		return chainhash.Hash{}, err
+	}
+
+	return chainhash.HashH(w.Bytes()), nil
+}
+
+// PrepareForSigning readies the ChannelUpdate to be signed after its policy
+// fields have been mutated. The signature is cleared and the timestamp is set
+// to now, or to one past the timestamp of the previous update if that isn't
+// before now, since peers ignore updates that aren't strictly newer than the
+// one they already have. Updates prepared repeatedly within the same second
+// thus carry timestamps running ahead of the clock, which is why the clock
+// going backwards is detected against lastPrepared, the clock reading the
+// update was last prepared with, rather than its timestamp. If now lies before
+// lastPrepared, an error is returned rather than producing an update that
+// would be ignored. A zero lastPrepared, e.g. for an update that was loaded
+// from disk, skips this check. The bytes to be signed are returned.
+func (a *ChannelUpdate) PrepareForSigning(now,
+	lastPrepared time.Time) ([]byte, error) {
+
+	unixNow := now.Unix()
+	if unixNow < 0 || unixNow > math.MaxUint32 {
+		return nil, ErrTimestampOverflow
+	}
+
+	if !lastPrepared.IsZero() && now.Before(lastPrepared) {
+		return nil, ErrClockBackwards{
+			now:          now,
+			lastPrepared: lastPrepared,
+		}
+	}
+
+	timestamp := uint32(unixNow)
+	if timestamp <= a.Timestamp {
+		if a.Timestamp == math.MaxUint32 {
+			return nil, ErrTimestampOverflow
+		}
+		timestamp = a.Timestamp + 1
+	}
+
+	a.Timestamp = timestamp
+	a.Signature = Sig{}
+
+	return a.DataToSign()
+}
+
+// NeedsSigning returns true if the update doesn't carry a signature, as is the
+// case after PrepareForSigning or SubstituteSCID, and must be signed before
+// it's broadcast.
+func (a *ChannelUpdate) NeedsSigning() bool {
+	return a.Signature == Sig{}
+}
+
+// SubstituteSCID returns a copy of the update referencing its channel by the
+// given short channel ID, e.g. to replace the real short channel ID of a
+// private channel with its alias. As the short channel ID is covered by the
+// signature, the copy is prepared for signing through PrepareForSigning,
+// clearing its signature so that it can't be mistakenly broadcast with one
+// that no longer covers it, and the bytes to be signed are returned. The
+// original update is left untouched.
+//
+// If the update already references the given short channel ID, the copy
+// retains its signature and nil is returned in place of the bytes to be
+// signed. Otherwise, if verbatim is set, the update can't be re-signed by us
+// and ErrVerbatimUpdate is returned. The now and lastPrepared clock readings
+// are passed on to PrepareForSigning.
+func (a *ChannelUpdate) SubstituteSCID(scid ShortChannelID, now,
+	lastPrepared time.Time, verbatim bool) (*ChannelUpdate, []byte, error) {
+
+	upd := *a
+	upd.ExtraOpaqueData = append(
+		ExtraOpaqueData(nil), a.ExtraOpaqueData...,
+	)
+
+	if upd.ShortChannelID == scid {
+		return &upd, nil, nil
+	}
+
+	if verbatim {
+		return nil, nil, ErrVerbatimUpdate
+	}
+
+	upd.ShortChannelID = scid
+	data, err := upd.PrepareForSigning(now, lastPrepared)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &upd, data, nil
+}
+
+// InboundFee houses the inbound routing fees of a channel. Unlike the regular
+// forwarding fees, these apply to HTLCs coming in through the channel and may
+// be negative, allowing a node to offer a discount on incoming traffic.
+type InboundFee struct {
+	// BaseFee is the base fee in millisatoshis applied to incoming HTLCs.
+	BaseFee int32
+
+	// FeeRate is the fee rate in parts per million applied to incoming
+	// HTLCs.
+	FeeRate int32
+}
+
+// Record returns a TLV record that can be used to encode/decode the inbound
+// fee to/from a TLV stream.
+func (f *InboundFee) Record() tlv.Record {
+	return tlv.MakeStaticRecord(
+		InboundFeeRecordType, f, inboundFeeRecordSize,
+		encodeInboundFee, decodeInboundFee,
+	)
+}
+
+// encodeInboundFee is a tlv.Encoder for InboundFee values.
+func encodeInboundFee(w io.Writer, val interface{}, buf *[8]byte) error {
+	if f, ok := val.(*InboundFee); ok {
+		binary.BigEndian.PutUint32(buf[:4], uint32(f.BaseFee))
+		binary.BigEndian.PutUint32(buf[4:], uint32(f.FeeRate))
+		_, err := w.Write(buf[:])
+		return err
+	}
+
+	return tlv.NewTypeForEncodingErr(val, "lnwire.InboundFee")
+}
+
+// decodeInboundFee is a tlv.Decoder for InboundFee values.
+func decodeInboundFee(r io.Reader, val interface{}, buf *[8]byte,
+	l uint64) error {
+
+	if f, ok := val.(*InboundFee); ok && l == inboundFeeRecordSize {
+		if _, err := io.ReadFull(r, buf[:]); err != nil {
+			return err
+		}
+		f.BaseFee = int32(binary.BigEndian.Uint32(buf[:4]))
+		f.FeeRate = int32(binary.BigEndian.Uint32(buf[4:]))
+
+		return nil
+	}
+
+	return tlv.NewTypeForDecodingErr(
+		val, "lnwire.InboundFee", l, inboundFeeRecordSize,
+	)
+}
+
+// InboundFee returns the inbound fee carried within the ExtraOpaqueData of
+// the update. If the update doesn't advertise any inbound fee, then nil is
+// returned. An error is returned if the ExtraOpaqueData isn't a valid TLV
+// stream.
+func (a *ChannelUpdate) InboundFee() (*InboundFee, error) {
+	var fee InboundFee
+	parsedTypes, err := a.ExtraOpaqueData.ExtractRecords(fee.Record())
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := parsedTypes[InboundFeeRecordType]; !ok {
+		return nil, nil
+	}
+
+	return &fee, nil
+}
+
+// SetInboundFee sets the inbound fee advertised by the update, replacing any
+// existing one. All other records within the ExtraOpaqueData are preserved.
+//
+// NOTE: As the ExtraOpaqueData is covered by the signature, the update must
+// be re-signed afterwards.
+func (a *ChannelUpdate) SetInboundFee(fee InboundFee) error {
+	return a.ExtraOpaqueData.replaceRecord(fee.Record())
+}
@@ -0,0 +1,353 @@
+package lnwire
+
+import (
+	"bytes"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/lightningnetwork/lnd/tlv"
+	"github.com/stretchr/testify/require"
+)
+
+// TestChannelUpdateInboundFee asserts that an inbound fee, including negative
+// values, can be set on a ChannelUpdate and survives a round trip through the
+// wire alongside other records within the ExtraOpaqueData.
+func TestChannelUpdateInboundFee(t *testing.T) {
+	t.Parallel()
+
+	// An update without any extra data shouldn't carry an inbound fee.
+	update := &ChannelUpdate{}
+	fee, err := update.InboundFee()
+	require.NoError(t, err)
+	require.Nil(t, fee)
+
+	// Populate the extra data with an unrelated record, which must be
+	// preserved once the inbound fee is set.
+	unknownValue := []byte{0x01, 0x02, 0x03}
+	err = update.ExtraOpaqueData.PackRecords(tlv.MakePrimitiveRecord(
+		tlv.Type(1), &unknownValue,
+	))
+	require.NoError(t, err)
+
+	expectedFee := InboundFee{
+		BaseFee: -1000,
+		FeeRate: -250,
+	}
+	require.NoError(t, update.SetInboundFee(expectedFee))
+
+	// Setting the fee again should replace the existing record rather than
+	// adding a duplicate one.
+	expectedFee.FeeRate = -500
+	require.NoError(t, update.SetInboundFee(expectedFee))
+
+	var b bytes.Buffer
+	_, err = WriteMessage(&b, update, 0)
+	require.NoError(t, err)
+
+	msg, err := ReadMessage(&b, 0)
+	require.NoError(t, err)
+	newUpdate := msg.(*ChannelUpdate)
+
+	fee, err = newUpdate.InboundFee()
+	require.NoError(t, err)
+	require.Equal(t, &expectedFee, fee)
+
+	var decodedValue []byte
+	_, err = newUpdate.ExtraOpaqueData.ExtractRecords(
+		tlv.MakePrimitiveRecord(tlv.Type(1), &decodedValue),
+	)
+	require.NoError(t, err)
+	require.Equal(t, unknownValue, decodedValue)
+}
+
+// TestValidateDirection asserts that the direction bit of a ChannelUpdate is
+// checked against the position of the signing node.
+func TestValidateDirection(t *testing.T) {
+	t.Parallel()
+
+	node1 := [33]byte{0x02, 0x01}
+	node2 := [33]byte{0x02, 0x02}
+	otherNode := [33]byte{0x02, 0x03}
+
+	update := &ChannelUpdate{}
+	require.NoError(t, ValidateDirection(update, node1, node2, node1))
+	require.Error(t, ValidateDirection(update, node1, node2, node2))
+
+	// The disabled bit shouldn't affect the direction.
+	update.ChannelFlags = ChanUpdateDirection | ChanUpdateDisabled
+	require.NoError(t, ValidateDirection(update, node1, node2, node2))
+	require.Error(t, ValidateDirection(update, node1, node2, node1))
+
+	// A signer that isn't part of the channel should be rejected.
+	require.Error(t, ValidateDirection(update, node1, node2, otherNode))
+}
+
+// TestChannelUpdateContentDigest asserts that the content digest of a
+// ChannelUpdate ignores its timestamp and signature, but commits to its
+// policy.
+func TestChannelUpdateContentDigest(t *testing.T) {
+	t.Parallel()
+
+	update := &ChannelUpdate{
+		ShortChannelID:  NewShortChanIDFromInt(1),
+		Timestamp:       1000,
+		MessageFlags:    ChanUpdateOptionMaxHtlc,
+		TimeLockDelta:   40,
+		HtlcMinimumMsat: 1000,
+		BaseFee:         1000,
+		FeeRate:         1,
+		HtlcMaximumMsat: 100000,
+	}
+	digest, err := update.ContentDigest()
+	require.NoError(t, err)
+
+	// A refresh that only bumps the timestamp and signature should yield
+	// the same digest.
+	refreshed := *update
+	refreshed.Timestamp++
+	refreshed.Signature[0] ^= 0xff
+	refreshedDigest, err := refreshed.ContentDigest()
+	require.NoError(t, err)
+	require.Equal(t, digest, refreshedDigest)
+
+	// Any change to the policy should change the digest.
+	policyChanges := []func(u *ChannelUpdate){
+		func(u *ChannelUpdate) { u.ChannelFlags |= ChanUpdateDisabled },
+		func(u *ChannelUpdate) { u.TimeLockDelta++ },
+		func(u *ChannelUpdate) { u.HtlcMinimumMsat++ },
+		func(u *ChannelUpdate) { u.BaseFee++ },
+		func(u *ChannelUpdate) { u.FeeRate++ },
+		func(u *ChannelUpdate) { u.HtlcMaximumMsat++ },
+		func(u *ChannelUpdate) {
+			require.NoError(t, u.SetInboundFee(InboundFee{
+				BaseFee: -1000,
+			}))
+		},
+	}
+	for i, change := range policyChanges {
+		changed := *update
+		change(&changed)
+
+		changedDigest, err := changed.ContentDigest()
+		require.NoError(t, err)
+		require.NotEqual(t, digest, changedDigest, "change %d", i)
+	}
+}
+
+// TestChannelUpdatePrepareForSigning asserts that preparing an update for
+// signing always results in a strictly newer timestamp, and that a clock going
+// backwards is rejected.
+func TestChannelUpdatePrepareForSigning(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1000, 0)
+	update := &ChannelUpdate{
+		Timestamp: 900,
+		Signature: Sig{1, 2, 3},
+	}
+
+	data, err := update.PrepareForSigning(now, time.Time{})
+	require.NoError(t, err)
+	require.Equal(t, uint32(1000), update.Timestamp)
+	require.Equal(t, Sig{}, update.Signature)
+
+	expData, err := update.DataToSign()
+	require.NoError(t, err)
+	require.Equal(t, expData, data)
+
+	// Preparing the update again within the same second should bump the
+	// timestamp, however often it's done, even though the timestamp runs
+	// ahead of the clock.
+	_, err = update.PrepareForSigning(now, now)
+	require.NoError(t, err)
+	require.Equal(t, uint32(1001), update.Timestamp)
+
+	_, err = update.PrepareForSigning(now, now)
+	require.NoError(t, err)
+	require.Equal(t, uint32(1002), update.Timestamp)
+
+	// The update should round trip through the wire unchanged, and once
+	// decoded still be prepared within the same second without the
+	// bumped timestamp being mistaken for the clock going backwards.
+	var b bytes.Buffer
+	require.NoError(t, update.Encode(&b, 0))
+	decoded := &ChannelUpdate{}
+	require.NoError(t, decoded.Decode(&b, 0))
+	require.Equal(t, update, decoded)
+
+	_, err = decoded.PrepareForSigning(now, now)
+	require.NoError(t, err)
+	require.Equal(t, uint32(1003), decoded.Timestamp)
+
+	// Once the clock catches up, the timestamp is still bumped past the
+	// previous one.
+	_, err = update.PrepareForSigning(now.Add(time.Second), now)
+	require.NoError(t, err)
+	require.Equal(t, uint32(1003), update.Timestamp)
+
+	// A time before the one the update was last prepared at should be
+	// rejected, leaving the timestamp untouched.
+	_, err = update.PrepareForSigning(now, now.Add(time.Second))
+	require.IsType(t, ErrClockBackwards{}, err)
+	require.Equal(t, uint32(1003), update.Timestamp)
+
+	// The timestamp can't be bumped past the maximum representable one.
+	update = &ChannelUpdate{Timestamp: math.MaxUint32}
+	_, err = update.PrepareForSigning(
+		time.Unix(math.MaxUint32, 0), time.Time{},
+	)
+	require.Equal(t, ErrTimestampOverflow, err)
+	require.Equal(t, uint32(math.MaxUint32), update.Timestamp)
+}
+
+// TestChannelUpdateSubstituteSCID asserts that substituting the short channel
+// ID of an update yields a copy that must be re-signed, leaving the original
+// untouched, and that updates to be forwarded verbatim aren't mutated.
+func TestChannelUpdateSubstituteSCID(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1000, 0)
+	realSCID := NewShortChanIDFromInt(1234)
+	alias := NewShortChanIDFromInt(5678)
+	update := &ChannelUpdate{
+		Signature:       Sig{1, 2, 3},
+		ShortChannelID:  realSCID,
+		Timestamp:       900,
+		ExtraOpaqueData: ExtraOpaqueData{0x01, 0x00},
+	}
+	require.False(t, update.NeedsSigning())
+
+	aliasUpdate, data, err := update.SubstituteSCID(
+		alias, now, time.Time{}, false,
+	)
+	require.NoError(t, err)
+	require.Equal(t, alias, aliasUpdate.ShortChannelID)
+	require.Equal(t, uint32(1000), aliasUpdate.Timestamp)
+	require.True(t, aliasUpdate.NeedsSigning())
+
+	expData, err := aliasUpdate.DataToSign()
+	require.NoError(t, err)
+	require.Equal(t, expData, data)
+
+	// The original update must still carry its signature over the real
+	// short channel ID.
+	require.Equal(t, realSCID, update.ShortChannelID)
+	require.Equal(t, uint32(900), update.Timestamp)
+	require.False(t, update.NeedsSigning())
+
+	// Substituting the short channel ID the update already references
+	// doesn't require re-signing, even if it's forwarded verbatim.
+	sameUpdate, data, err := update.SubstituteSCID(
+		realSCID, now, time.Time{}, true,
+	)
+	require.NoError(t, err)
+	require.Nil(t, data)
+	require.Equal(t, update, sameUpdate)
+
+	// Substituting the short channel ID of the copy again within the same
+	// second must not be mistaken for the clock going backwards.
+	_, data, err = aliasUpdate.SubstituteSCID(realSCID, now, now, false)
+	require.NoError(t, err)
+	require.NotNil(t, data)
+
+	// An update to be forwarded verbatim can't be mutated otherwise.
+	_, _, err = update.SubstituteSCID(alias, now, time.Time{}, true)
+	require.Equal(t, ErrVerbatimUpdate, err)
+
+	// A clock going backwards is surfaced from PrepareForSigning.
+	_, _, err = update.SubstituteSCID(alias, time.Unix(800, 0), now, false)
+	require.IsType(t, ErrClockBackwards{}, err)
+}
+
+// TestChannelUpdateCanonicalEncode asserts that a parsed update carrying TLV
+// records within its ExtraOpaqueData is canonically encoded exactly as it was
+// on the wire, and that extra data which isn't a canonical TLV stream is
+// rejected.
+func TestChannelUpdateCanonicalEncode(t *testing.T) {
+	t.Parallel()
+
+	update := &ChannelUpdate{
+		ShortChannelID:  NewShortChanIDFromInt(1234),
+		Timestamp:       1600000000,
+		MessageFlags:    ChanUpdateOptionMaxHtlc,
+		TimeLockDelta:   40,
+		HtlcMinimumMsat: 1000,
+		BaseFee:         1,
+		FeeRate:         10,
+		HtlcMaximumMsat: 1000000,
+	}
+
+	unknownValue := []byte{0x01, 0x02, 0x03}
+	err := update.ExtraOpaqueData.PackRecords(tlv.MakePrimitiveRecord(
+		tlv.Type(1), &unknownValue,
+	))
+	require.NoError(t, err)
+	require.NoError(t, update.SetInboundFee(InboundFee{
+		BaseFee: -1000,
+		FeeRate: -250,
+	}))
+
+	var payload, canonical bytes.Buffer
+	require.NoError(t, update.Encode(&payload, 0))
+	require.NoError(t, update.CanonicalEncode(&canonical, 0))
+	require.Equal(t, payload.Bytes(), canonical.Bytes())
+
+	var parsed ChannelUpdate
+	require.NoError(t, parsed.Decode(bytes.NewReader(payload.Bytes()), 0))
+	require.Equal(t, update, &parsed)
+
+	canonical.Reset()
+	require.NoError(t, parsed.CanonicalEncode(&canonical, 0))
+	require.Equal(t, payload.Bytes(), canonical.Bytes())
+
+	// An update without any extra data is trivially canonical.
+	update.ExtraOpaqueData = nil
+	payload.Reset()
+	canonical.Reset()
+	require.NoError(t, update.Encode(&payload, 0))
+	require.NoError(t, update.CanonicalEncode(&canonical, 0))
+	require.Equal(t, payload.Bytes(), canonical.Bytes())
+
+	// Records out of order have no canonical form, as they're already
+	// rejected when parsing the stream.
+	update.ExtraOpaqueData = []byte{0x03, 0x00, 0x01, 0x00}
+	err = update.CanonicalEncode(&canonical, 0)
+	require.Equal(t, tlv.ErrStreamNotCanonical, err)
+
+	// Neither has extra data that can't be parsed as a TLV stream.
+	update.ExtraOpaqueData = []byte{0x05}
+	require.Error(t, update.CanonicalEncode(&canonical, 0))
+}
+
+// TestValidateUpdateSCID asserts that updates for private channels with an
+// alias must use it, while all others must use the real short channel ID.
+func TestValidateUpdateSCID(t *testing.T) {
+	t.Parallel()
+
+	realSCID := NewShortChanIDFromInt(700000 << 40)
+	alias := ShortChannelID{BlockHeight: AliasStartBlockHeight}
+
+	realUpdate := &ChannelUpdate{ShortChannelID: realSCID}
+	aliasUpdate := &ChannelUpdate{ShortChannelID: alias}
+
+	// A private channel with an alias must never leak its real short
+	// channel ID.
+	err := ValidateUpdateSCID(aliasUpdate, alias, realSCID, true)
+	require.NoError(t, err)
+	err = ValidateUpdateSCID(realUpdate, alias, realSCID, true)
+	require.IsType(t, ErrUpdateSCIDMismatch{}, err)
+
+	// A public channel is known by its real short channel ID.
+	err = ValidateUpdateSCID(realUpdate, alias, realSCID, false)
+	require.NoError(t, err)
+	err = ValidateUpdateSCID(aliasUpdate, alias, realSCID, false)
+	require.IsType(t, ErrUpdateSCIDMismatch{}, err)
+
+	// A private channel without an alias can only use its real one.
+	noAlias := ShortChannelID{}
+	err = ValidateUpdateSCID(realUpdate, noAlias, realSCID, true)
+	require.NoError(t, err)
+	err = ValidateUpdateSCID(aliasUpdate, noAlias, realSCID, true)
+	require.IsType(t, ErrUpdateSCIDMismatch{}, err)
+}
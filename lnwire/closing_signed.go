@@ -1,6 +1,7 @@
 package lnwire
 
 import (
+	"fmt"
 	"io"
 
 	"github.com/btcsuite/btcutil"
@@ -40,6 +41,32 @@ func NewClosingSigned(cid ChannelID, fs btcutil.Amount,
 	}
 }
 
+// ErrFeeOutOfRange is returned when the fee proposed within a ClosingSigned
+// message lies outside of the acceptable range.
+type ErrFeeOutOfRange struct {
+	fee btcutil.Amount
+	min btcutil.Amount
+	max btcutil.Amount
+}
+
+// Error returns a human readable string describing the error.
+//
+// NOTE: implements the error interface.
+func (e ErrFeeOutOfRange) Error() string {
+	return fmt.Sprintf("proposed closing fee %v outside of range [%v, %v]",
+		e.fee, e.min, e.max)
+}
+
+// ValidateFee returns ErrFeeOutOfRange if the proposed fee doesn't lie within
+// the inclusive range [min, max].
+func (c *ClosingSigned) ValidateFee(min, max btcutil.Amount) error {
+	if c.FeeSatoshis < min || c.FeeSatoshis > max {
+		return ErrFeeOutOfRange{c.FeeSatoshis, min, max}
+	}
+
+	return nil
+}
+
 // A compile time check to ensure ClosingSigned implements the lnwire.Message
 // interface.
 var _ Message = (*ClosingSigned)(nil)
@@ -0,0 +1,72 @@
+package lnwire
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcutil"
+)
+
+// TestClosingSignedValidateFee asserts that only fees within the inclusive
+// range passed to ValidateFee are accepted.
+func TestClosingSignedValidateFee(t *testing.T) {
+	t.Parallel()
+
+	const (
+		minFee btcutil.Amount = 1000
+		maxFee btcutil.Amount = 5000
+	)
+
+	testCases := []struct {
+		name  string
+		fee   btcutil.Amount
+		valid bool
+	}{
+		{
+			name:  "below min",
+			fee:   minFee - 1,
+			valid: false,
+		},
+		{
+			name:  "min",
+			fee:   minFee,
+			valid: true,
+		},
+		{
+			name:  "within range",
+			fee:   3000,
+			valid: true,
+		},
+		{
+			name:  "max",
+			fee:   maxFee,
+			valid: true,
+		},
+		{
+			name:  "above max",
+			fee:   maxFee + 1,
+			valid: false,
+		},
+		{
+			name:  "negative",
+			fee:   -1,
+			valid: false,
+		},
+	}
+
+	for _, test := range testCases {
+		msg := NewClosingSigned(ChannelID{}, test.fee, Sig{})
+		err := msg.ValidateFee(minFee, maxFee)
+
+		switch {
+		case test.valid && err != nil:
+			t.Fatalf("%s: expected fee %v to be valid: %v",
+				test.name, test.fee, err)
+
+		case !test.valid:
+			if _, ok := err.(ErrFeeOutOfRange); !ok {
+				t.Fatalf("%s: expected ErrFeeOutOfRange, got: %v",
+					test.name, err)
+			}
+		}
+	}
+}
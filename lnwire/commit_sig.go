@@ -4,6 +4,11 @@ import (
 	"io"
 )
 
+// MaxHTLCSigs is the maximum number of HTLC signatures a CommitSig can carry.
+// A commitment transaction holds at most 483 HTLCs in each direction, each of
+// which requires its own signature.
+const MaxHTLCSigs = 483 * 2
+
 // CommitSig is sent by either side to stage any pending HTLC's in the
 // receiver's pending set into a new commitment state. Implicitly, the new
 // commitment transaction constructed which has been signed by CommitSig
@@ -0,0 +1,39 @@
+package lnwire
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// TestCommitSigMaxHTLCSigs asserts that a CommitSig carrying more than
+// MaxHTLCSigs signatures is rejected when decoding.
+func TestCommitSigMaxHTLCSigs(t *testing.T) {
+	t.Parallel()
+
+	// A CommitSig at the limit should be decoded fine.
+	commitSig := NewCommitSig()
+	commitSig.HtlcSigs = make([]Sig, MaxHTLCSigs)
+
+	var b bytes.Buffer
+	if err := commitSig.Encode(&b, 0); err != nil {
+		t.Fatalf("unable to encode commit sig: %v", err)
+	}
+
+	var decoded CommitSig
+	if err := decoded.Decode(bytes.NewReader(b.Bytes()), 0); err != nil {
+		t.Fatalf("unable to decode commit sig: %v", err)
+	}
+
+	// Bumping the encoded count by one should cause it to be rejected
+	// before any of the signatures are read. The count follows the
+	// channel ID and commitment signature.
+	raw := b.Bytes()
+	countOffset := 32 + 64
+	binary.BigEndian.PutUint16(raw[countOffset:], MaxHTLCSigs+1)
+
+	if err := decoded.Decode(bytes.NewReader(raw), 0); err == nil {
+		t.Fatalf("expected commit sig with %d htlc sigs to be "+
+			"rejected", MaxHTLCSigs+1)
+	}
+}
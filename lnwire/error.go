@@ -1,6 +1,7 @@
 package lnwire
 
 import (
+	"encoding/binary"
 	"fmt"
 	"io"
 )
@@ -52,6 +53,62 @@ func (e FundingError) Error() string {
 // set includes byte values 32 through 127 inclusive.
 type ErrorData []byte
 
+// DefaultMaxErrorDataLen is the default maximum length of the data carried by
+// an Error or Warning enforced when decoding it. It leaves plenty of room for a
+// descriptive message, while bounding the cost of the hostile ones a peer
+// might send us. A different limit can be enforced through the
+// DecodeWithMaxDataLen method of either message.
+const DefaultMaxErrorDataLen = 4096
+
+// ErrErrorDataTooLarge is returned when decoding an Error or Warning that
+// carries more data than allowed.
+type ErrErrorDataTooLarge struct {
+	dataLen int
+	limit   int
+}
+
+// Error returns a human readable string describing the error.
+//
+// NOTE: implements the error interface.
+func (e ErrErrorDataTooLarge) Error() string {
+	return fmt.Sprintf("error data of %d bytes exceeds maximum of %d "+
+		"bytes", e.dataLen, e.limit)
+}
+
+// DataLen returns the length of the data carried by the rejected message.
+func (e ErrErrorDataTooLarge) DataLen() int {
+	return e.dataLen
+}
+
+// Limit returns the maximum length of the data that was exceeded.
+func (e ErrErrorDataTooLarge) Limit() int {
+	return e.limit
+}
+
+// readErrorData reads length prefixed error data from r, failing with
+// ErrErrorDataTooLarge if it's longer than maxDataLen, before reading any of
+// the data itself.
+func readErrorData(r io.Reader, maxDataLen int) (ErrorData, error) {
+	var l [2]byte
+	if _, err := io.ReadFull(r, l[:]); err != nil {
+		return nil, err
+	}
+	dataLen := int(binary.BigEndian.Uint16(l[:]))
+	if dataLen > maxDataLen {
+		return nil, ErrErrorDataTooLarge{
+			dataLen: dataLen,
+			limit:   maxDataLen,
+		}
+	}
+
+	data := make(ErrorData, dataLen)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
 // Error represents a generic error bound to an exact channel. The message
 // format is purposefully general in order to allow expression of a wide array
 // of possible errors. Each Error message is directed at a particular open
@@ -93,10 +150,23 @@ func (c *Error) Error() string {
 //
 // This is part of the lnwire.Message interface.
 func (c *Error) Decode(r io.Reader, pver uint32) error {
-	return ReadElements(r,
-		&c.ChanID,
-		&c.Data,
-	)
+	return c.DecodeWithMaxDataLen(r, pver, DefaultMaxErrorDataLen)
+}
+
+// DecodeWithMaxDataLen deserializes a serialized Error message like Decode,
+// but fails with ErrErrorDataTooLarge if it carries more than maxDataLen bytes
+// of data.
+func (c *Error) DecodeWithMaxDataLen(r io.Reader, pver uint32,
+	maxDataLen int) error {
+
+	if err := ReadElements(r, &c.ChanID); err != nil {
+		return err
+	}
+
+	var err error
+	c.Data, err = readErrorData(r, maxDataLen)
+
+	return err
 }
 
 // Encode serializes the target Error into the passed io.Writer observing the
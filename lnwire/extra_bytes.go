@@ -0,0 +1,225 @@
+package lnwire
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"sort"
+
+	"github.com/lightningnetwork/lnd/tlv"
+)
+
+// OptionalRecordsMessage is implemented by messages that may carry optional
+// TLV records, allowing callers to learn which of them a decoded message
+// actually carried, e.g. to drive behavior or for logging.
+type OptionalRecordsMessage interface {
+	Message
+
+	// ParsedOptionalRecords returns the sorted set of optional TLV types
+	// carried by the message. If the message doesn't carry any, or they
+	// can't be parsed as a valid TLV stream, nil is returned.
+	ParsedOptionalRecords() []uint64
+}
+
+// UnknownRecord is a TLV record carried by a message that we don't know how to
+// interpret, along with its raw encoded value.
+type UnknownRecord struct {
+	// Type is the TLV type of the record.
+	Type tlv.Type
+
+	// Value is the raw encoded value of the record.
+	Value []byte
+}
+
+// UnknownRecordsMessage is implemented by messages that retain the TLV records
+// they carry but we don't know of, allowing callers to inspect them, e.g. to
+// measure the adoption of new extensions across the network.
+//
+// As the unknown records are a subset of the optional records a message
+// carries, every such message also reports the types of all of its optional
+// records.
+type UnknownRecordsMessage interface {
+	OptionalRecordsMessage
+
+	// UnknownRecords returns the TLV records carried by the message that
+	// we don't know of, sorted by type. If the message doesn't carry any,
+	// or they can't be parsed as a valid TLV stream, nil is returned.
+	UnknownRecords() []UnknownRecord
+}
+
+// ExtraOpaqueData is the set of data that was appended to a message, some of
+// which we may not actually know how to iterate or parse. By holding onto
+// this data, we ensure that we're able to properly validate the set of
+// signatures that cover these new fields, and ensure we're able to make
+// upgrades to the network in a forwards compatible manner. When a message
+// extends itself with new fields, they're expected to be encoded within this
+// data as a TLV stream.
+//
+// As the extra data simply consists of any bytes trailing the known fields of
+// a message, a nil and an empty ExtraOpaqueData are equivalent: both encode to
+// zero bytes, and decoding zero bytes always results in a nil ExtraOpaqueData.
+// This ensures re-serializing a decoded message is byte-exact, which matters
+// for messages whose signature covers the extra data. Use IsPresent to check
+// whether any extra data was carried.
+type ExtraOpaqueData []byte
+
+// IsPresent returns true if the message carried any extra data at all.
+func (e *ExtraOpaqueData) IsPresent() bool {
+	return e != nil && len(*e) > 0
+}
+
+// Encode attempts to encode the raw extra bytes into the passed io.Writer.
+func (e *ExtraOpaqueData) Encode(w io.Writer) error {
+	eBytes := []byte((*e)[:])
+	return WriteElements(w, eBytes)
+}
+
+// Decode attempts to unpack the raw bytes encoded in the passed io.Reader as
+// a set of extra opaque data. If there aren't any bytes left, then we'll snip
+// off the slice to avoid carrying around excess capacity.
+func (e *ExtraOpaqueData) Decode(r io.Reader) error {
+	rawBytes, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	if len(rawBytes) == 0 {
+		*e = nil
+		return nil
+	}
+
+	*e = rawBytes
+	return nil
+}
+
+// PackRecords attempts to encode the set of tlv records into the target
+// ExtraOpaqueData instance. The records will be encoded as a raw TLV stream
+// and stored within the backing slice pointer.
+func (e *ExtraOpaqueData) PackRecords(records ...tlv.Record) error {
+	// The stream requires the records to be sorted by their type, so
+	// we'll ensure that's the case before encoding.
+	tlv.SortRecords(records)
+
+	tlvStream, err := tlv.NewStream(records...)
+	if err != nil {
+		return err
+	}
+
+	var extraBytesWriter bytes.Buffer
+	if err := tlvStream.Encode(&extraBytesWriter); err != nil {
+		return err
+	}
+
+	*e = ExtraOpaqueData(extraBytesWriter.Bytes())
+
+	return nil
+}
+
+// ExtractRecords attempts to decode any types in the internal raw bytes as if
+// it were a tlv stream. The set of raw parsed types is returned, with the
+// value of each record we don't know of set to its raw encoded bytes. Any
+// known records passed in are populated with their decoded values.
+func (e *ExtraOpaqueData) ExtractRecords(records ...tlv.Record) (
+	tlv.TypeMap, error) {
+
+	extraBytesReader := bytes.NewReader(*e)
+
+	tlvStream, err := tlv.NewStream(records...)
+	if err != nil {
+		return nil, err
+	}
+
+	return tlvStream.DecodeWithParsedTypes(extraBytesReader)
+}
+
+// replaceRecord encodes the passed record into the extra data, replacing any
+// existing record of the same type. All other records are preserved.
+func (e *ExtraOpaqueData) replaceRecord(record tlv.Record) error {
+	// First, we'll extract all records currently present in the extra
+	// data, so that we don't drop any that we don't know of.
+	parsedTypes, err := e.ExtractRecords()
+	if err != nil {
+		return err
+	}
+
+	tlvMap := make(map[uint64][]byte, len(parsedTypes)+1)
+	for typ, value := range parsedTypes {
+		tlvMap[uint64(typ)] = value
+	}
+
+	// With the existing records collected, we'll add our own, replacing
+	// any record of the same type that was previously set.
+	var b bytes.Buffer
+	if err := record.Encode(&b); err != nil {
+		return err
+	}
+	tlvMap[uint64(record.Type())] = b.Bytes()
+
+	return e.PackRecords(tlv.MapToRecords(tlvMap)...)
+}
+
+// TLVTypes returns the sorted set of TLV types present within the extra data,
+// without decoding their values. An error is returned if the extra data isn't
+// a valid TLV stream.
+func (e *ExtraOpaqueData) TLVTypes() ([]uint64, error) {
+	// Without any known records, every type within the stream will be
+	// reported as a parsed type.
+	parsedTypes, err := e.ExtractRecords()
+	if err != nil {
+		return nil, err
+	}
+
+	types := make([]uint64, 0, len(parsedTypes))
+	for typ := range parsedTypes {
+		types = append(types, uint64(typ))
+	}
+	sort.Slice(types, func(i, j int) bool {
+		return types[i] < types[j]
+	})
+
+	return types, nil
+}
+
+// parsedOptionalRecords returns the sorted set of TLV types present within the
+// extra data, or nil if there aren't any or the extra data isn't a valid TLV
+// stream.
+func (e *ExtraOpaqueData) parsedOptionalRecords() []uint64 {
+	types, err := e.TLVTypes()
+	if err != nil || len(types) == 0 {
+		return nil
+	}
+
+	return types
+}
+
+// unknownRecords returns the records within the extra data whose type isn't
+// one of the known types, sorted by type, or nil if there aren't any or the
+// extra data isn't a valid TLV stream.
+func (e *ExtraOpaqueData) unknownRecords(known ...tlv.Type) []UnknownRecord {
+	// Without any known records, every type within the stream will be
+	// reported along with its raw value.
+	parsedTypes, err := e.ExtractRecords()
+	if err != nil {
+		return nil
+	}
+
+	for _, typ := range known {
+		delete(parsedTypes, typ)
+	}
+	if len(parsedTypes) == 0 {
+		return nil
+	}
+
+	records := make([]UnknownRecord, 0, len(parsedTypes))
+	for typ, value := range parsedTypes {
+		records = append(records, UnknownRecord{
+			Type:  typ,
+			Value: value,
+		})
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Type < records[j].Type
+	})
+
+	return records
+}
@@ -0,0 +1,252 @@
+package lnwire
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/lightningnetwork/lnd/tlv"
+	"github.com/stretchr/testify/require"
+)
+
+// TestExtraOpaqueDataTLVTypes asserts that the types present within the
+// extra data are reported in sorted order, and that malformed extra data is
+// rejected.
+func TestExtraOpaqueDataTLVTypes(t *testing.T) {
+	t.Parallel()
+
+	// Empty extra data shouldn't contain any types.
+	var extraData ExtraOpaqueData
+	types, err := extraData.TLVTypes()
+	require.NoError(t, err)
+	require.Empty(t, types)
+
+	var (
+		a uint8  = 1
+		b uint32 = 2
+		c uint64 = 3
+	)
+	err = extraData.PackRecords(
+		tlv.MakePrimitiveRecord(65537, &c),
+		tlv.MakePrimitiveRecord(1, &a),
+		tlv.MakePrimitiveRecord(10, &b),
+	)
+	require.NoError(t, err)
+
+	types, err = extraData.TLVTypes()
+	require.NoError(t, err)
+	require.Equal(t, []uint64{1, 10, 65537}, types)
+
+	// A stream truncated in the middle of a record should be rejected.
+	truncated := extraData[:len(extraData)-1]
+	_, err = truncated.TLVTypes()
+	require.Error(t, err)
+}
+
+// TestExtraOpaqueDataNilEmpty asserts that nil and empty extra data are
+// encoded identically, both decode to nil, and aren't reported as present.
+func TestExtraOpaqueDataNilEmpty(t *testing.T) {
+	t.Parallel()
+
+	nilUpdate := &ChannelUpdate{}
+	emptyUpdate := &ChannelUpdate{
+		ExtraOpaqueData: make([]byte, 0),
+	}
+	require.False(t, nilUpdate.ExtraOpaqueData.IsPresent())
+	require.False(t, emptyUpdate.ExtraOpaqueData.IsPresent())
+
+	var nilBuf, emptyBuf bytes.Buffer
+	_, err := WriteMessage(&nilBuf, nilUpdate, 0)
+	require.NoError(t, err)
+	_, err = WriteMessage(&emptyBuf, emptyUpdate, 0)
+	require.NoError(t, err)
+	require.Equal(t, nilBuf.Bytes(), emptyBuf.Bytes())
+
+	// The data covered by the signature must be identical as well.
+	nilData, err := nilUpdate.DataToSign()
+	require.NoError(t, err)
+	emptyData, err := emptyUpdate.DataToSign()
+	require.NoError(t, err)
+	require.Equal(t, nilData, emptyData)
+
+	msg, err := ReadMessage(&emptyBuf, 0)
+	require.NoError(t, err)
+	decoded := msg.(*ChannelUpdate)
+	require.Nil(t, decoded.ExtraOpaqueData)
+	require.False(t, decoded.ExtraOpaqueData.IsPresent())
+
+	// Re-serializing the decoded message should be byte-exact.
+	var reencoded bytes.Buffer
+	_, err = WriteMessage(&reencoded, decoded, 0)
+	require.NoError(t, err)
+	require.Equal(t, nilBuf.Bytes(), reencoded.Bytes())
+
+	var extraData ExtraOpaqueData
+	require.NoError(t, extraData.Decode(bytes.NewReader([]byte{1, 1, 0})))
+	require.True(t, extraData.IsPresent())
+}
+
+// TestParsedOptionalRecords asserts that messages report the optional TLV
+// records they carried once decoded, and none if their extra data isn't a
+// valid TLV stream.
+func TestParsedOptionalRecords(t *testing.T) {
+	t.Parallel()
+
+	update := &ChannelUpdate{}
+	require.NoError(t, update.SetInboundFee(InboundFee{BaseFee: -1}))
+	warning := &Warning{}
+	require.NoError(t, warning.SetRetryDelay(time.Minute))
+
+	// An explicit zero retry delay is still carried on the wire, so it
+	// must be reported as well.
+	zeroDelayWarning := &Warning{}
+	require.NoError(t, zeroDelayWarning.SetRetryDelay(0))
+
+	var extraData ExtraOpaqueData
+	unknownValue := []byte{0x01}
+	err := extraData.PackRecords(
+		tlv.MakePrimitiveRecord(tlv.Type(3), &unknownValue),
+		tlv.MakePrimitiveRecord(tlv.Type(1), &unknownValue),
+	)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		msg      OptionalRecordsMessage
+		expected []uint64
+	}{
+		{
+			msg:      &ChannelUpdate{},
+			expected: nil,
+		},
+		{
+			msg:      update,
+			expected: []uint64{uint64(InboundFeeRecordType)},
+		},
+		{
+			msg: &ChannelAnnouncement{
+				Features:        NewRawFeatureVector(),
+				ExtraOpaqueData: extraData,
+			},
+			expected: []uint64{1, 3},
+		},
+		{
+			msg: &NodeAnnouncement{
+				Features:        NewRawFeatureVector(),
+				ExtraOpaqueData: ExtraOpaqueData{0x05},
+			},
+			expected: nil,
+		},
+		{
+			msg: &AnnounceSignatures{
+				ExtraOpaqueData: extraData,
+			},
+			expected: []uint64{1, 3},
+		},
+		{
+			msg:      warning,
+			expected: []uint64{uint64(RetryDelayRecordType)},
+		},
+		{
+			msg:      zeroDelayWarning,
+			expected: []uint64{uint64(RetryDelayRecordType)},
+		},
+		{
+			msg:      &Warning{},
+			expected: nil,
+		},
+	}
+
+	for _, testCase := range testCases {
+		// Round trip the message through the wire first, so that we
+		// inspect the records of the decoded message.
+		const pver = ProtocolVersionWarningRetry
+
+		var b bytes.Buffer
+		_, err := WriteMessage(&b, testCase.msg, pver)
+		require.NoError(t, err)
+		msg, err := ReadMessage(&b, pver)
+		require.NoError(t, err)
+
+		parsed := msg.(OptionalRecordsMessage).ParsedOptionalRecords()
+		require.Equal(t, testCase.expected, parsed, msg.MsgType())
+	}
+}
+
+// TestUnknownRecords asserts that the TLV records carried by a message that we
+// don't know of are reported along with their values, sorted by type.
+func TestUnknownRecords(t *testing.T) {
+	t.Parallel()
+
+	unknownValue1 := []byte{0x01}
+	unknownValue3 := []byte{0x03, 0x03}
+	inboundFee := InboundFee{BaseFee: -1}
+
+	var extraData ExtraOpaqueData
+	err := extraData.PackRecords(
+		tlv.MakePrimitiveRecord(tlv.Type(3), &unknownValue3),
+		tlv.MakePrimitiveRecord(tlv.Type(1), &unknownValue1),
+		inboundFee.Record(),
+	)
+	require.NoError(t, err)
+
+	expected := []UnknownRecord{
+		{Type: 1, Value: unknownValue1},
+		{Type: 3, Value: unknownValue3},
+	}
+
+	// The inbound fee is only known to the ChannelUpdate.
+	feeValue := extraData[len(extraData)-inboundFeeRecordSize:]
+	expectedWithFee := append(expected, UnknownRecord{
+		Type:  InboundFeeRecordType,
+		Value: feeValue,
+	})
+
+	testCases := []struct {
+		msg      UnknownRecordsMessage
+		expected []UnknownRecord
+	}{
+		{
+			msg:      &ChannelUpdate{},
+			expected: nil,
+		},
+		{
+			msg:      &ChannelUpdate{ExtraOpaqueData: extraData},
+			expected: expected,
+		},
+		{
+			msg: &ChannelAnnouncement{
+				Features:        NewRawFeatureVector(),
+				ExtraOpaqueData: extraData,
+			},
+			expected: expectedWithFee,
+		},
+		{
+			msg: &NodeAnnouncement{
+				Features:        NewRawFeatureVector(),
+				ExtraOpaqueData: ExtraOpaqueData{0x05},
+			},
+			expected: nil,
+		},
+		{
+			msg: &AnnounceSignatures{
+				ExtraOpaqueData: extraData,
+			},
+			expected: expectedWithFee,
+		},
+		{
+			msg:      &Warning{ExtraOpaqueData: extraData},
+			expected: expectedWithFee[1:],
+		},
+	}
+
+	for _, testCase := range testCases {
+		var b bytes.Buffer
+		_, err := WriteMessage(&b, testCase.msg, ProtocolVersionLatest)
+		require.NoError(t, err)
+		msg, err := ReadMessage(&b, ProtocolVersionLatest)
+		require.NoError(t, err)
+
+		unknown := msg.(UnknownRecordsMessage).UnknownRecords()
+		require.Equal(t, testCase.expected, unknown, msg.MsgType())
+	}
+}
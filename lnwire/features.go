@@ -3,13 +3,20 @@ package lnwire
 import (
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"io"
+	"sort"
+	"strings"
 )
 
 var (
 	// ErrFeaturePairExists signals an error in feature vector construction
 	// where the opposing bit in a feature pair has already been set.
 	ErrFeaturePairExists = errors.New("feature pair exists")
+
+	// ErrFeatureBitTooHigh signals that a decoded feature vector sets a
+	// bit above the maximum the caller is willing to accept.
+	ErrFeatureBitTooHigh = errors.New("feature bit above maximum")
 )
 
 // FeatureBit represents a feature that can be enabled in either a local or
@@ -129,6 +136,16 @@ const (
 	// transactions, which also imply anchor commitments.
 	AnchorsZeroFeeHtlcTxOptional FeatureBit = 23
 
+	// ShutdownAnySegwitRequired is a required feature bit that signals
+	// that the node requires the delivery scripts of a cooperative close
+	// to be allowed to pay to any future segwit version.
+	ShutdownAnySegwitRequired FeatureBit = 26
+
+	// ShutdownAnySegwitOptional is an optional feature bit that signals
+	// that the node allows the delivery scripts of a cooperative close to
+	// pay to any future segwit version.
+	ShutdownAnySegwitOptional FeatureBit = 27
+
 	// maxAllowedSize is a maximum allowed size of feature vector.
 	//
 	// NOTE: Within the protocol, the maximum allowed message size is 65535
@@ -172,6 +189,8 @@ var Features = map[FeatureBit]string{
 	AnchorsZeroFeeHtlcTxOptional:  "anchors-zero-fee-htlc-tx",
 	WumboChannelsRequired:         "wumbo-channels",
 	WumboChannelsOptional:         "wumbo-channels",
+	ShutdownAnySegwitRequired:     "shutdown-any-segwit",
+	ShutdownAnySegwitOptional:     "shutdown-any-segwit",
 }
 
 // RawFeatureVector represents a set of feature bits as defined in BOLT-09.  A
@@ -213,6 +232,19 @@ func (fv *RawFeatureVector) Clone() *RawFeatureVector {
 	return newFeatures
 }
 
+// Clamp returns a copy of the feature vector with all bits above maxBit
+// removed. This can be used to bound the features we keep track of for a
+// peer advertising implausibly high bits.
+func (fv *RawFeatureVector) Clamp(maxBit FeatureBit) *RawFeatureVector {
+	clamped := NewRawFeatureVector()
+	for bit := range fv.features {
+		if bit <= maxBit {
+			clamped.Set(bit)
+		}
+	}
+	return clamped
+}
+
 // IsSet returns whether a particular feature bit is enabled in the vector.
 func (fv *RawFeatureVector) IsSet(feature FeatureBit) bool {
 	return fv.features[feature]
@@ -335,6 +367,32 @@ func (fv *RawFeatureVector) Decode(r io.Reader) error {
 	return fv.decode(r, int(length), 8)
 }
 
+// DecodeWithMaxBit reads the feature vector from its binary representation
+// like Decode, but returns ErrFeatureBitTooHigh if any bit above maxBit is
+// set. The feature vector is left untouched if an error is returned.
+func (fv *RawFeatureVector) DecodeWithMaxBit(r io.Reader,
+	maxBit FeatureBit) error {
+
+	// We'll decode into a temporary vector first, so that none of the
+	// rejected bits make their way into the feature vector.
+	decoded := NewRawFeatureVector()
+	if err := decoded.Decode(r); err != nil {
+		return err
+	}
+
+	for bit := range decoded.features {
+		if bit > maxBit {
+			return ErrFeatureBitTooHigh
+		}
+	}
+
+	for bit := range decoded.features {
+		fv.Set(bit)
+	}
+
+	return nil
+}
+
 // DecodeBase256 reads the feature vector from its base256 representation. Every
 // feature encoded as a bit, and the bit vector is serialized using the least
 // number of bytes.
@@ -480,3 +538,59 @@ func (fv *FeatureVector) Clone() *FeatureVector {
 	features := fv.RawFeatureVector.Clone()
 	return NewFeatureVector(features, fv.featureNames)
 }
+
+// Diff compares the receiver against an older feature vector and returns the
+// set of bits that were added and removed, each sorted in ascending order. A
+// nil old vector is treated as having no bits set.
+func (fv *FeatureVector) Diff(old *FeatureVector) ([]FeatureBit,
+	[]FeatureBit) {
+
+	var oldFeatures map[FeatureBit]bool
+	if old != nil {
+		oldFeatures = old.features
+	}
+
+	var added, removed []FeatureBit
+	for bit := range fv.features {
+		if !oldFeatures[bit] {
+			added = append(added, bit)
+		}
+	}
+	for bit := range oldFeatures {
+		if !fv.features[bit] {
+			removed = append(removed, bit)
+		}
+	}
+
+	sortFeatureBits(added)
+	sortFeatureBits(removed)
+
+	return added, removed
+}
+
+// DiffString returns a human readable summary of the bits that changed
+// between an older feature vector and the receiver, using the receiver's
+// feature names to describe each bit.
+func (fv *FeatureVector) DiffString(old *FeatureVector) string {
+	added, removed := fv.Diff(old)
+
+	describe := func(bits []FeatureBit) string {
+		names := make([]string, 0, len(bits))
+		for _, bit := range bits {
+			names = append(
+				names, fmt.Sprintf("%s(%d)", fv.Name(bit), bit),
+			)
+		}
+		return strings.Join(names, ", ")
+	}
+
+	return fmt.Sprintf("added=[%s] removed=[%s]", describe(added),
+		describe(removed))
+}
+
+// sortFeatureBits sorts the passed feature bits in ascending order.
+func sortFeatureBits(bits []FeatureBit) {
+	sort.Slice(bits, func(i, j int) bool {
+		return bits[i] < bits[j]
+	})
+}
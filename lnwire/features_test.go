@@ -353,3 +353,60 @@ func TestFeatures(t *testing.T) {
 		})
 	}
 }
+
+// TestFeatureVectorDiff asserts that Diff properly reports the bits that were
+// added and removed between two feature vectors.
+func TestFeatureVectorDiff(t *testing.T) {
+	t.Parallel()
+
+	oldFV := NewFeatureVector(NewRawFeatureVector(0, 3, 5), testFeatureNames)
+	newFV := NewFeatureVector(NewRawFeatureVector(0, 4, 7), testFeatureNames)
+
+	added, removed := newFV.Diff(oldFV)
+	require.Equal(t, []FeatureBit{4, 7}, added)
+	require.Equal(t, []FeatureBit{3, 5}, removed)
+	require.Equal(
+		t, "added=[feature3(4), unknown(7)] removed=[feature2(3), "+
+			"feature3(5)]", newFV.DiffString(oldFV),
+	)
+
+	// Diffing against a nil vector should report every bit as added.
+	added, removed = newFV.Diff(nil)
+	require.Equal(t, []FeatureBit{0, 4, 7}, added)
+	require.Empty(t, removed)
+
+	// Diffing a vector against itself should yield no changes.
+	added, removed = newFV.Diff(newFV)
+	require.Empty(t, added)
+	require.Empty(t, removed)
+}
+
+// TestFeatureVectorClamp asserts that bits above a maximum are removed from
+// a clamped copy of a feature vector, and rejected when decoding with a
+// maximum bit.
+func TestFeatureVectorClamp(t *testing.T) {
+	t.Parallel()
+
+	fv := NewRawFeatureVector(0, 5, 100, 10000)
+
+	clamped := fv.Clamp(100)
+	require.Equal(t, NewRawFeatureVector(0, 5, 100), clamped)
+
+	// The original vector should be left untouched.
+	require.True(t, fv.IsSet(10000))
+
+	var b bytes.Buffer
+	require.NoError(t, fv.Encode(&b))
+	encoded := b.Bytes()
+
+	decoded := NewRawFeatureVector()
+	err := decoded.DecodeWithMaxBit(bytes.NewReader(encoded), 10000)
+	require.NoError(t, err)
+	require.Equal(t, fv, decoded)
+
+	// A rejected vector shouldn't leave any of its bits behind.
+	decoded = NewRawFeatureVector(1)
+	err = decoded.DecodeWithMaxBit(bytes.NewReader(encoded), 9999)
+	require.Equal(t, ErrFeatureBitTooHigh, err)
+	require.Equal(t, NewRawFeatureVector(1), decoded)
+}
@@ -1,11 +1,17 @@
 package lnwire
 
 import (
+	"errors"
 	"io"
 
 	"github.com/btcsuite/btcd/btcec"
 )
 
+// ErrInvalidPerCommitmentPoint is returned when the next per commitment point
+// of a FundingLocked message isn't a valid secp256k1 point.
+var ErrInvalidPerCommitmentPoint = errors.New("next per commitment point " +
+	"is not a valid point")
+
 // FundingLocked is the message that both parties to a new channel creation
 // send once they have observed the funding transaction being confirmed on the
 // blockchain. FundingLocked contains the signatures necessary for the channel
@@ -30,6 +36,27 @@ func NewFundingLocked(cid ChannelID, npcp *btcec.PublicKey) *FundingLocked {
 	}
 }
 
+// Validate ensures that the next per commitment point of the message is a
+// valid secp256k1 point other than the point at infinity, as commitments can't
+// be constructed from anything else. Points decoded from the wire are already
+// known to be on the curve, but those of a message constructed locally aren't.
+func (c *FundingLocked) Validate() error {
+	point := c.NextPerCommitmentPoint
+	if point == nil || point.X == nil || point.Y == nil {
+		return ErrInvalidPerCommitmentPoint
+	}
+
+	if point.X.Sign() == 0 && point.Y.Sign() == 0 {
+		return ErrInvalidPerCommitmentPoint
+	}
+
+	if !btcec.S256().IsOnCurve(point.X, point.Y) {
+		return ErrInvalidPerCommitmentPoint
+	}
+
+	return nil
+}
+
 // A compile time check to ensure FundingLocked implements the lnwire.Message
 // interface.
 var _ Message = (*FundingLocked)(nil)
@@ -0,0 +1,42 @@
+package lnwire
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/stretchr/testify/require"
+)
+
+// TestFundingLockedValidate asserts that only per commitment points that are
+// on the curve and not the point at infinity are accepted.
+func TestFundingLockedValidate(t *testing.T) {
+	t.Parallel()
+
+	pubKey, err := randPubKey()
+	require.NoError(t, err)
+
+	msg := NewFundingLocked(ChannelID{1}, pubKey)
+	require.NoError(t, msg.Validate())
+
+	// A valid message survives a round trip and remains valid.
+	var b bytes.Buffer
+	_, err = WriteMessage(&b, msg, 0)
+	require.NoError(t, err)
+
+	decoded, err := ReadMessage(&b, 0)
+	require.NoError(t, err)
+	require.NoError(t, decoded.(*FundingLocked).Validate())
+
+	invalidPoints := []*btcec.PublicKey{
+		nil,
+		{Curve: btcec.S256()},
+		{Curve: btcec.S256(), X: big.NewInt(0), Y: big.NewInt(0)},
+		{Curve: btcec.S256(), X: big.NewInt(1), Y: big.NewInt(1)},
+	}
+	for _, point := range invalidPoints {
+		msg := NewFundingLocked(ChannelID{1}, point)
+		require.Equal(t, ErrInvalidPerCommitmentPoint, msg.Validate())
+	}
+}
@@ -1,11 +1,29 @@
 package lnwire
 
 import (
+	"fmt"
 	"io"
+	"math"
 
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 )
 
+// ErrTimestampRangeOverflow is returned when constructing a
+// GossipTimestampRange whose range extends beyond the largest timestamp that
+// can be expressed.
+type ErrTimestampRangeOverflow struct {
+	firstTimestamp uint32
+	timestampRange uint32
+}
+
+// Error returns a human readable string describing the error.
+//
+// NOTE: implements the error interface.
+func (e ErrTimestampRangeOverflow) Error() string {
+	return fmt.Sprintf("timestamp range %d overflows when added to first "+
+		"timestamp %d", e.timestampRange, e.firstTimestamp)
+}
+
 // GossipTimestampRange is a message that allows the sender to restrict the set
 // of future gossip announcements sent by the receiver. Nodes should send this
 // if they have the gossip-queries feature bit active. Nodes are able to send
@@ -26,9 +44,41 @@ type GossipTimestampRange struct {
 	TimestampRange uint32
 }
 
-// NewGossipTimestampRange creates a new empty GossipTimestampRange message.
-func NewGossipTimestampRange() *GossipTimestampRange {
-	return &GossipTimestampRange{}
+// NewGossipTimestampRange creates a new GossipTimestampRange message for the
+// given chain, covering the rangeSecs seconds starting at the first
+// timestamp. An error is returned if the end of the range can't be expressed
+// as a timestamp. To receive all announcements from now on, the range should
+// be set to math.MaxUint32 minus the current timestamp, while a zero range
+// results in no announcements being received at all.
+func NewGossipTimestampRange(chain chainhash.Hash, first,
+	rangeSecs uint32) (*GossipTimestampRange, error) {
+
+	if uint64(first)+uint64(rangeSecs) > math.MaxUint32 {
+		return nil, ErrTimestampRangeOverflow{
+			firstTimestamp: first,
+			timestampRange: rangeSecs,
+		}
+	}
+
+	return &GossipTimestampRange{
+		ChainHash:      chain,
+		FirstTimestamp: first,
+		TimestampRange: rangeSecs,
+	}, nil
+}
+
+// WantsAll returns true if the sender wishes to receive every announcement
+// from the first timestamp onwards, i.e. the range extends up to, or beyond,
+// the largest timestamp that can be expressed.
+func (g *GossipTimestampRange) WantsAll() bool {
+	return uint64(g.FirstTimestamp)+uint64(g.TimestampRange) >=
+		math.MaxUint32
+}
+
+// WantsNone returns true if the sender doesn't wish to receive any
+// announcements at all, as the range is empty.
+func (g *GossipTimestampRange) WantsNone() bool {
+	return g.TimestampRange == 0
 }
 
 // A compile time check to ensure GossipTimestampRange implements the
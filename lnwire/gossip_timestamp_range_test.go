@@ -0,0 +1,43 @@
+package lnwire
+
+import (
+	"math"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/stretchr/testify/require"
+)
+
+// TestNewGossipTimestampRange asserts that only ranges whose end can be
+// expressed as a timestamp are constructed, and that the predicates reflect
+// the range.
+func TestNewGossipTimestampRange(t *testing.T) {
+	t.Parallel()
+
+	chain := chainhash.Hash{1}
+	const now = 1600000000
+
+	// A regular range neither covers everything nor nothing.
+	filter, err := NewGossipTimestampRange(chain, now, 3600)
+	require.NoError(t, err)
+	require.Equal(t, chain, filter.ChainHash)
+	require.False(t, filter.WantsAll())
+	require.False(t, filter.WantsNone())
+
+	// Extending the range up to the largest timestamp covers everything
+	// from now on.
+	filter, err = NewGossipTimestampRange(chain, now, math.MaxUint32-now)
+	require.NoError(t, err)
+	require.True(t, filter.WantsAll())
+	require.False(t, filter.WantsNone())
+
+	// Any further and the range overflows.
+	_, err = NewGossipTimestampRange(chain, now, math.MaxUint32-now+1)
+	require.IsType(t, ErrTimestampRangeOverflow{}, err)
+
+	// A zero range covers nothing.
+	filter, err = NewGossipTimestampRange(chain, 0, 0)
+	require.NoError(t, err)
+	require.False(t, filter.WantsAll())
+	require.True(t, filter.WantsNone())
+}
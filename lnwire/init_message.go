@@ -30,6 +30,80 @@ func NewInitMessage(gf *RawFeatureVector, f *RawFeatureVector) *Init {
 	}
 }
 
+// MergedFeatures returns the effective feature vector advertised by the
+// sender, i.e. the union of the legacy GlobalFeatures and Features. The
+// message itself is left untouched, so that the legacy global features are
+// re-encoded exactly as they were received.
+func (msg *Init) MergedFeatures() (*RawFeatureVector, error) {
+	merged := NewRawFeatureVector()
+	if msg.Features != nil {
+		merged = msg.Features.Clone()
+	}
+
+	if msg.GlobalFeatures != nil {
+		if err := merged.Merge(msg.GlobalFeatures); err != nil {
+			return nil, err
+		}
+	}
+
+	return merged, nil
+}
+
+// CompatibleUpgradeFrom compares the features advertised by the receiver
+// against those of an older Init sent by the same peer, e.g. before it
+// reconnected. The change is safe if the peer only added optional features,
+// or relaxed required ones. Otherwise, the breaking bits are returned in
+// ascending order: any newly required bits, and any bits of features that are
+// no longer advertised at all, as we may have relied on them. A nil old
+// message is treated as one without any features.
+func (msg *Init) CompatibleUpgradeFrom(old *Init) (bool, []FeatureBit) {
+	newFeatures := msg.advertisedFeatures()
+
+	var oldFeatures *FeatureVector
+	if old != nil {
+		oldFeatures = old.advertisedFeatures()
+	}
+
+	added, removed := newFeatures.Diff(oldFeatures)
+
+	var breaking []FeatureBit
+	for _, bit := range added {
+		if bit.IsRequired() {
+			breaking = append(breaking, bit)
+		}
+	}
+
+	// A removed bit is only breaking if the feature was dropped entirely,
+	// rather than having moved to the other bit of its pair.
+	for _, bit := range removed {
+		if !newFeatures.IsSet(bit ^ 1) {
+			breaking = append(breaking, bit)
+		}
+	}
+
+	sortFeatureBits(breaking)
+
+	return len(breaking) == 0, breaking
+}
+
+// advertisedFeatures returns every feature bit set in either the legacy
+// GlobalFeatures or Features. Unlike MergedFeatures, this never fails, as both
+// bits of a pair are allowed to be set.
+func (msg *Init) advertisedFeatures() *FeatureVector {
+	features := NewRawFeatureVector()
+	vectors := []*RawFeatureVector{msg.GlobalFeatures, msg.Features}
+	for _, fv := range vectors {
+		if fv == nil {
+			continue
+		}
+		for bit := range fv.features {
+			features.Set(bit)
+		}
+	}
+
+	return NewFeatureVector(features, nil)
+}
+
 // A compile time check to ensure Init implements the lnwire.Message
 // interface.
 var _ Message = (*Init)(nil)
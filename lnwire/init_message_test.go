@@ -0,0 +1,131 @@
+package lnwire
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestInitLegacyGlobalFeatures asserts that an Init message placing features
+// in the legacy global field round trips without losing them, and that the
+// merged feature vector is computed without mutating the message.
+func TestInitLegacyGlobalFeatures(t *testing.T) {
+	t.Parallel()
+
+	globalFeatures := NewRawFeatureVector(DataLossProtectOptional)
+	localFeatures := NewRawFeatureVector(GossipQueriesOptional)
+	init := NewInitMessage(globalFeatures, localFeatures)
+
+	var b bytes.Buffer
+	_, err := WriteMessage(&b, init, 0)
+	require.NoError(t, err)
+	encoded := append([]byte(nil), b.Bytes()...)
+
+	msg, err := ReadMessage(&b, 0)
+	require.NoError(t, err)
+	newInit := msg.(*Init)
+
+	// The legacy bit should still be in the global field, and only there.
+	require.True(t, newInit.GlobalFeatures.IsSet(DataLossProtectOptional))
+	require.False(t, newInit.Features.IsSet(DataLossProtectOptional))
+
+	merged, err := newInit.MergedFeatures()
+	require.NoError(t, err)
+	require.True(t, merged.IsSet(DataLossProtectOptional))
+	require.True(t, merged.IsSet(GossipQueriesOptional))
+
+	// Computing the merged features shouldn't have altered the message, so
+	// it should re-encode exactly as received.
+	b.Reset()
+	_, err = WriteMessage(&b, newInit, 0)
+	require.NoError(t, err)
+	require.Equal(t, encoded, b.Bytes())
+
+	// Conflicting bits across the two vectors should be reported.
+	conflicting := NewInitMessage(
+		NewRawFeatureVector(DataLossProtectRequired),
+		NewRawFeatureVector(DataLossProtectOptional),
+	)
+	_, err = conflicting.MergedFeatures()
+	require.Equal(t, ErrFeaturePairExists, err)
+}
+
+// TestInitCompatibleUpgradeFrom asserts that only newly required features and
+// dropped features are reported as breaking changes between two Init
+// messages.
+func TestInitCompatibleUpgradeFrom(t *testing.T) {
+	t.Parallel()
+
+	old := NewInitMessage(
+		NewRawFeatureVector(DataLossProtectOptional),
+		NewRawFeatureVector(
+			GossipQueriesOptional, StaticRemoteKeyRequired,
+		),
+	)
+
+	testCases := []struct {
+		name     string
+		new      *Init
+		breaking []FeatureBit
+	}{
+		{
+			name: "unchanged",
+			new: NewInitMessage(
+				NewRawFeatureVector(),
+				NewRawFeatureVector(
+					DataLossProtectOptional,
+					GossipQueriesOptional,
+					StaticRemoteKeyRequired,
+				),
+			),
+		},
+		{
+			name: "added optional and relaxed required",
+			new: NewInitMessage(
+				NewRawFeatureVector(DataLossProtectOptional),
+				NewRawFeatureVector(
+					GossipQueriesOptional,
+					StaticRemoteKeyOptional,
+					PaymentAddrOptional,
+				),
+			),
+		},
+		{
+			name: "newly required",
+			new: NewInitMessage(
+				NewRawFeatureVector(DataLossProtectOptional),
+				NewRawFeatureVector(
+					GossipQueriesRequired,
+					StaticRemoteKeyRequired,
+					PaymentAddrRequired,
+				),
+			),
+			breaking: []FeatureBit{
+				GossipQueriesRequired, PaymentAddrRequired,
+			},
+		},
+		{
+			name: "dropped",
+			new: NewInitMessage(
+				nil,
+				NewRawFeatureVector(GossipQueriesOptional),
+			),
+			breaking: []FeatureBit{
+				DataLossProtectOptional,
+				StaticRemoteKeyRequired,
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		safe, breaking := testCase.new.CompatibleUpgradeFrom(old)
+		require.Equal(t, testCase.breaking == nil, safe, testCase.name)
+		require.Equal(t, testCase.breaking, breaking, testCase.name)
+	}
+
+	// Without a previous message, any required feature is new.
+	safe, breaking := old.CompatibleUpgradeFrom(nil)
+	require.False(t, safe)
+	require.Equal(t, []FeatureBit{StaticRemoteKeyRequired}, breaking)
+}
@@ -67,6 +67,68 @@ func (a addressType) AddrLen() uint16 {
 	}
 }
 
+// AddrLimits bounds the number of addresses of each type accepted when
+// decoding the addresses of a NodeAnnouncement. A limit of zero disables the
+// check for that type.
+type AddrLimits struct {
+	// MaxIPv4 is the max number of IPv4 addresses.
+	MaxIPv4 int
+
+	// MaxIPv6 is the max number of IPv6 addresses.
+	MaxIPv6 int
+
+	// MaxV2Onion is the max number of v2 onion addresses.
+	MaxV2Onion int
+
+	// MaxV3Onion is the max number of v3 onion addresses.
+	MaxV3Onion int
+}
+
+// limit returns the max number of addresses of the given type, or zero if
+// there's no limit.
+func (l AddrLimits) limit(aType addressType) int {
+	switch aType {
+	case tcp4Addr:
+		return l.MaxIPv4
+	case tcp6Addr:
+		return l.MaxIPv6
+	case v2OnionAddr:
+		return l.MaxV2Onion
+	case v3OnionAddr:
+		return l.MaxV3Onion
+	default:
+		return 0
+	}
+}
+
+// DefaultAddrLimits returns the default per type address limits enforced when
+// decoding a NodeAnnouncement. They're well above the number of addresses any
+// reasonable node advertises, while keeping the work spent on a single
+// NodeAnnouncement bounded.
+func DefaultAddrLimits() AddrLimits {
+	return AddrLimits{
+		MaxIPv4:    16,
+		MaxIPv6:    16,
+		MaxV2Onion: 16,
+		MaxV3Onion: 16,
+	}
+}
+
+// ErrTooManyAddrs is returned when decoding a set of addresses that contains
+// more addresses of a single type than allowed by the AddrLimits in use.
+type ErrTooManyAddrs struct {
+	addrType addressType
+	limit    int
+}
+
+// Error returns a human readable string describing the error.
+//
+// NOTE: implements the error interface.
+func (e ErrTooManyAddrs) Error() string {
+	return fmt.Sprintf("more than %d addresses of type %d", e.limit,
+		e.addrType)
+}
+
 // WriteElement is a one-stop shop to write the big endian representation of
 // any element which is to be serialized for the wire protocol. The passed
 // io.Writer should be backed by an appropriately sized byte slice, or be able
@@ -200,6 +262,9 @@ func WriteElement(w io.Writer, element interface{}) error {
 		if _, err := w.Write(e[:]); err != nil {
 			return err
 		}
+	case WarningData:
+		// Warning data shares the encoding of error data.
+		return WriteElement(w, ErrorData(e))
 	case OpaqueReason:
 		var l [2]byte
 		binary.BigEndian.PutUint16(l[:], uint16(len(e)))
@@ -218,6 +283,10 @@ func WriteElement(w io.Writer, element interface{}) error {
 		if _, err := w.Write(e[:]); err != nil {
 			return err
 		}
+	case ExtraOpaqueData:
+		if _, err := w.Write(e[:]); err != nil {
+			return err
+		}
 	case PkScript:
 		// The largest script we'll accept is a p2wsh which is exactly
 		// 34 bytes long.
@@ -371,31 +440,12 @@ func WriteElement(w io.Writer, element interface{}) error {
 		}
 
 	case []net.Addr:
-		// First, we'll encode all the addresses into an intermediate
-		// buffer. We need to do this in order to compute the total
-		// length of the addresses.
-		var addrBuf bytes.Buffer
-		for _, address := range e {
-			if err := WriteElement(&addrBuf, address); err != nil {
-				return err
-			}
-		}
-
-		// With the addresses fully encoded, we can now write out the
-		// number of bytes needed to encode them.
-		addrLen := addrBuf.Len()
-		if err := WriteElement(w, uint16(addrLen)); err != nil {
+		if err := WriteNetAddrs(w, e, AddrEncodingStrict); err != nil {
 			return err
 		}
-
-		// Finally, we'll write out the raw addresses themselves, but
-		// only if we have any bytes to write.
-		if addrLen > 0 {
-			if _, err := w.Write(addrBuf.Bytes()); err != nil {
-				return err
-			}
-		}
 	case color.RGBA:
+		// The alpha channel isn't part of the encoding, so it's
+		// ignored.
 		if err := WriteElements(w, e.R, e.G, e.B); err != nil {
 			return err
 		}
@@ -437,6 +487,94 @@ func WriteElements(w io.Writer, elements ...interface{}) error {
 	return nil
 }
 
+// AddrEncodingMode determines how WriteNetAddrs handles addresses that can't
+// be encoded.
+type AddrEncodingMode uint8
+
+const (
+	// AddrEncodingStrict fails the whole write as soon as an address
+	// can't be encoded.
+	AddrEncodingStrict AddrEncodingMode = iota
+
+	// AddrEncodingSkipInvalid skips any address that can't be encoded,
+	// writing out the remaining ones. The skipped addresses are reported
+	// through ErrSkippedAddrs.
+	AddrEncodingSkipInvalid
+)
+
+// ErrSkippedAddrs is returned by WriteNetAddrs in AddrEncodingSkipInvalid
+// mode when some of the addresses couldn't be encoded. The remaining
+// addresses were written successfully.
+type ErrSkippedAddrs struct {
+	addrs []net.Addr
+}
+
+// Addrs returns the addresses that were skipped.
+func (e ErrSkippedAddrs) Addrs() []net.Addr {
+	return e.addrs
+}
+
+// Error returns a human readable string describing the error.
+//
+// NOTE: implements the error interface.
+func (e ErrSkippedAddrs) Error() string {
+	return fmt.Sprintf("skipped %d unencodable addresses: %v",
+		len(e.addrs), e.addrs)
+}
+
+// WriteNetAddrs writes the set of addresses to w, prefixed by the total length
+// of their encoding, as done within a NodeAnnouncement. The mode determines
+// whether an address that can't be encoded fails the whole write, or is
+// skipped and reported through ErrSkippedAddrs once the remaining addresses
+// have been written.
+func WriteNetAddrs(w io.Writer, addrs []net.Addr,
+	mode AddrEncodingMode) error {
+
+	// First, we'll encode all the addresses into an intermediate buffer.
+	// We need to do this in order to compute the total length of the
+	// addresses. Each address is encoded on its own first, so that one
+	// failing halfway through doesn't leave a partial encoding behind.
+	var (
+		addrBuf bytes.Buffer
+		skipped []net.Addr
+	)
+	for _, address := range addrs {
+		var b bytes.Buffer
+		if err := WriteElement(&b, address); err != nil {
+			if mode == AddrEncodingStrict {
+				return err
+			}
+
+			skipped = append(skipped, address)
+			continue
+		}
+
+		addrBuf.Write(b.Bytes())
+	}
+
+	// With the addresses fully encoded, we can now write out the number
+	// of bytes needed to encode them.
+	addrLen := addrBuf.Len()
+	if err := WriteElement(w, uint16(addrLen)); err != nil {
+		return err
+	}
+
+	// Next, we'll write out the raw addresses themselves, but only if we
+	// have any bytes to write.
+	if addrLen > 0 {
+		if _, err := w.Write(addrBuf.Bytes()); err != nil {
+			return err
+		}
+	}
+
+	// Finally, report any addresses we had to skip.
+	if len(skipped) > 0 {
+		return ErrSkippedAddrs{skipped}
+	}
+
+	return nil
+}
+
 // ReadElement is a one-stop utility function to deserialize any datastructure
 // encoded using the serialization format of lnwire.
 func ReadElement(r io.Reader, element interface{}) error {
@@ -551,6 +689,13 @@ func ReadElement(r io.Reader, element interface{}) error {
 		}
 		numSigs := binary.BigEndian.Uint16(l[:])
 
+		// Reject an absurd number of signatures before allocating
+		// space for them.
+		if numSigs > MaxHTLCSigs {
+			return fmt.Errorf("number of htlc signatures %d "+
+				"exceeds maximum of %d", numSigs, MaxHTLCSigs)
+		}
+
 		var sigs []Sig
 		if numSigs > 0 {
 			sigs = make([]Sig, numSigs)
@@ -579,16 +724,14 @@ func ReadElement(r io.Reader, element interface{}) error {
 			return err
 		}
 	case *ErrorData:
-		var l [2]byte
-		if _, err := io.ReadFull(r, l[:]); err != nil {
-			return err
-		}
-		errorLen := binary.BigEndian.Uint16(l[:])
-
-		*e = ErrorData(make([]byte, errorLen))
-		if _, err := io.ReadFull(r, *e); err != nil {
+		data, err := readErrorData(r, DefaultMaxErrorDataLen)
+		if err != nil {
 			return err
 		}
+		*e = data
+	case *WarningData:
+		// Warning data shares the encoding of error data.
+		return ReadElement(r, (*ErrorData)(e))
 	case *PingPayload:
 		var l [2]byte
 		if _, err := io.ReadFull(r, l[:]); err != nil {
@@ -678,126 +821,10 @@ func ReadElement(r io.Reader, element interface{}) error {
 		}
 
 	case *[]net.Addr:
-		// First, we'll read the number of total bytes that have been
-		// used to encode the set of addresses.
-		var numAddrsBytes [2]byte
-		if _, err = io.ReadFull(r, numAddrsBytes[:]); err != nil {
-			return err
-		}
-		addrsLen := binary.BigEndian.Uint16(numAddrsBytes[:])
-
-		// With the number of addresses, read, we'll now pull in the
-		// buffer of the encoded addresses into memory.
-		addrs := make([]byte, addrsLen)
-		if _, err := io.ReadFull(r, addrs[:]); err != nil {
+		addresses, err := readAddrs(r, DefaultAddrLimits())
+		if err != nil {
 			return err
 		}
-		addrBuf := bytes.NewReader(addrs)
-
-		// Finally, we'll parse the remaining address payload in
-		// series, using the first byte to denote how to decode the
-		// address itself.
-		var (
-			addresses     []net.Addr
-			addrBytesRead uint16
-		)
-
-		for addrBytesRead < addrsLen {
-			var descriptor [1]byte
-			if _, err = io.ReadFull(addrBuf, descriptor[:]); err != nil {
-				return err
-			}
-
-			addrBytesRead++
-
-			var address net.Addr
-			switch aType := addressType(descriptor[0]); aType {
-			case noAddr:
-				addrBytesRead += aType.AddrLen()
-				continue
-
-			case tcp4Addr:
-				var ip [4]byte
-				if _, err := io.ReadFull(addrBuf, ip[:]); err != nil {
-					return err
-				}
-
-				var port [2]byte
-				if _, err := io.ReadFull(addrBuf, port[:]); err != nil {
-					return err
-				}
-
-				address = &net.TCPAddr{
-					IP:   net.IP(ip[:]),
-					Port: int(binary.BigEndian.Uint16(port[:])),
-				}
-				addrBytesRead += aType.AddrLen()
-
-			case tcp6Addr:
-				var ip [16]byte
-				if _, err := io.ReadFull(addrBuf, ip[:]); err != nil {
-					return err
-				}
-
-				var port [2]byte
-				if _, err := io.ReadFull(addrBuf, port[:]); err != nil {
-					return err
-				}
-
-				address = &net.TCPAddr{
-					IP:   net.IP(ip[:]),
-					Port: int(binary.BigEndian.Uint16(port[:])),
-				}
-				addrBytesRead += aType.AddrLen()
-
-			case v2OnionAddr:
-				var h [tor.V2DecodedLen]byte
-				if _, err := io.ReadFull(addrBuf, h[:]); err != nil {
-					return err
-				}
-
-				var p [2]byte
-				if _, err := io.ReadFull(addrBuf, p[:]); err != nil {
-					return err
-				}
-
-				onionService := tor.Base32Encoding.EncodeToString(h[:])
-				onionService += tor.OnionSuffix
-				port := int(binary.BigEndian.Uint16(p[:]))
-
-				address = &tor.OnionAddr{
-					OnionService: onionService,
-					Port:         port,
-				}
-				addrBytesRead += aType.AddrLen()
-
-			case v3OnionAddr:
-				var h [tor.V3DecodedLen]byte
-				if _, err := io.ReadFull(addrBuf, h[:]); err != nil {
-					return err
-				}
-
-				var p [2]byte
-				if _, err := io.ReadFull(addrBuf, p[:]); err != nil {
-					return err
-				}
-
-				onionService := tor.Base32Encoding.EncodeToString(h[:])
-				onionService += tor.OnionSuffix
-				port := int(binary.BigEndian.Uint16(p[:]))
-
-				address = &tor.OnionAddr{
-					OnionService: onionService,
-					Port:         port,
-				}
-				addrBytesRead += aType.AddrLen()
-
-			default:
-				return &ErrUnknownAddrType{aType}
-			}
-
-			addresses = append(addresses, address)
-		}
 
 		*e = addresses
 	case *color.RGBA:
@@ -809,6 +836,10 @@ func ReadElement(r io.Reader, element interface{}) error {
 		if err != nil {
 			return err
 		}
+
+		// As the alpha channel isn't encoded, we'll normalize it so a
+		// decoded color doesn't retain whatever it was set to before.
+		*e = NormalizeColor(*e)
 	case *DeliveryAddress:
 		var addrLen [2]byte
 		if _, err = io.ReadFull(r, addrLen[:]); err != nil {
@@ -831,6 +862,55 @@ func ReadElement(r io.Reader, element interface{}) error {
 	return nil
 }
 
+// readAddrs reads a set of addresses, failing with ErrTooManyAddrs as soon as
+// it contains more addresses of a single type than allowed by limits.
+func readAddrs(r io.Reader, limits AddrLimits) ([]net.Addr, error) {
+	// First, we'll read the number of total bytes that have been used to
+	// encode the set of addresses.
+	var numAddrsBytes [2]byte
+	if _, err := io.ReadFull(r, numAddrsBytes[:]); err != nil {
+		return nil, err
+	}
+	addrsLen := binary.BigEndian.Uint16(numAddrsBytes[:])
+
+	// With the number of addresses, read, we'll now pull in the buffer of
+	// the encoded addresses into memory.
+	addrs := make([]byte, addrsLen)
+	if _, err := io.ReadFull(r, addrs[:]); err != nil {
+		return nil, err
+	}
+
+	// Finally, we'll parse the full set of addresses, bailing out as soon
+	// as there are more of a type than we allow.
+	var (
+		addresses []net.Addr
+		counts    = make(map[addressType]int)
+		limitErr  error
+	)
+	err := decodeAddrs(addrs, func(aType addressType, addr net.Addr) bool {
+		counts[aType]++
+		limit := limits.limit(aType)
+		if limit > 0 && counts[aType] > limit {
+			limitErr = ErrTooManyAddrs{
+				addrType: aType,
+				limit:    limit,
+			}
+			return false
+		}
+
+		addresses = append(addresses, addr)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if limitErr != nil {
+		return nil, limitErr
+	}
+
+	return addresses, nil
+}
+
 // ReadElements deserializes a variable number of elements into the passed
 // io.Reader, with each element being deserialized according to the ReadElement
 // function.
@@ -843,3 +923,128 @@ func ReadElements(r io.Reader, elements ...interface{}) error {
 	}
 	return nil
 }
+
+// DecodeAddrs parses the raw encoding of a set of addresses, as found in a
+// NodeAnnouncement without its length prefix, handing each address to cb in
+// the order they're encoded. Decoding stops as soon as cb returns false, so
+// callers only interested in a few of the addresses avoid parsing the rest.
+func DecodeAddrs(rawAddrs []byte, cb func(net.Addr) bool) error {
+	return decodeAddrs(rawAddrs, func(_ addressType, addr net.Addr) bool {
+		return cb(addr)
+	})
+}
+
+// decodeAddrs is DecodeAddrs, but also hands the type of each address to cb.
+func decodeAddrs(rawAddrs []byte,
+	cb func(addressType, net.Addr) bool) error {
+
+	addrBuf := bytes.NewReader(rawAddrs)
+
+	// We'll parse the address payload in series, using the first byte to
+	// denote how to decode the address itself.
+	var addrBytesRead int
+	for addrBytesRead < len(rawAddrs) {
+		var descriptor [1]byte
+		_, err := io.ReadFull(addrBuf, descriptor[:])
+		if err != nil {
+			return err
+		}
+
+		addrBytesRead++
+
+		var address net.Addr
+		aType := addressType(descriptor[0])
+		switch aType {
+		case noAddr:
+			addrBytesRead += int(aType.AddrLen())
+			continue
+
+		case tcp4Addr:
+			var ip [4]byte
+			if _, err := io.ReadFull(addrBuf, ip[:]); err != nil {
+				return err
+			}
+
+			var port [2]byte
+			if _, err := io.ReadFull(addrBuf, port[:]); err != nil {
+				return err
+			}
+
+			address = &net.TCPAddr{
+				IP:   net.IP(ip[:]),
+				Port: int(binary.BigEndian.Uint16(port[:])),
+			}
+			addrBytesRead += int(aType.AddrLen())
+
+		case tcp6Addr:
+			var ip [16]byte
+			if _, err := io.ReadFull(addrBuf, ip[:]); err != nil {
+				return err
+			}
+
+			var port [2]byte
+			if _, err := io.ReadFull(addrBuf, port[:]); err != nil {
+				return err
+			}
+
+			address = &net.TCPAddr{
+				IP:   net.IP(ip[:]),
+				Port: int(binary.BigEndian.Uint16(port[:])),
+			}
+			addrBytesRead += int(aType.AddrLen())
+
+		case v2OnionAddr:
+			var h [tor.V2DecodedLen]byte
+			if _, err := io.ReadFull(addrBuf, h[:]); err != nil {
+				return err
+			}
+
+			var p [2]byte
+			if _, err := io.ReadFull(addrBuf, p[:]); err != nil {
+				return err
+			}
+
+			onionService := tor.Base32Encoding.EncodeToString(h[:])
+			onionService += tor.OnionSuffix
+			port := int(binary.BigEndian.Uint16(p[:]))
+
+			address = &tor.OnionAddr{
+				OnionService: onionService,
+				Port:         port,
+			}
+			addrBytesRead += int(aType.AddrLen())
+
+		case v3OnionAddr:
+			var h [tor.V3DecodedLen]byte
+			if _, err := io.ReadFull(addrBuf, h[:]); err != nil {
+				return err
+			}
+
+			var p [2]byte
+			if _, err := io.ReadFull(addrBuf, p[:]); err != nil {
+				return err
+			}
+
+			onionService := tor.Base32Encoding.EncodeToString(h[:])
+			onionService += tor.OnionSuffix
+			port := int(binary.BigEndian.Uint16(p[:]))
+
+			address = &tor.OnionAddr{
+				OnionService: onionService,
+				Port:         port,
+			}
+			addrBytesRead += int(aType.AddrLen())
+
+		default:
+			return &ErrUnknownAddrType{aType}
+		}
+
+		// Hand the address off to the caller, stopping early if
+		// they don't need any more of them.
+		if !cb(aType, address) {
+			return nil
+		}
+	}
+
+	return nil
+}
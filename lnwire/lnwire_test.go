@@ -264,8 +264,10 @@ func TestLightningWireProtocol(t *testing.T) {
 	mainScenario := func(msg Message) bool {
 		// Give a new message, we'll serialize the message into a new
 		// bytes buffer.
+		const pver = ProtocolVersionLatest
+
 		var b bytes.Buffer
-		if _, err := WriteMessage(&b, msg, 0); err != nil {
+		if _, err := WriteMessage(&b, msg, pver); err != nil {
 			t.Fatalf("unable to write msg: %v", err)
 			return false
 		}
@@ -274,15 +276,15 @@ func TestLightningWireProtocol(t *testing.T) {
 		// the 2 bytes for the message type) is _below_ the specified
 		// max payload size for this message.
 		payloadLen := uint32(b.Len()) - 2
-		if payloadLen > msg.MaxPayloadLength(0) {
+		if payloadLen > msg.MaxPayloadLength(pver) {
 			t.Fatalf("msg payload constraint violated: %v > %v",
-				payloadLen, msg.MaxPayloadLength(0))
+				payloadLen, msg.MaxPayloadLength(pver))
 			return false
 		}
 
 		// Finally, we'll deserialize the message from the written
 		// buffer, and finally assert that the messages are equal.
-		newMsg, err := ReadMessage(&b, 0)
+		newMsg, err := ReadMessage(&b, pver)
 		if err != nil {
 			t.Fatalf("unable to read msg: %v", err)
 			return false
@@ -534,7 +536,7 @@ func TestLightningWireProtocol(t *testing.T) {
 			// Only create the slice if there will be any signatures
 			// in it to prevent false positive test failures due to
 			// an empty slice versus a nil slice.
-			numSigs := uint16(r.Int31n(1020))
+			numSigs := uint16(r.Int31n(MaxHTLCSigs + 1))
 			if numSigs > 0 {
 				req.HtlcSigs = make([]Sig, numSigs)
 			}
@@ -710,6 +712,24 @@ func TestLightningWireProtocol(t *testing.T) {
 				return
 			}
 
+			// Half of the time, we'll advertise an inbound fee
+			// rather than random opaque bytes, to ensure it
+			// survives the round trip.
+			if r.Int31n(2) == 0 {
+				err := req.SetInboundFee(InboundFee{
+					BaseFee: r.Int31() - r.Int31(),
+					FeeRate: r.Int31() - r.Int31(),
+				})
+				if err != nil {
+					t.Fatalf("unable to set inbound fee: %v",
+						err)
+					return
+				}
+
+				v[0] = reflect.ValueOf(req)
+				return
+			}
+
 			numExtraBytes := r.Int31n(1000)
 			if numExtraBytes > 0 {
 				req.ExtraOpaqueData = make([]byte, numExtraBytes)
@@ -759,6 +779,34 @@ func TestLightningWireProtocol(t *testing.T) {
 
 			v[0] = reflect.ValueOf(req)
 		},
+		MsgWarning: func(v []reflect.Value, r *rand.Rand) {
+			req := Warning{
+				Data: make(WarningData, r.Intn(100)),
+			}
+
+			if _, err := r.Read(req.ChanID[:]); err != nil {
+				t.Fatalf("unable to generate chan id: %v", err)
+				return
+			}
+
+			if _, err := r.Read(req.Data); err != nil {
+				t.Fatalf("unable to generate data: %v", err)
+				return
+			}
+
+			numExtraBytes := r.Int31n(1000)
+			if numExtraBytes > 0 {
+				req.ExtraOpaqueData = make([]byte, numExtraBytes)
+				_, err := r.Read(req.ExtraOpaqueData[:])
+				if err != nil {
+					t.Fatalf("unable to generate opaque "+
+						"bytes: %v", err)
+					return
+				}
+			}
+
+			v[0] = reflect.ValueOf(req)
+		},
 		MsgChannelReestablish: func(v []reflect.Value, r *rand.Rand) {
 			req := ChannelReestablish{
 				NextLocalCommitHeight:  uint64(r.Int63()),
@@ -862,6 +910,12 @@ func TestLightningWireProtocol(t *testing.T) {
 				return mainScenario(&m)
 			},
 		},
+		{
+			msgType: MsgWarning,
+			scenario: func(m Warning) bool {
+				return mainScenario(&m)
+			},
+		},
 		{
 			msgType: MsgPing,
 			scenario: func(m Ping) bool {
@@ -1044,3 +1098,147 @@ func TestLightningWireProtocol(t *testing.T) {
 func init() {
 	rand.Seed(time.Now().Unix())
 }
+
+// TestReadMessageSizeLimit asserts that messages exceeding the size limit of
+// their type are rejected, while those within it are read successfully.
+func TestReadMessageSizeLimit(t *testing.T) {
+	t.Parallel()
+
+	shutdown := NewShutdown(ChannelID{}, DeliveryAddress{0x00, 0x14})
+
+	var b bytes.Buffer
+	if _, err := WriteMessage(&b, shutdown, 0); err != nil {
+		t.Fatalf("unable to write msg: %v", err)
+	}
+	payloadLen := b.Len() - 2
+
+	if err := CheckMsgSize(MsgShutdown, payloadLen); err != nil {
+		t.Fatalf("expected shutdown to be within limit: %v", err)
+	}
+	if _, err := ReadMessage(&b, 0); err != nil {
+		t.Fatalf("unable to read msg: %v", err)
+	}
+
+	// A shutdown padded well beyond its natural size should be rejected.
+	bloatedLen := int(MaxMsgSize(MsgShutdown)) + 1
+	err := CheckMsgSize(MsgShutdown, bloatedLen)
+	if _, ok := err.(ErrMsgTooLarge); !ok {
+		t.Fatalf("expected ErrMsgTooLarge, got: %v", err)
+	}
+
+	// Reading the bloated shutdown should fail as well, even though
+	// decoding its fixed fields doesn't reach the trailing padding.
+	b.Reset()
+	if _, err := WriteMessage(&b, shutdown, 0); err != nil {
+		t.Fatalf("unable to write msg: %v", err)
+	}
+	b.Write(make([]byte, bloatedLen-payloadLen))
+	_, err = ReadFramedMessage(b.Bytes(), 0)
+	if _, ok := err.(ErrMsgTooLarge); !ok {
+		t.Fatalf("expected ErrMsgTooLarge, got: %v", err)
+	}
+
+	// A message whose trailing extra data runs past the limit should be
+	// rejected rather than truncated, regardless of the reader it's read
+	// from.
+	extraLen := int(MaxMsgSize(MsgAnnounceSignatures))
+	sigs := &AnnounceSignatures{
+		NodeSignature:    Sig{},
+		BitcoinSignature: Sig{},
+	}
+	b.Reset()
+	if _, err := WriteMessage(&b, sigs, 0); err != nil {
+		t.Fatalf("unable to write msg: %v", err)
+	}
+	fixedLen := b.Len() - 2
+	b.Write(make([]byte, extraLen-fixedLen+1))
+	_, err = ReadMessage(&b, 0)
+	if _, ok := err.(ErrMsgTooLarge); !ok {
+		t.Fatalf("expected ErrMsgTooLarge, got: %v", err)
+	}
+
+	// Right at the limit, the message is accepted along with all of its
+	// extra data.
+	b.Reset()
+	if _, err := WriteMessage(&b, sigs, 0); err != nil {
+		t.Fatalf("unable to write msg: %v", err)
+	}
+	b.Write(make([]byte, extraLen-fixedLen))
+	msg, err := ReadMessage(&b, 0)
+	if err != nil {
+		t.Fatalf("unable to read msg: %v", err)
+	}
+	extra := msg.(*AnnounceSignatures).ExtraOpaqueData
+	if len(extra) != extraLen-fixedLen {
+		t.Fatalf("expected %d bytes of extra data, got %d",
+			extraLen-fixedLen, len(extra))
+	}
+
+	// Message types without an explicit limit should be bound by the
+	// maximum message payload.
+	if MaxMsgSize(MsgChannelUpdate) != MaxMessagePayload {
+		t.Fatalf("expected default limit of %d, got %d",
+			MaxMessagePayload, MaxMsgSize(MsgChannelUpdate))
+	}
+}
+
+// TestProtocolVersionGate asserts that message types and fields introduced in
+// a later protocol version are only written and read when that version is in
+// use.
+func TestProtocolVersionGate(t *testing.T) {
+	t.Parallel()
+
+	warning := NewWarning()
+	warning.Data = WarningData("slow down")
+	if err := warning.SetRetryDelay(time.Minute); err != nil {
+		t.Fatalf("unable to set retry delay: %v", err)
+	}
+
+	// The warning message type isn't part of the legacy protocol, so we
+	// should refuse to write it.
+	var b bytes.Buffer
+	_, err := WriteMessage(&b, warning, ProtocolVersionLegacy)
+	if _, ok := err.(ErrUnsupportedProtocolVersion); !ok {
+		t.Fatalf("expected ErrUnsupportedProtocolVersion, got: %v",
+			err)
+	}
+
+	// Nor should we read it with the legacy protocol version.
+	_, err = WriteMessage(&b, warning, ProtocolVersionWarning)
+	if err != nil {
+		t.Fatalf("unable to write msg: %v", err)
+	}
+	_, err = ReadMessage(bytes.NewReader(b.Bytes()), ProtocolVersionLegacy)
+	if _, ok := err.(ErrUnsupportedProtocolVersion); !ok {
+		t.Fatalf("expected ErrUnsupportedProtocolVersion, got: %v",
+			err)
+	}
+
+	// Written with a version predating the retry delay, the field should
+	// be dropped from the encoding.
+	newMsg, err := ReadMessage(&b, ProtocolVersionLatest)
+	if err != nil {
+		t.Fatalf("unable to read msg: %v", err)
+	}
+	_, ok, err := newMsg.(*Warning).SuggestedRetryDelay()
+	if err != nil || ok {
+		t.Fatalf("retry delay shouldn't be encoded before version %d",
+			ProtocolVersionWarningRetry)
+	}
+
+	// With the latest version, the retry delay should round trip.
+	b.Reset()
+	_, err = WriteMessage(&b, warning, ProtocolVersionLatest)
+	if err != nil {
+		t.Fatalf("unable to write msg: %v", err)
+	}
+	newMsg, err = ReadMessage(&b, ProtocolVersionLatest)
+	if err != nil {
+		t.Fatalf("unable to read msg: %v", err)
+	}
+	delay, ok, err := newMsg.(*Warning).SuggestedRetryDelay()
+	if err != nil || !ok || delay != time.Minute {
+		t.Fatalf("expected retry delay of %v, got %v", time.Minute,
+			delay)
+	}
+}
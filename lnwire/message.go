@@ -8,14 +8,44 @@ package lnwire
 import (
 	"bytes"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
+	"net"
+	"sync"
 )
 
 // MaxMessagePayload is the maximum bytes a message can be regardless of other
 // individual limits imposed by messages themselves.
 const MaxMessagePayload = 65535 // 65KB
 
+// msgExtensionAllowance is the number of bytes we'll tolerate on top of the
+// natural size of a message with a bounded set of fields. This leaves room for
+// peers to append TLV extensions we don't know of yet.
+const msgExtensionAllowance = 1024
+
+// The protocol versions that can be passed to WriteMessage and ReadMessage.
+// Each version is a superset of the previous one: a message type or field
+// introduced in a version is only encoded and decoded when the negotiated
+// version is at least that version, so older encodings stay byte-for-byte
+// identical.
+const (
+	// ProtocolVersionLegacy is the version covering all messages and
+	// fields of the original wire protocol.
+	ProtocolVersionLegacy uint32 = 0
+
+	// ProtocolVersionWarning introduces the Warning message.
+	ProtocolVersionWarning uint32 = 1
+
+	// ProtocolVersionWarningRetry introduces the optional retry delay
+	// record within the Warning message.
+	ProtocolVersionWarningRetry uint32 = 2
+
+	// ProtocolVersionLatest is the most recent protocol version we know
+	// of.
+	ProtocolVersionLatest = ProtocolVersionWarningRetry
+)
+
 // MessageType is the unique 2 byte big-endian integer that indicates the type
 // of message on the wire. All messages have a very simple header which
 // consists simply of 2-byte message type. We omit a length field, and checksum
@@ -26,6 +56,7 @@ type MessageType uint16
 // The currently defined message types within this current version of the
 // Lightning protocol.
 const (
+	MsgWarning                 MessageType = 1
 	MsgInit                    MessageType = 16
 	MsgError                               = 17
 	MsgPing                                = 18
@@ -59,6 +90,8 @@ const (
 // String return the string representation of message type.
 func (t MessageType) String() string {
 	switch t {
+	case MsgWarning:
+		return "Warning"
 	case MsgInit:
 		return "Init"
 	case MsgOpenChannel:
@@ -120,6 +153,318 @@ func (t MessageType) String() string {
 	}
 }
 
+// MsgSizeLimits houses the maximum payload size we'll accept when reading a
+// message of a given type from the wire. Messages composed solely of fixed
+// size fields never need to be near MaxMessagePayload, so a peer sending a
+// bloated one is either buggy or attempting to waste our bandwidth. Any
+// message type not found within this map is limited to MaxMessagePayload.
+var MsgSizeLimits = map[MessageType]uint32{
+	// 32 + 32 + 2 + 64
+	MsgFundingCreated: 130 + msgExtensionAllowance,
+
+	// 32 + 64
+	MsgFundingSigned: 96 + msgExtensionAllowance,
+
+	// 32 + 33
+	MsgFundingLocked: 65 + msgExtensionAllowance,
+
+	// 32 + 2 + 42
+	MsgShutdown: 76 + msgExtensionAllowance,
+
+	// 32 + 8 + 64
+	MsgClosingSigned: 104 + msgExtensionAllowance,
+
+	// 32 + 8 + 32
+	MsgUpdateFulfillHTLC: 72 + msgExtensionAllowance,
+
+	// 32 + 32 + 33
+	MsgRevokeAndAck: 97 + msgExtensionAllowance,
+
+	// 32 + 4
+	MsgUpdateFee: 36 + msgExtensionAllowance,
+
+	// 32 + 8 + 32 + 2
+	MsgUpdateFailMalformedHTLC: 74 + msgExtensionAllowance,
+
+	// 32 + 8 + 8 + 32 + 33
+	MsgChannelReestablish: 113 + msgExtensionAllowance,
+
+	// 32 + 1
+	MsgReplyShortChanIDsEnd: 33 + msgExtensionAllowance,
+
+	// 32 + 4 + 4
+	MsgGossipTimestampRange: 40 + msgExtensionAllowance,
+}
+
+// msgMinProtocolVersion maps message types that were introduced after the
+// legacy protocol version to the version they were introduced in. Message
+// types not present in the map are understood by all versions.
+var msgMinProtocolVersion = map[MessageType]uint32{
+	MsgWarning: ProtocolVersionWarning,
+}
+
+// MessageCategory classifies messages by the subsystem responsible for
+// handling them.
+type MessageCategory uint8
+
+const (
+	// CategoryUnknown is the category of message types we don't know of.
+	CategoryUnknown MessageCategory = iota
+
+	// CategoryControl covers messages concerning the connection with the
+	// peer itself, such as Init, Ping and Error.
+	CategoryControl
+
+	// CategoryChannel covers messages bound to a particular channel, from
+	// its funding through its updates to its closure.
+	CategoryChannel
+
+	// CategoryGossip covers messages that announce the channel graph and
+	// are routed through the gossip broadcast path.
+	CategoryGossip
+
+	// CategoryGossipQuery covers messages used to query and synchronize
+	// the channel graph with a peer.
+	CategoryGossipQuery
+)
+
+// String returns a human readable description of the MessageCategory.
+func (c MessageCategory) String() string {
+	switch c {
+	case CategoryControl:
+		return "Control"
+
+	case CategoryChannel:
+		return "Channel"
+
+	case CategoryGossip:
+		return "Gossip"
+
+	case CategoryGossipQuery:
+		return "GossipQuery"
+
+	default:
+		return "Unknown"
+	}
+}
+
+// msgCategories maps every known message type to its category. New message
+// types must be added here so that dispatch logic handles them correctly.
+var msgCategories = map[MessageType]MessageCategory{
+	MsgWarning: CategoryControl,
+	MsgInit:    CategoryControl,
+	MsgError:   CategoryControl,
+	MsgPing:    CategoryControl,
+	MsgPong:    CategoryControl,
+
+	MsgOpenChannel:             CategoryChannel,
+	MsgAcceptChannel:           CategoryChannel,
+	MsgFundingCreated:          CategoryChannel,
+	MsgFundingSigned:           CategoryChannel,
+	MsgFundingLocked:           CategoryChannel,
+	MsgShutdown:                CategoryChannel,
+	MsgClosingSigned:           CategoryChannel,
+	MsgUpdateAddHTLC:           CategoryChannel,
+	MsgUpdateFulfillHTLC:       CategoryChannel,
+	MsgUpdateFailHTLC:          CategoryChannel,
+	MsgCommitSig:               CategoryChannel,
+	MsgRevokeAndAck:            CategoryChannel,
+	MsgUpdateFee:               CategoryChannel,
+	MsgUpdateFailMalformedHTLC: CategoryChannel,
+	MsgChannelReestablish:      CategoryChannel,
+
+	MsgChannelAnnouncement: CategoryGossip,
+	MsgNodeAnnouncement:    CategoryGossip,
+	MsgChannelUpdate:       CategoryGossip,
+	MsgAnnounceSignatures:  CategoryGossip,
+
+	MsgQueryShortChanIDs:    CategoryGossipQuery,
+	MsgReplyShortChanIDsEnd: CategoryGossipQuery,
+	MsgQueryChannelRange:    CategoryGossipQuery,
+	MsgReplyChannelRange:    CategoryGossipQuery,
+	MsgGossipTimestampRange: CategoryGossipQuery,
+}
+
+// Category returns the category of the message type, or CategoryUnknown if
+// the type isn't known.
+func (t MessageType) Category() MessageCategory {
+	return msgCategories[t]
+}
+
+// IsGossipMessage returns true if the message announces the channel graph and
+// should be routed through the gossip broadcast path.
+func IsGossipMessage(msg Message) bool {
+	return msg.MsgType().Category() == CategoryGossip
+}
+
+// IsChannelMessage returns true if the message is bound to a particular
+// channel.
+func IsChannelMessage(msg Message) bool {
+	return msg.MsgType().Category() == CategoryChannel
+}
+
+// CheckProtocolVersion returns ErrUnsupportedProtocolVersion if the message
+// type was introduced in a later protocol version than pver.
+func CheckProtocolVersion(msgType MessageType, pver uint32) error {
+	minVersion, ok := msgMinProtocolVersion[msgType]
+	if ok && pver < minVersion {
+		return ErrUnsupportedProtocolVersion{msgType, pver, minVersion}
+	}
+
+	return nil
+}
+
+// ErrUnsupportedProtocolVersion is returned when writing or reading a message
+// whose type isn't part of the protocol version in use.
+type ErrUnsupportedProtocolVersion struct {
+	msgType    MessageType
+	pver       uint32
+	minVersion uint32
+}
+
+// Error returns a human readable string describing the error.
+//
+// NOTE: implements the error interface.
+func (e ErrUnsupportedProtocolVersion) Error() string {
+	return fmt.Sprintf("message type %v requires protocol version %d, "+
+		"but version %d is in use", e.msgType, e.minVersion, e.pver)
+}
+
+// MaxMsgSize returns the maximum payload size we'll accept when reading a
+// message of the given type from the wire.
+func MaxMsgSize(msgType MessageType) uint32 {
+	if limit, ok := MsgSizeLimits[msgType]; ok {
+		return limit
+	}
+
+	return MaxMessagePayload
+}
+
+// CheckMsgSize returns ErrMsgTooLarge if a payload of the given length exceeds
+// the size limit of the message type. Callers that know the length of a framed
+// message should apply it before decoding the message, as decoding alone won't
+// catch trailing bytes that a message's fixed fields don't consume.
+func CheckMsgSize(msgType MessageType, payloadLen int) error {
+	limit := MaxMsgSize(msgType)
+	if payloadLen > int(limit) {
+		return ErrMsgTooLarge{msgType, limit}
+	}
+
+	return nil
+}
+
+// ErrMsgTooLarge is returned when reading a message whose payload exceeds the
+// size limit of its type.
+type ErrMsgTooLarge struct {
+	msgType MessageType
+	limit   uint32
+}
+
+// Error returns a human readable string describing the error.
+//
+// NOTE: implements the error interface.
+func (e ErrMsgTooLarge) Error() string {
+	return fmt.Sprintf("payload of message type %v exceeds limit of %d "+
+		"bytes", e.msgType, e.limit)
+}
+
+// PartialMessageError is returned by ReadMessage when the type of a message was
+// read, but the reader ran out of bytes before the rest of the message could be
+// decoded, e.g. because the peer disconnected mid-message.
+type PartialMessageError struct {
+	// Type is the type of the truncated message.
+	Type MessageType
+
+	// BytesRead is the number of bytes of the message that were read
+	// before it got truncated, including its type.
+	BytesRead int
+
+	// err is the error returned while decoding the message.
+	err error
+}
+
+// Error returns a human readable string describing the error.
+//
+// NOTE: implements the error interface.
+func (e PartialMessageError) Error() string {
+	return fmt.Sprintf("message of type %v truncated after %d bytes: %v",
+		e.Type, e.BytesRead, e.err)
+}
+
+// Unwrap returns the error returned while decoding the message, allowing
+// callers to still match against io.EOF or io.ErrUnexpectedEOF.
+func (e PartialMessageError) Unwrap() error {
+	return e.err
+}
+
+// sizeLimitedReader wraps an io.Reader, ending the stream once the size limit
+// of the message being decoded has been read, much like io.LimitReader. This
+// bounds the number of bytes a message can make us read. Should the message
+// attempt to read past the limit, a single byte is probed from the underlying
+// reader to tell whether the message actually exceeds it. That byte is only
+// consumed if the message is to be rejected as too large.
+type sizeLimitedReader struct {
+	r         io.Reader
+	limit     uint32
+	remaining int64
+
+	// exhausted is set once the underlying reader was found to hold more
+	// bytes than the limit allows.
+	exhausted bool
+}
+
+// newSizeLimitedReader returns a reader that enforces the size limit of the
+// given message type on r.
+func newSizeLimitedReader(r io.Reader, msgType MessageType) *sizeLimitedReader {
+	limit := MaxMsgSize(msgType)
+	return &sizeLimitedReader{
+		r:         r,
+		limit:     limit,
+		remaining: int64(limit),
+	}
+}
+
+// Read reads up to len(p) bytes from the underlying reader without exceeding
+// the size limit, returning io.EOF once it's been reached.
+//
+// NOTE: implements the io.Reader interface.
+func (l *sizeLimitedReader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	if l.exhausted {
+		return 0, io.EOF
+	}
+
+	// Once the limit has been reached, the message may only be read
+	// further if the underlying reader has nothing left to give us.
+	// Otherwise, the message is larger than we allow.
+	if l.remaining <= 0 {
+		var b [1]byte
+		n, err := l.r.Read(b[:])
+		if n > 0 {
+			l.exhausted = true
+			return 0, io.EOF
+		}
+
+		return 0, err
+	}
+
+	if int64(len(p)) > l.remaining {
+		p = p[:l.remaining]
+	}
+	n, err := l.r.Read(p)
+	l.remaining -= int64(n)
+
+	return n, err
+}
+
+// bytesRead returns the number of bytes read through the reader so far.
+func (l *sizeLimitedReader) bytesRead() int {
+	return int(int64(l.limit) - l.remaining)
+}
+
 // UnknownMessage is an implementation of the error interface that allows the
 // creation of an error in response to an unknown message.
 type UnknownMessage struct {
@@ -154,12 +499,28 @@ type Message interface {
 	MaxPayloadLength(uint32) uint32
 }
 
+// SizedMessage is a Message that can cheaply compute the length of its encoded
+// payload, which allows WriteMessage to check the size of the message before
+// encoding it, and to encode it into a buffer of exactly the right size.
+type SizedMessage interface {
+	Message
+
+	// SerializedBodyLen returns the length of the payload the message
+	// encodes to for the given protocol version. False is returned if the
+	// length can only be known by encoding the message, e.g. because its
+	// payload is compressed, in which case WriteMessage can only check
+	// the size of the message once encoded.
+	SerializedBodyLen(uint32) (int, bool)
+}
+
 // makeEmptyMessage creates a new empty message of the proper concrete type
 // based on the passed message type.
 func makeEmptyMessage(msgType MessageType) (Message, error) {
 	var msg Message
 
 	switch msgType {
+	case MsgWarning:
+		msg = &Warning{}
 	case MsgInit:
 		msg = &Init{}
 	case MsgOpenChannel:
@@ -228,7 +589,22 @@ func makeEmptyMessage(msgType MessageType) (Message, error) {
 func WriteMessage(w io.Writer, msg Message, pver uint32) (int, error) {
 	totalBytes := 0
 
-	// Encode the message payload itself into a temporary buffer.
+	// Refuse to write a message the protocol version doesn't know of, as
+	// the receiving end would be unable to decode it.
+	if err := CheckProtocolVersion(msg.MsgType(), pver); err != nil {
+		return totalBytes, err
+	}
+
+	// If the message knows its own length, we'll check its size before
+	// encoding it.
+	if sized, ok := msg.(SizedMessage); ok {
+		if lenp, ok := sized.SerializedBodyLen(pver); ok {
+			return writeSizedMessage(w, sized, pver, lenp)
+		}
+	}
+
+	// Otherwise, encode the message payload itself into a temporary
+	// buffer.
 	// TODO(roasbeef): create buffer pool
 	var bw bytes.Buffer
 	if err := msg.Encode(&bw, pver); err != nil {
@@ -237,19 +613,8 @@ func WriteMessage(w io.Writer, msg Message, pver uint32) (int, error) {
 	payload := bw.Bytes()
 	lenp := len(payload)
 
-	// Enforce maximum overall message payload.
-	if lenp > MaxMessagePayload {
-		return totalBytes, fmt.Errorf("message payload is too large - "+
-			"encoded %d bytes, but maximum message payload is %d bytes",
-			lenp, MaxMessagePayload)
-	}
-
-	// Enforce maximum message payload on the message type.
-	mpl := msg.MaxPayloadLength(pver)
-	if uint32(lenp) > mpl {
-		return totalBytes, fmt.Errorf("message payload is too large - "+
-			"encoded %d bytes, but maximum message payload of "+
-			"type %v is %d bytes", lenp, msg.MsgType(), mpl)
+	if err := checkPayloadSize(msg, pver, lenp); err != nil {
+		return totalBytes, err
 	}
 
 	// With the initial sanity checks complete, we'll now write out the
@@ -270,8 +635,122 @@ func WriteMessage(w io.Writer, msg Message, pver uint32) (int, error) {
 	return totalBytes, err
 }
 
+// writeSizedMessage writes a message whose payload is known to be lenp bytes
+// long to w. As the size of the message is checked before it's encoded, an
+// oversized message is rejected without encoding it at all. The message is
+// then encoded into a buffer of exactly the right size, and only written once
+// its length matches the reported one, so nothing is written on error.
+func writeSizedMessage(w io.Writer, msg SizedMessage, pver uint32,
+	lenp int) (int, error) {
+
+	if err := checkPayloadSize(msg, pver, lenp); err != nil {
+		return 0, err
+	}
+
+	var mType [2]byte
+	binary.BigEndian.PutUint16(mType[:], uint16(msg.MsgType()))
+
+	bw := bytes.NewBuffer(make([]byte, 0, len(mType)+lenp))
+	bw.Write(mType[:])
+	if err := msg.Encode(bw, pver); err != nil {
+		return 0, err
+	}
+
+	// A message reporting the wrong length would defeat the size checks
+	// above, so we'll make sure it didn't go unnoticed.
+	if bw.Len()-len(mType) != lenp {
+		return 0, fmt.Errorf("message of type %v encoded %d bytes, "+
+			"but reported a length of %d bytes", msg.MsgType(),
+			bw.Len()-len(mType), lenp)
+	}
+
+	return w.Write(bw.Bytes())
+}
+
+// countingWriter wraps an io.Writer, counting the number of bytes written
+// through it.
+type countingWriter struct {
+	w io.Writer
+	n int
+}
+
+// Write writes to the underlying writer, adding the number of bytes written
+// to the count.
+//
+// NOTE: implements the io.Writer interface.
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += n
+
+	return n, err
+}
+
+// checkPayloadSize ensures an encoded payload of the given length is within
+// both the overall maximum message payload and that of the message type.
+func checkPayloadSize(msg Message, pver uint32, lenp int) error {
+	// Enforce maximum overall message payload.
+	if lenp > MaxMessagePayload {
+		return fmt.Errorf("message payload is too large - encoded %d "+
+			"bytes, but maximum message payload is %d bytes",
+			lenp, MaxMessagePayload)
+	}
+
+	// Enforce maximum message payload on the message type.
+	mpl := msg.MaxPayloadLength(pver)
+	if uint32(lenp) > mpl {
+		return fmt.Errorf("message payload is too large - encoded %d "+
+			"bytes, but maximum message payload of type %v is %d "+
+			"bytes", lenp, msg.MsgType(), mpl)
+	}
+
+	return nil
+}
+
+// writeBufPool is a pool of buffers used by WriteMessageToConn to serialize
+// messages without allocating a new buffer for each one.
+var writeBufPool = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
+// WriteMessageToConn writes a lightning Message to conn including the
+// necessary header information, and returns the number of bytes written.
+// Unlike WriteMessage, the header and payload are serialized into a single
+// contiguous buffer that's handed to the connection in one Write call, which
+// avoids an extra syscall and any Nagle interactions between the two parts.
+func WriteMessageToConn(conn net.Conn, msg Message, pver uint32) (int, error) {
+	if err := CheckProtocolVersion(msg.MsgType(), pver); err != nil {
+		return 0, err
+	}
+
+	buf := writeBufPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	defer writeBufPool.Put(buf)
+
+	// Write out the message type, followed by the payload directly after
+	// it.
+	var mType [2]byte
+	binary.BigEndian.PutUint16(mType[:], uint16(msg.MsgType()))
+	buf.Write(mType[:])
+
+	if err := msg.Encode(buf, pver); err != nil {
+		return 0, err
+	}
+
+	if err := checkPayloadSize(msg, pver, buf.Len()-2); err != nil {
+		return 0, err
+	}
+
+	return conn.Write(buf.Bytes())
+}
+
 // ReadMessage reads, validates, and parses the next Lightning message from r
-// for the provided protocol version.
+// for the provided protocol version. A message reading beyond the size limit
+// of its type is rejected with ErrMsgTooLarge. As r may hold further data
+// following the message, any bytes the message doesn't consume are left
+// unread, so callers reading a framed message should use ReadFramedMessage to
+// also reject trailing bytes beyond the limit.
 func ReadMessage(r io.Reader, pver uint32) (Message, error) {
 	// First, we'll read out the first two bytes of the message so we can
 	// create the proper empty message.
@@ -282,15 +761,64 @@ func ReadMessage(r io.Reader, pver uint32) (Message, error) {
 
 	msgType := MessageType(binary.BigEndian.Uint16(mType[:]))
 
+	// Before going any further, we'll ensure the message type is part of
+	// the protocol version we're reading with.
+	if err := CheckProtocolVersion(msgType, pver); err != nil {
+		return nil, err
+	}
+
 	// Now that we know the target message type, we can create the proper
 	// empty message type and decode the message into it.
 	msg, err := makeEmptyMessage(msgType)
 	if err != nil {
 		return nil, err
 	}
-	if err := msg.Decode(r, pver); err != nil {
+
+	// We'll decode the message through a reader that ends at the size
+	// limit for its type, so that a bloated message is rejected rather
+	// than fully read into memory.
+	lr := newSizeLimitedReader(r, msgType)
+	err = msg.Decode(lr, pver)
+
+	// If the message didn't fit within the limit, we'll reject it, even if
+	// it was decoded successfully, as the decoder then merely stopped at
+	// the limit, e.g. truncating its trailing extra data.
+	if lr.exhausted {
+		return nil, ErrMsgTooLarge{msgType, lr.limit}
+	}
+
+	if err != nil {
+		// If we ran out of bytes midway, we'll report how far we got
+		// so the truncation can be told apart from a malformed
+		// message.
+		if errors.Is(err, io.EOF) ||
+			errors.Is(err, io.ErrUnexpectedEOF) {
+
+			return nil, PartialMessageError{
+				Type:      msgType,
+				BytesRead: len(mType) + lr.bytesRead(),
+				err:       err,
+			}
+		}
+
 		return nil, err
 	}
 
 	return msg, nil
 }
+
+// ReadFramedMessage parses a Lightning message from frame, which holds exactly
+// one message including its type, as read off the wire. Unlike ReadMessage, a
+// message whose frame exceeds the size limit of its type is rejected with
+// ErrMsgTooLarge before being decoded, even if the message's fields don't
+// consume the trailing bytes.
+func ReadFramedMessage(frame []byte, pver uint32) (Message, error) {
+	if len(frame) >= 2 {
+		msgType := MessageType(binary.BigEndian.Uint16(frame[:2]))
+		if err := CheckMsgSize(msgType, len(frame)-2); err != nil {
+			return nil, err
+		}
+	}
+
+	return ReadMessage(bytes.NewReader(frame), pver)
+}
@@ -0,0 +1,283 @@
+package lnwire
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"net"
+	"testing"
+)
+
+// recordingConn is a net.Conn that records everything written to it, along
+// with the number of calls to Write.
+type recordingConn struct {
+	net.Conn
+
+	buf    bytes.Buffer
+	writes int
+}
+
+// Write records the passed bytes and the call itself.
+//
+// NOTE: implements the io.Writer interface.
+func (c *recordingConn) Write(b []byte) (int, error) {
+	c.writes++
+	return c.buf.Write(b)
+}
+
+// benchmarkMessage returns a message representative of those written while
+// forwarding HTLCs.
+func benchmarkMessage(b testing.TB) Message {
+	msg, err := NewMinimal(MsgUpdateAddHTLC)
+	if err != nil {
+		b.Fatalf("unable to create msg: %v", err)
+	}
+
+	return msg
+}
+
+// TestWriteMessageToConn asserts that WriteMessageToConn writes the same bytes
+// as WriteMessage, using a single call to Write.
+func TestWriteMessageToConn(t *testing.T) {
+	t.Parallel()
+
+	msg := benchmarkMessage(t)
+
+	var expected bytes.Buffer
+	if _, err := WriteMessage(&expected, msg, 0); err != nil {
+		t.Fatalf("unable to write msg: %v", err)
+	}
+
+	conn := &recordingConn{}
+	n, err := WriteMessageToConn(conn, msg, 0)
+	if err != nil {
+		t.Fatalf("unable to write msg to conn: %v", err)
+	}
+
+	if n != expected.Len() {
+		t.Fatalf("expected %d bytes written, got %d", expected.Len(), n)
+	}
+	if conn.writes != 1 {
+		t.Fatalf("expected a single write, got %d", conn.writes)
+	}
+	if !bytes.Equal(expected.Bytes(), conn.buf.Bytes()) {
+		t.Fatalf("expected %x, got %x", expected.Bytes(),
+			conn.buf.Bytes())
+	}
+}
+
+// BenchmarkWriteMessage benchmarks writing a message to a connection with
+// WriteMessage, which writes the header and payload separately.
+func BenchmarkWriteMessage(b *testing.B) {
+	msg := benchmarkMessage(b)
+	conn := &recordingConn{}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		conn.buf.Reset()
+		if _, err := WriteMessage(conn, msg, 0); err != nil {
+			b.Fatalf("unable to write msg: %v", err)
+		}
+	}
+}
+
+// BenchmarkWriteMessageToConn benchmarks writing a message to a connection
+// with WriteMessageToConn, which writes the full message at once.
+func BenchmarkWriteMessageToConn(b *testing.B) {
+	msg := benchmarkMessage(b)
+	conn := &recordingConn{}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		conn.buf.Reset()
+		if _, err := WriteMessageToConn(conn, msg, 0); err != nil {
+			b.Fatalf("unable to write msg: %v", err)
+		}
+	}
+}
+
+// TestMessageCategories asserts that every message type we know how to decode
+// is assigned a category, and that the gossip and channel helpers agree with
+// it.
+func TestMessageCategories(t *testing.T) {
+	t.Parallel()
+
+	for i := 0; i <= math.MaxUint16; i++ {
+		msgType := MessageType(i)
+		if _, err := makeEmptyMessage(msgType); err != nil {
+			continue
+		}
+
+		category := msgType.Category()
+		if category == CategoryUnknown {
+			t.Fatalf("message type %v has no category", msgType)
+		}
+
+		msg, err := NewMinimal(msgType)
+		if err != nil {
+			t.Fatalf("unable to create %v: %v", msgType, err)
+		}
+
+		if IsGossipMessage(msg) != (category == CategoryGossip) {
+			t.Fatalf("gossip mismatch for %v", msgType)
+		}
+		if IsChannelMessage(msg) != (category == CategoryChannel) {
+			t.Fatalf("channel mismatch for %v", msgType)
+		}
+	}
+
+	if !IsGossipMessage(&AnnounceSignatures{}) {
+		t.Fatalf("expected AnnounceSignatures to be gossip")
+	}
+	if !IsChannelMessage(&CommitSig{}) {
+		t.Fatalf("expected CommitSig to be a channel message")
+	}
+}
+
+// TestReadMessagePartial asserts that reading a message that got truncated
+// after its type results in a PartialMessageError reporting how much of it was
+// read.
+func TestReadMessagePartial(t *testing.T) {
+	t.Parallel()
+
+	msg := NewUpdateFulfillHTLC(ChannelID{1}, 2, [32]byte{3})
+
+	var b bytes.Buffer
+	n, err := WriteMessage(&b, msg, 0)
+	if err != nil {
+		t.Fatalf("unable to write msg: %v", err)
+	}
+
+	// Truncating the message after its type and part of its body should
+	// result in a partial message error.
+	for _, size := range []int{2, 10, n - 1} {
+		r := bytes.NewReader(b.Bytes()[:size])
+		_, err := ReadMessage(r, 0)
+
+		var partialErr PartialMessageError
+		if !errors.As(err, &partialErr) {
+			t.Fatalf("expected partial message error, got %v", err)
+		}
+		if partialErr.Type != MsgUpdateFulfillHTLC {
+			t.Fatalf("expected type %v, got %v",
+				MsgUpdateFulfillHTLC, partialErr.Type)
+		}
+		if partialErr.BytesRead != size {
+			t.Fatalf("expected %d bytes read, got %d", size,
+				partialErr.BytesRead)
+		}
+		if !errors.Is(err, io.EOF) &&
+			!errors.Is(err, io.ErrUnexpectedEOF) {
+
+			t.Fatalf("expected error to wrap eof, got %v", err)
+		}
+	}
+
+	// Truncating the message within its type doesn't tell us which
+	// message was in progress.
+	_, err = ReadMessage(bytes.NewReader(b.Bytes()[:1]), 0)
+	if err != io.ErrUnexpectedEOF {
+		t.Fatalf("expected unexpected eof, got %v", err)
+	}
+}
+
+// badSizedMessage is a Ping reporting a wrong length for its payload.
+type badSizedMessage struct {
+	Ping
+}
+
+// SerializedBodyLen returns a length one byte short of the actual payload.
+//
+// NOTE: implements the SizedMessage interface.
+func (m *badSizedMessage) SerializedBodyLen(pver uint32) (int, bool) {
+	n, _ := m.Ping.SerializedBodyLen(pver)
+	return n - 1, true
+}
+
+// TestWriteSizedMessage asserts that messages reporting the length of their
+// payload are written just like those that don't, including those that can't
+// report it for their encoding.
+func TestWriteSizedMessage(t *testing.T) {
+	t.Parallel()
+
+	scids := []ShortChannelID{
+		NewShortChanIDFromInt(1), NewShortChanIDFromInt(2),
+	}
+	plainReply := &ReplyChannelRange{
+		EncodingType: EncodingSortedPlain,
+		ShortChanIDs: scids,
+	}
+	zlibReply := &ReplyChannelRange{
+		EncodingType: EncodingSortedZlib,
+		ShortChanIDs: scids,
+	}
+
+	testCases := []struct {
+		msg   SizedMessage
+		sized bool
+	}{
+		{msg: NewPing(10), sized: true},
+		{msg: &Ping{PaddingBytes: make(PingPayload, 100)}, sized: true},
+		{msg: NewPong(make([]byte, 20)), sized: true},
+		{msg: plainReply, sized: true},
+		{msg: zlibReply, sized: false},
+		{
+			msg: NewQueryShortChanIDs(
+				[32]byte{1}, EncodingSortedPlain, scids,
+			),
+			sized: true,
+		},
+		{
+			msg: NewQueryShortChanIDs(
+				[32]byte{1}, EncodingSortedZlib, scids,
+			),
+			sized: false,
+		},
+	}
+	for _, tc := range testCases {
+		var payload bytes.Buffer
+		if err := tc.msg.Encode(&payload, 0); err != nil {
+			t.Fatalf("unable to encode %v: %v", tc.msg.MsgType(),
+				err)
+		}
+
+		lenp, ok := tc.msg.SerializedBodyLen(0)
+		if ok != tc.sized {
+			t.Fatalf("expected %v to be sized=%v", tc.msg.MsgType(),
+				tc.sized)
+		}
+		if ok && lenp != payload.Len() {
+			t.Fatalf("expected %v length %d, got %d",
+				tc.msg.MsgType(), payload.Len(), lenp)
+		}
+
+		var b bytes.Buffer
+		n, err := WriteMessage(&b, tc.msg, 0)
+		if err != nil {
+			t.Fatalf("unable to write %v: %v", tc.msg.MsgType(),
+				err)
+		}
+		if n != b.Len() || n != payload.Len()+2 {
+			t.Fatalf("expected %d bytes written, got %d",
+				payload.Len()+2, n)
+		}
+		if !bytes.Equal(b.Bytes()[2:], payload.Bytes()) {
+			t.Fatalf("payload of %v mismatch", tc.msg.MsgType())
+		}
+	}
+
+	// A message reporting the wrong length should be caught before
+	// anything is written.
+	var b bytes.Buffer
+	n, err := WriteMessage(&b, &badSizedMessage{Ping: *NewPing(10)}, 0)
+	if err == nil {
+		t.Fatalf("expected length mismatch to be detected")
+	}
+	if n != 0 || b.Len() != 0 {
+		t.Fatalf("expected nothing to be written, got %d bytes",
+			b.Len())
+	}
+}
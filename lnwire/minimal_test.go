@@ -0,0 +1,53 @@
+package lnwire
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+)
+
+// TestNewMinimal asserts that a minimal message can be created for every
+// known message type, and that it survives an encoding round trip.
+func TestNewMinimal(t *testing.T) {
+	t.Parallel()
+
+	for i := 0; i <= math.MaxUint16; i++ {
+		msgType := MessageType(i)
+
+		// Skip over any message types we don't know of.
+		if _, err := makeEmptyMessage(msgType); err != nil {
+			if _, err := NewMinimal(msgType); err == nil {
+				t.Fatalf("expected error for unknown type %v",
+					msgType)
+			}
+			continue
+		}
+
+		msg, err := NewMinimal(msgType)
+		if err != nil {
+			t.Fatalf("unable to create minimal %v: %v", msgType,
+				err)
+		}
+
+		var b bytes.Buffer
+		_, err = WriteMessage(&b, msg, ProtocolVersionLatest)
+		if err != nil {
+			t.Fatalf("unable to write minimal %v: %v", msgType,
+				err)
+		}
+
+		newMsg, err := ReadMessage(&b, ProtocolVersionLatest)
+		if err != nil {
+			t.Fatalf("unable to read minimal %v: %v", msgType, err)
+		}
+
+		if !reflect.DeepEqual(msg, newMsg) {
+			t.Fatalf("minimal %v doesn't match after re-encoding: "+
+				"%v vs %v", msgType, spew.Sdump(msg),
+				spew.Sdump(newMsg))
+		}
+	}
+}
@@ -98,18 +98,44 @@ type NodeAnnouncement struct {
 	// properly validate the set of signatures that cover these new fields,
 	// and ensure we're able to make upgrades to the network in a forwards
 	// compatible manner.
-	ExtraOpaqueData []byte
+	ExtraOpaqueData ExtraOpaqueData
+}
+
+// NormalizeColor returns the color with its alpha channel zeroed. Only the red,
+// green and blue channels of a node's color are part of a NodeAnnouncement, so
+// a color with any other alpha channel wouldn't survive being encoded, and
+// would make announcements that are identical on the wire compare unequal.
+func NormalizeColor(c color.RGBA) color.RGBA {
+	c.A = 0
+	return c
 }
 
 // A compile time check to ensure NodeAnnouncement implements the
 // lnwire.Message interface.
 var _ Message = (*NodeAnnouncement)(nil)
 
+// A compile time check to ensure NodeAnnouncement implements the
+// lnwire.OptionalRecordsMessage interface.
+var _ OptionalRecordsMessage = (*NodeAnnouncement)(nil)
+
+// A compile time check to ensure NodeAnnouncement implements the
+// lnwire.UnknownRecordsMessage interface.
+var _ UnknownRecordsMessage = (*NodeAnnouncement)(nil)
+
 // Decode deserializes a serialized NodeAnnouncement stored in the passed
 // io.Reader observing the specified protocol version.
 //
 // This is part of the lnwire.Message interface.
 func (a *NodeAnnouncement) Decode(r io.Reader, pver uint32) error {
+	return a.DecodeWithAddrLimits(r, pver, DefaultAddrLimits())
+}
+
+// DecodeWithAddrLimits deserializes a serialized NodeAnnouncement like Decode,
+// but fails with ErrTooManyAddrs if it carries more addresses of a single type
+// than allowed by limits.
+func (a *NodeAnnouncement) DecodeWithAddrLimits(r io.Reader, pver uint32,
+	limits AddrLimits) error {
+
 	err := ReadElements(r,
 		&a.Signature,
 		&a.Features,
@@ -117,12 +143,16 @@ func (a *NodeAnnouncement) Decode(r io.Reader, pver uint32) error {
 		&a.NodeID,
 		&a.RGBColor,
 		&a.Alias,
-		&a.Addresses,
 	)
 	if err != nil {
 		return err
 	}
 
+	a.Addresses, err = readAddrs(r, limits)
+	if err != nil {
+		return err
+	}
+
 	// Now that we've read out all the fields that we explicitly know of,
 	// we'll collect the remainder into the ExtraOpaqueData field. If there
 	// aren't any bytes, then we'll snip off the slice to avoid carrying
@@ -170,6 +200,22 @@ func (a *NodeAnnouncement) MaxPayloadLength(pver uint32) uint32 {
 	return 65533
 }
 
+// ParsedOptionalRecords returns the sorted set of TLV types carried within the
+// ExtraOpaqueData of the message.
+//
+// This is part of the lnwire.OptionalRecordsMessage interface.
+func (a *NodeAnnouncement) ParsedOptionalRecords() []uint64 {
+	return a.ExtraOpaqueData.parsedOptionalRecords()
+}
+
+// UnknownRecords returns the TLV records carried within the ExtraOpaqueData of
+// the message, none of which we know of.
+//
+// This is part of the lnwire.UnknownRecordsMessage interface.
+func (a *NodeAnnouncement) UnknownRecords() []UnknownRecord {
+	return a.ExtraOpaqueData.unknownRecords()
+}
+
 // DataToSign returns the part of the message that should be signed.
 func (a *NodeAnnouncement) DataToSign() ([]byte, error) {
 
@@ -1,6 +1,14 @@
 package lnwire
 
-import "testing"
+import (
+	"bytes"
+	"image/color"
+	"net"
+	"testing"
+
+	"github.com/lightningnetwork/lnd/tor"
+	"github.com/stretchr/testify/require"
+)
 
 // TestNodeAliasValidation tests that the NewNodeAlias method will only accept
 // valid node announcements.
@@ -40,3 +48,230 @@ func TestNodeAliasValidation(t *testing.T) {
 		}
 	}
 }
+
+// TestDecodeAddrs asserts that DecodeAddrs hands each encoded address to the
+// callback in order, and stops decoding once the callback returns false.
+func TestDecodeAddrs(t *testing.T) {
+	t.Parallel()
+
+	addrs := []net.Addr{
+		&net.TCPAddr{IP: net.IP{127, 0, 0, 1}, Port: 9735},
+		&net.TCPAddr{IP: net.ParseIP("::1"), Port: 9736},
+		&tor.OnionAddr{
+			OnionService: "3g2upl4pq6kufc4m.onion",
+			Port:         9737,
+		},
+	}
+
+	var b bytes.Buffer
+	require.NoError(t, WriteElement(&b, addrs))
+
+	// Strip the length prefix to get at the raw address bytes.
+	rawAddrs := b.Bytes()[2:]
+
+	var decoded []net.Addr
+	err := DecodeAddrs(rawAddrs, func(addr net.Addr) bool {
+		decoded = append(decoded, addr)
+		return true
+	})
+	require.NoError(t, err)
+	require.Equal(t, addrs, decoded)
+
+	// Stopping after the first address shouldn't decode any others.
+	decoded = nil
+	err = DecodeAddrs(rawAddrs, func(addr net.Addr) bool {
+		decoded = append(decoded, addr)
+		return false
+	})
+	require.NoError(t, err)
+	require.Equal(t, addrs[:1], decoded)
+
+	// Since decoding stops early, a malformed trailing address shouldn't
+	// be reached either.
+	truncated := rawAddrs[:len(rawAddrs)-1]
+	err = DecodeAddrs(truncated, func(net.Addr) bool {
+		return false
+	})
+	require.NoError(t, err)
+
+	err = DecodeAddrs(truncated, func(net.Addr) bool {
+		return true
+	})
+	require.Error(t, err)
+}
+
+// TestWriteNetAddrs asserts that WriteNetAddrs either fails on or skips
+// addresses that can't be encoded, depending on the mode.
+func TestWriteNetAddrs(t *testing.T) {
+	t.Parallel()
+
+	goodAddrs := []net.Addr{
+		&net.TCPAddr{IP: net.IP{127, 0, 0, 1}, Port: 9735},
+		&net.TCPAddr{IP: net.ParseIP("::1"), Port: 9736},
+	}
+
+	// An onion service of the right length, but not valid base32, fails
+	// only after its descriptor has been encoded.
+	badOnion := &tor.OnionAddr{
+		OnionService: "!!!!!!!!!!!!!!!!.onion",
+		Port:         9737,
+	}
+	unknownAddr := &net.UnixAddr{Name: "/tmp/lnd.sock", Net: "unix"}
+
+	addrs := []net.Addr{goodAddrs[0], badOnion, goodAddrs[1], unknownAddr}
+
+	// In strict mode, the first bad address should fail the write.
+	var b bytes.Buffer
+	err := WriteNetAddrs(&b, addrs, AddrEncodingStrict)
+	require.Error(t, err)
+	_, ok := err.(ErrSkippedAddrs)
+	require.False(t, ok)
+
+	// When skipping invalid addresses, the good ones should be written
+	// exactly as if they were the only ones, and the bad ones reported.
+	b.Reset()
+	err = WriteNetAddrs(&b, addrs, AddrEncodingSkipInvalid)
+	skippedErr, ok := err.(ErrSkippedAddrs)
+	require.True(t, ok, err)
+	require.Equal(t, []net.Addr{badOnion, unknownAddr}, skippedErr.Addrs())
+
+	var expected bytes.Buffer
+	err = WriteNetAddrs(&expected, goodAddrs, AddrEncodingStrict)
+	require.NoError(t, err)
+	require.Equal(t, expected.Bytes(), b.Bytes())
+
+	var decoded []net.Addr
+	require.NoError(t, ReadElement(&b, &decoded))
+	require.Equal(t, goodAddrs, decoded)
+}
+
+// TestNetAddrsSameTypeOrdering asserts that multiple addresses of the same
+// type, interleaved with others, survive an encoding round trip in their
+// original order.
+func TestNetAddrsSameTypeOrdering(t *testing.T) {
+	t.Parallel()
+
+	addrs := []net.Addr{
+		&net.TCPAddr{IP: net.IP{10, 0, 0, 3}, Port: 9735},
+		&net.TCPAddr{IP: net.IP{10, 0, 0, 1}, Port: 9736},
+		&tor.OnionAddr{
+			OnionService: "3g2upl4pq6kufc4m.onion",
+			Port:         9735,
+		},
+		&net.TCPAddr{IP: net.IP{10, 0, 0, 2}, Port: 9737},
+		&tor.OnionAddr{
+			OnionService: "expyuzz4wqqyqhjn.onion",
+			Port:         9736,
+		},
+	}
+
+	var b bytes.Buffer
+	require.NoError(t, WriteElement(&b, addrs))
+
+	var decoded []net.Addr
+	require.NoError(t, ReadElement(&b, &decoded))
+	require.Equal(t, addrs, decoded)
+}
+
+// TestReadAddrsTypeLimits asserts that reading a set of addresses fails once
+// it contains more addresses of a single type than allowed.
+func TestReadAddrsTypeLimits(t *testing.T) {
+	t.Parallel()
+
+	tcp4Addrs := func(n int) []net.Addr {
+		addrs := make([]net.Addr, 0, n)
+		for i := 0; i < n; i++ {
+			addrs = append(addrs, &net.TCPAddr{
+				IP:   net.IP{10, 0, 0, byte(i)},
+				Port: 9735,
+			})
+		}
+		return addrs
+	}
+	onionAddr := &tor.OnionAddr{
+		OnionService: "3g2upl4pq6kufc4m.onion",
+		Port:         9735,
+	}
+
+	// Right at the limit, all addresses should be read, and addresses of
+	// another type don't count towards it.
+	addrs := append(tcp4Addrs(DefaultAddrLimits().MaxIPv4), onionAddr)
+
+	var b bytes.Buffer
+	require.NoError(t, WriteElement(&b, addrs))
+
+	var decoded []net.Addr
+	require.NoError(t, ReadElement(&b, &decoded))
+	require.Len(t, decoded, len(addrs))
+
+	// A single address beyond the limit should be rejected.
+	addrs = tcp4Addrs(DefaultAddrLimits().MaxIPv4 + 1)
+
+	b.Reset()
+	require.NoError(t, WriteElement(&b, addrs))
+
+	err := ReadElement(&b, &decoded)
+	require.Equal(t, ErrTooManyAddrs{
+		addrType: tcp4Addr,
+		limit:    DefaultAddrLimits().MaxIPv4,
+	}, err)
+
+	// A NodeAnnouncement can be decoded with custom limits instead, which
+	// only apply to that single decoding.
+	ann := &NodeAnnouncement{
+		Features:  NewRawFeatureVector(),
+		Addresses: tcp4Addrs(3),
+	}
+	b.Reset()
+	require.NoError(t, ann.Encode(&b, 0))
+	encoded := b.Bytes()
+
+	limits := DefaultAddrLimits()
+	limits.MaxIPv4 = 2
+
+	var decodedAnn NodeAnnouncement
+	err = decodedAnn.DecodeWithAddrLimits(
+		bytes.NewReader(encoded), 0, limits,
+	)
+	require.Equal(t, ErrTooManyAddrs{
+		addrType: tcp4Addr,
+		limit:    2,
+	}, err)
+
+	err = decodedAnn.Decode(bytes.NewReader(encoded), 0)
+	require.NoError(t, err)
+	require.Len(t, decodedAnn.Addresses, 3)
+}
+
+// TestNodeAnnouncementColorAlpha asserts that the alpha channel of a node's
+// color, which isn't encoded, doesn't make announcements compare unequal once
+// decoded.
+func TestNodeAnnouncementColorAlpha(t *testing.T) {
+	t.Parallel()
+
+	newAnn := func(alpha uint8) *NodeAnnouncement {
+		return &NodeAnnouncement{
+			Features: NewRawFeatureVector(),
+			RGBColor: color.RGBA{R: 1, G: 2, B: 3, A: alpha},
+		}
+	}
+
+	var encoded [2][]byte
+	for i, alpha := range []uint8{0, 255} {
+		var b bytes.Buffer
+		require.NoError(t, newAnn(alpha).Encode(&b, 0))
+		encoded[i] = b.Bytes()
+	}
+	require.Equal(t, encoded[0], encoded[1])
+
+	// Decoding into an announcement with a stale alpha channel should
+	// normalize it.
+	decoded := newAnn(255)
+	require.NoError(t, decoded.Decode(bytes.NewReader(encoded[1]), 0))
+	require.Equal(t, newAnn(0), decoded)
+
+	require.Equal(
+		t, color.RGBA{R: 1, G: 2, B: 3},
+		NormalizeColor(color.RGBA{R: 1, G: 2, B: 3, A: 128}),
+	)
+}
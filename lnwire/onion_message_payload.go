@@ -0,0 +1,347 @@
+package lnwire
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/lightningnetwork/lnd/tlv"
+)
+
+const (
+	// ReplyPathType is the TLV type of the blinded path the recipient of
+	// an onion message may use to reply to it.
+	ReplyPathType tlv.Type = 2
+
+	// EncryptedRecipientDataType is the TLV type of the data the creator
+	// of a blinded path encrypted for the hop processing the payload.
+	EncryptedRecipientDataType tlv.Type = 4
+
+	// FinalHopPayloadStart is the first TLV type reserved for payloads
+	// meant for the final hop of an onion message, such as BOLT12
+	// invoice requests, invoices and invoice errors.
+	FinalHopPayloadStart tlv.Type = 64
+
+	// RouteDataPaddingType is the TLV type of the padding within
+	// encrypted recipient data, which is ignored.
+	RouteDataPaddingType tlv.Type = 1
+
+	// RouteDataShortChanIDType is the TLV type of the short channel ID of
+	// the channel to forward over within encrypted recipient data.
+	RouteDataShortChanIDType tlv.Type = 2
+
+	// RouteDataNextNodeIDType is the TLV type of the ID of the node to
+	// forward to within encrypted recipient data.
+	RouteDataNextNodeIDType tlv.Type = 4
+
+	// RouteDataPathIDType is the TLV type of the secret the creator of a
+	// blinded path includes for itself within its encrypted recipient
+	// data.
+	RouteDataPathIDType tlv.Type = 6
+
+	// RouteDataBlindingOverrideType is the TLV type of the blinding point
+	// to use for the next hop instead of the one derived from the current
+	// one, within encrypted recipient data.
+	RouteDataBlindingOverrideType tlv.Type = 8
+)
+
+var (
+	// ErrMissingEncryptedData is returned when an onion message payload
+	// doesn't carry any encrypted recipient data.
+	ErrMissingEncryptedData = errors.New("onion message payload is " +
+		"missing encrypted recipient data")
+
+	// ErrEmptyBlindedPath is returned when a blinded path doesn't have any
+	// hops.
+	ErrEmptyBlindedPath = errors.New("blinded path has no hops")
+
+	// ErrConflictingNextHop is returned when encrypted recipient data
+	// specifies both a next node ID and a short channel ID to forward to.
+	ErrConflictingNextHop = errors.New("encrypted recipient data has " +
+		"both next node id and short channel id")
+)
+
+// ErrUnknownRequiredTLV is returned when a TLV stream carries an even type
+// that we don't know of, and therefore must not ignore.
+type ErrUnknownRequiredTLV struct {
+	typ tlv.Type
+}
+
+// Error returns a human readable string describing the error.
+//
+// NOTE: implements the error interface.
+func (e ErrUnknownRequiredTLV) Error() string {
+	return fmt.Sprintf("unknown required tlv type %d", e.typ)
+}
+
+// BlindedHop is a single hop within a blinded path.
+type BlindedHop struct {
+	// BlindedNodeID is the blinded public key of the node of this hop.
+	BlindedNodeID *btcec.PublicKey
+
+	// EncryptedData is the recipient data encrypted for the node of this
+	// hop.
+	EncryptedData []byte
+}
+
+// BlindedPath is a route to a node whose identity, and that of the nodes
+// leading up to it, is hidden from the sender.
+type BlindedPath struct {
+	// FirstNodeID is the public key of the introduction node of the path,
+	// which is not blinded.
+	FirstNodeID *btcec.PublicKey
+
+	// BlindingPoint is the ephemeral key used by the introduction node to
+	// decrypt its encrypted recipient data.
+	BlindingPoint *btcec.PublicKey
+
+	// Hops are the hops of the path, starting at the introduction node.
+	Hops []*BlindedHop
+}
+
+// Encode serializes the BlindedPath into the passed io.Writer.
+func (p *BlindedPath) Encode(w io.Writer) error {
+	if len(p.Hops) == 0 {
+		return ErrEmptyBlindedPath
+	}
+	if len(p.Hops) > 255 {
+		return fmt.Errorf("blinded path has %d hops, max is 255",
+			len(p.Hops))
+	}
+
+	err := WriteElements(w,
+		p.FirstNodeID,
+		p.BlindingPoint,
+		uint8(len(p.Hops)),
+	)
+	if err != nil {
+		return err
+	}
+
+	for _, hop := range p.Hops {
+		if len(hop.EncryptedData) > 65535 {
+			return fmt.Errorf("encrypted data of %d bytes is too "+
+				"large", len(hop.EncryptedData))
+		}
+
+		err := WriteElements(w,
+			hop.BlindedNodeID,
+			uint16(len(hop.EncryptedData)),
+			hop.EncryptedData,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Decode deserializes a BlindedPath from the passed io.Reader.
+func (p *BlindedPath) Decode(r io.Reader) error {
+	var numHops uint8
+	err := ReadElements(r,
+		&p.FirstNodeID,
+		&p.BlindingPoint,
+		&numHops,
+	)
+	if err != nil {
+		return err
+	}
+
+	if numHops == 0 {
+		return ErrEmptyBlindedPath
+	}
+
+	p.Hops = make([]*BlindedHop, 0, numHops)
+	for i := uint8(0); i < numHops; i++ {
+		var (
+			hop     BlindedHop
+			dataLen uint16
+		)
+		err := ReadElements(r, &hop.BlindedNodeID, &dataLen)
+		if err != nil {
+			return err
+		}
+
+		hop.EncryptedData = make([]byte, dataLen)
+		if _, err := io.ReadFull(r, hop.EncryptedData); err != nil {
+			return err
+		}
+
+		p.Hops = append(p.Hops, &hop)
+	}
+
+	return nil
+}
+
+// OnionMessagePayload is the payload of a single hop of an onion message.
+type OnionMessagePayload struct {
+	// ReplyPath is an optional blinded path the recipient may use to
+	// reply to the message.
+	ReplyPath *BlindedPath
+
+	// EncryptedData is the recipient data encrypted for the processing
+	// hop by the creator of the blinded path.
+	EncryptedData []byte
+
+	// FinalHopTLVs are the raw records meant for the final hop, keyed by
+	// their type, which is at least FinalHopPayloadStart.
+	FinalHopTLVs map[tlv.Type][]byte
+}
+
+// DecodeOnionMessagePayload decodes the TLV stream of the payload of a single
+// hop of an onion message. The encrypted recipient data is required, and any
+// unknown even type below FinalHopPayloadStart causes the payload to be
+// rejected.
+func DecodeOnionMessagePayload(r io.Reader) (*OnionMessagePayload, error) {
+	var (
+		payload       OnionMessagePayload
+		replyPath     []byte
+		encryptedData []byte
+	)
+
+	tlvStream, err := tlv.NewStream(
+		tlv.MakePrimitiveRecord(ReplyPathType, &replyPath),
+		tlv.MakePrimitiveRecord(
+			EncryptedRecipientDataType, &encryptedData,
+		),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	parsedTypes, err := tlvStream.DecodeWithParsedTypes(r)
+	if err != nil {
+		return nil, err
+	}
+
+	for typ, value := range parsedTypes {
+		// Known records are reported without a value.
+		if value == nil {
+			continue
+		}
+
+		if typ >= FinalHopPayloadStart {
+			if payload.FinalHopTLVs == nil {
+				payload.FinalHopTLVs = make(map[tlv.Type][]byte)
+			}
+			payload.FinalHopTLVs[typ] = value
+
+			continue
+		}
+
+		if typ%2 == 0 {
+			return nil, ErrUnknownRequiredTLV{typ: typ}
+		}
+	}
+
+	if _, ok := parsedTypes[EncryptedRecipientDataType]; !ok {
+		return nil, ErrMissingEncryptedData
+	}
+	payload.EncryptedData = encryptedData
+
+	if _, ok := parsedTypes[ReplyPathType]; ok {
+		payload.ReplyPath = &BlindedPath{}
+		err := payload.ReplyPath.Decode(bytes.NewReader(replyPath))
+		if err != nil {
+			return nil, fmt.Errorf("invalid reply path: %w", err)
+		}
+	}
+
+	return &payload, nil
+}
+
+// BlindedRouteData is the decrypted recipient data of a hop within a blinded
+// path of an onion message.
+type BlindedRouteData struct {
+	// ShortChannelID is the channel to forward the message over, if set.
+	ShortChannelID *ShortChannelID
+
+	// NextNodeID is the node to forward the message to, if set.
+	NextNodeID *btcec.PublicKey
+
+	// PathID is the secret the creator of the path included for itself,
+	// only present for the final hop.
+	PathID []byte
+
+	// NextBlindingOverride is the blinding point to use for the next hop
+	// instead of the one derived from the current one, if set.
+	NextBlindingOverride *btcec.PublicKey
+}
+
+// DecodeBlindedRouteData decodes the TLV stream of decrypted recipient data.
+// Any unknown even type causes the data to be rejected, as does specifying
+// both a next node ID and a short channel ID to forward to.
+func DecodeBlindedRouteData(r io.Reader) (*BlindedRouteData, error) {
+	var (
+		data             BlindedRouteData
+		padding          []byte
+		scid             uint64
+		nextNodeID       [33]byte
+		pathID           []byte
+		blindingOverride [33]byte
+	)
+
+	tlvStream, err := tlv.NewStream(
+		tlv.MakePrimitiveRecord(RouteDataPaddingType, &padding),
+		tlv.MakePrimitiveRecord(RouteDataShortChanIDType, &scid),
+		tlv.MakePrimitiveRecord(RouteDataNextNodeIDType, &nextNodeID),
+		tlv.MakePrimitiveRecord(RouteDataPathIDType, &pathID),
+		tlv.MakePrimitiveRecord(
+			RouteDataBlindingOverrideType, &blindingOverride,
+		),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	parsedTypes, err := tlvStream.DecodeWithParsedTypes(r)
+	if err != nil {
+		return nil, err
+	}
+
+	for typ, value := range parsedTypes {
+		if value != nil && typ%2 == 0 {
+			return nil, ErrUnknownRequiredTLV{typ: typ}
+		}
+	}
+
+	_, hasSCID := parsedTypes[RouteDataShortChanIDType]
+	_, hasNextNode := parsedTypes[RouteDataNextNodeIDType]
+	if hasSCID && hasNextNode {
+		return nil, ErrConflictingNextHop
+	}
+
+	if hasSCID {
+		shortChanID := NewShortChanIDFromInt(scid)
+		data.ShortChannelID = &shortChanID
+	}
+
+	if hasNextNode {
+		data.NextNodeID, err = btcec.ParsePubKey(
+			nextNodeID[:], btcec.S256(),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("invalid next node id: %w", err)
+		}
+	}
+
+	if _, ok := parsedTypes[RouteDataPathIDType]; ok {
+		data.PathID = pathID
+	}
+
+	if _, ok := parsedTypes[RouteDataBlindingOverrideType]; ok {
+		data.NextBlindingOverride, err = btcec.ParsePubKey(
+			blindingOverride[:], btcec.S256(),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("invalid next blinding "+
+				"override: %w", err)
+		}
+	}
+
+	return &data, nil
+}
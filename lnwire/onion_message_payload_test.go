@@ -0,0 +1,152 @@
+package lnwire
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/lightningnetwork/lnd/tlv"
+	"github.com/stretchr/testify/require"
+)
+
+// encodeTestStream encodes the given raw records as a TLV stream.
+func encodeTestStream(t *testing.T, records map[uint64][]byte) []byte {
+	t.Helper()
+
+	tlvRecords := tlv.MapToRecords(records)
+	tlv.SortRecords(tlvRecords)
+
+	stream, err := tlv.NewStream(tlvRecords...)
+	require.NoError(t, err)
+
+	var b bytes.Buffer
+	require.NoError(t, stream.Encode(&b))
+
+	return b.Bytes()
+}
+
+// newTestPubKey returns a new random public key.
+func newTestPubKey(t *testing.T) *btcec.PublicKey {
+	t.Helper()
+
+	priv, err := btcec.NewPrivateKey(btcec.S256())
+	require.NoError(t, err)
+
+	return priv.PubKey()
+}
+
+// TestDecodeOnionMessagePayload asserts that onion message payloads are
+// decoded into their typed fields, and that malformed payloads are rejected.
+func TestDecodeOnionMessagePayload(t *testing.T) {
+	t.Parallel()
+
+	replyPath := &BlindedPath{
+		FirstNodeID:   newTestPubKey(t),
+		BlindingPoint: newTestPubKey(t),
+		Hops: []*BlindedHop{
+			{
+				BlindedNodeID: newTestPubKey(t),
+				EncryptedData: []byte{1, 2, 3},
+			},
+			{
+				BlindedNodeID: newTestPubKey(t),
+				EncryptedData: []byte{4, 5},
+			},
+		},
+	}
+
+	var rawPath bytes.Buffer
+	require.NoError(t, replyPath.Encode(&rawPath))
+
+	rawPayload := encodeTestStream(t, map[uint64][]byte{
+		uint64(ReplyPathType):              rawPath.Bytes(),
+		uint64(EncryptedRecipientDataType): {9, 9},
+		5:                                  {0},
+		64:                                 {7},
+	})
+
+	payload, err := DecodeOnionMessagePayload(bytes.NewReader(rawPayload))
+	require.NoError(t, err)
+	require.Equal(t, replyPath, payload.ReplyPath)
+	require.Equal(t, []byte{9, 9}, payload.EncryptedData)
+	require.Equal(t, map[tlv.Type][]byte{64: {7}}, payload.FinalHopTLVs)
+
+	// The encrypted recipient data is required.
+	rawPayload = encodeTestStream(t, map[uint64][]byte{
+		uint64(ReplyPathType): rawPath.Bytes(),
+	})
+	_, err = DecodeOnionMessagePayload(bytes.NewReader(rawPayload))
+	require.Equal(t, ErrMissingEncryptedData, err)
+
+	// Unknown even types below the final hop range must be rejected.
+	rawPayload = encodeTestStream(t, map[uint64][]byte{
+		uint64(EncryptedRecipientDataType): {9},
+		10:                                 {0},
+	})
+	_, err = DecodeOnionMessagePayload(bytes.NewReader(rawPayload))
+	require.Equal(t, ErrUnknownRequiredTLV{typ: 10}, err)
+
+	// A reply path without any hops is invalid.
+	emptyPath := append(
+		replyPath.FirstNodeID.SerializeCompressed(),
+		replyPath.BlindingPoint.SerializeCompressed()...,
+	)
+	emptyPath = append(emptyPath, 0)
+	rawPayload = encodeTestStream(t, map[uint64][]byte{
+		uint64(ReplyPathType):              emptyPath,
+		uint64(EncryptedRecipientDataType): {9},
+	})
+	_, err = DecodeOnionMessagePayload(bytes.NewReader(rawPayload))
+	require.True(t, errors.Is(err, ErrEmptyBlindedPath))
+}
+
+// TestDecodeBlindedRouteData asserts that decrypted recipient data is decoded
+// into its typed fields, and that malformed data is rejected.
+func TestDecodeBlindedRouteData(t *testing.T) {
+	t.Parallel()
+
+	nextNode := newTestPubKey(t)
+	override := newTestPubKey(t)
+
+	rawData := encodeTestStream(t, map[uint64][]byte{
+		uint64(RouteDataPaddingType):    {0, 0, 0},
+		uint64(RouteDataNextNodeIDType): nextNode.SerializeCompressed(),
+		uint64(RouteDataBlindingOverrideType): override.
+			SerializeCompressed(),
+	})
+
+	data, err := DecodeBlindedRouteData(bytes.NewReader(rawData))
+	require.NoError(t, err)
+	require.Nil(t, data.ShortChannelID)
+	require.True(t, nextNode.IsEqual(data.NextNodeID))
+	require.True(t, override.IsEqual(data.NextBlindingOverride))
+	require.Nil(t, data.PathID)
+
+	// The final hop carries a path ID instead of a next hop.
+	rawData = encodeTestStream(t, map[uint64][]byte{
+		uint64(RouteDataPathIDType): {1, 2, 3, 4},
+	})
+	data, err = DecodeBlindedRouteData(bytes.NewReader(rawData))
+	require.NoError(t, err)
+	require.Equal(t, []byte{1, 2, 3, 4}, data.PathID)
+	require.Nil(t, data.NextNodeID)
+
+	// Specifying both a next node and a channel is ambiguous.
+	scid := ShortChannelID{BlockHeight: 100, TxIndex: 2, TxPosition: 1}
+	var rawSCID bytes.Buffer
+	require.NoError(t, WriteElement(&rawSCID, scid))
+	rawData = encodeTestStream(t, map[uint64][]byte{
+		uint64(RouteDataShortChanIDType): rawSCID.Bytes(),
+		uint64(RouteDataNextNodeIDType):  nextNode.SerializeCompressed(),
+	})
+	_, err = DecodeBlindedRouteData(bytes.NewReader(rawData))
+	require.Equal(t, ErrConflictingNextHop, err)
+
+	// Unknown even types must be rejected.
+	rawData = encodeTestStream(t, map[uint64][]byte{
+		12: {0},
+	})
+	_, err = DecodeBlindedRouteData(bytes.NewReader(rawData))
+	require.Equal(t, ErrUnknownRequiredTLV{typ: 12}, err)
+}
@@ -1,13 +1,36 @@
 package lnwire
 
 import (
+	"errors"
+	"fmt"
 	"io"
+	"math"
 
 	"github.com/btcsuite/btcd/btcec"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcutil"
 )
 
+var (
+	// ErrZeroInFlightLimit is returned when the max value in flight set
+	// by either side of a channel being opened is zero, which would
+	// prevent any HTLC from being offered to that side.
+	ErrZeroInFlightLimit = errors.New("max value in flight is zero")
+
+	// ErrInFlightLimitExceedsCapacity is returned when the max value in
+	// flight set by either side of a channel being opened exceeds the
+	// capacity of the channel.
+	ErrInFlightLimitExceedsCapacity = errors.New("max value in flight " +
+		"exceeds channel capacity")
+
+	// ErrInFlightLimitBelowHtlcMin is returned when the max value in
+	// flight set by either side of a channel being opened is below the
+	// smallest HTLC that side accepts, which would prevent any HTLC from
+	// being offered to it.
+	ErrInFlightLimitBelowHtlcMin = errors.New("max value in flight is " +
+		"below htlc minimum")
+)
+
 // FundingFlag represents the possible bit mask values for the ChannelFlags
 // field within the OpenChannel struct.
 type FundingFlag uint8
@@ -223,3 +246,82 @@ func (o *OpenChannel) MaxPayloadLength(uint32) uint32 {
 
 	return length
 }
+
+// ValidateInFlightLimits checks that the max value in flight set by both sides
+// of a channel being opened is sane: each must be non-zero, not exceed the
+// capacity of the channel, and allow at least a single HTLC of the minimum
+// size the side setting it accepts. The error returned identifies the message
+// whose limit is invalid. Neither message is modified.
+//
+// As some implementations set the max value in flight to its maximum value to
+// signal that they don't impose any limit, such a limit is validated as if it
+// were the capacity of the channel rather than rejected. ClampInFlightLimits
+// can be used to apply this to the messages themselves.
+func ValidateInFlightLimits(open *OpenChannel, accept *AcceptChannel) error {
+	capacity := NewMSatFromSatoshis(open.FundingAmount)
+
+	limits := []struct {
+		msgType MessageType
+		limit   MilliSatoshi
+		htlcMin MilliSatoshi
+	}{
+		{
+			msgType: open.MsgType(),
+			limit: clampInFlightLimit(
+				open.MaxValueInFlight, capacity,
+			),
+			htlcMin: open.HtlcMinimum,
+		},
+		{
+			msgType: accept.MsgType(),
+			limit: clampInFlightLimit(
+				accept.MaxValueInFlight, capacity,
+			),
+			htlcMin: accept.HtlcMinimum,
+		},
+	}
+	for _, l := range limits {
+		switch {
+		case l.limit == 0:
+			return fmt.Errorf("%v: %w", l.msgType,
+				ErrZeroInFlightLimit)
+
+		case l.limit > capacity:
+			return fmt.Errorf("%v: %w (%v > %v)", l.msgType,
+				ErrInFlightLimitExceedsCapacity, l.limit,
+				capacity)
+
+		case l.limit < l.htlcMin:
+			return fmt.Errorf("%v: %w (%v < %v)", l.msgType,
+				ErrInFlightLimitBelowHtlcMin, l.limit,
+				l.htlcMin)
+		}
+	}
+
+	return nil
+}
+
+// ClampInFlightLimits replaces a max value in flight signaling that no limit
+// is imposed, i.e. set to its maximum value, with the capacity of the channel
+// in both messages. Any other limit is left as is, so it should be called
+// after ValidateInFlightLimits succeeded.
+func ClampInFlightLimits(open *OpenChannel, accept *AcceptChannel) {
+	capacity := NewMSatFromSatoshis(open.FundingAmount)
+
+	open.MaxValueInFlight = clampInFlightLimit(
+		open.MaxValueInFlight, capacity,
+	)
+	accept.MaxValueInFlight = clampInFlightLimit(
+		accept.MaxValueInFlight, capacity,
+	)
+}
+
+// clampInFlightLimit returns the capacity if the max value in flight signals
+// that no limit is imposed, and the limit itself otherwise.
+func clampInFlightLimit(limit, capacity MilliSatoshi) MilliSatoshi {
+	if limit == math.MaxUint64 {
+		return capacity
+	}
+
+	return limit
+}
@@ -0,0 +1,112 @@
+package lnwire
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestValidateInFlightLimits asserts that the max value in flight of both
+// sides of a channel being opened must be within the channel capacity, and
+// allow for at least a single HTLC, while a limit signaling no limit at all is
+// treated as the capacity without modifying the messages unless clamped.
+func TestValidateInFlightLimits(t *testing.T) {
+	t.Parallel()
+
+	const capacity = 1000000
+	capacityMSat := NewMSatFromSatoshis(capacity)
+
+	testCases := []struct {
+		name        string
+		openLimit   MilliSatoshi
+		acceptLimit MilliSatoshi
+		err         error
+	}{
+		{
+			name:        "valid",
+			openLimit:   capacityMSat / 2,
+			acceptLimit: capacityMSat,
+		},
+		{
+			name:        "zero open limit",
+			openLimit:   0,
+			acceptLimit: capacityMSat,
+			err:         ErrZeroInFlightLimit,
+		},
+		{
+			name:        "zero accept limit",
+			openLimit:   capacityMSat,
+			acceptLimit: 0,
+			err:         ErrZeroInFlightLimit,
+		},
+		{
+			name:        "open limit exceeds capacity",
+			openLimit:   capacityMSat + 1,
+			acceptLimit: capacityMSat,
+			err:         ErrInFlightLimitExceedsCapacity,
+		},
+		{
+			name:        "accept limit exceeds capacity",
+			openLimit:   capacityMSat,
+			acceptLimit: capacityMSat + 1,
+			err:         ErrInFlightLimitExceedsCapacity,
+		},
+		{
+			name:        "accept limit below htlc minimum",
+			openLimit:   capacityMSat,
+			acceptLimit: 999,
+			err:         ErrInFlightLimitBelowHtlcMin,
+		},
+	}
+
+	for _, test := range testCases {
+		open := &OpenChannel{
+			FundingAmount:    capacity,
+			MaxValueInFlight: test.openLimit,
+			HtlcMinimum:      1000,
+		}
+		accept := &AcceptChannel{
+			MaxValueInFlight: test.acceptLimit,
+			HtlcMinimum:      1000,
+		}
+
+		err := ValidateInFlightLimits(open, accept)
+		if test.err == nil {
+			require.NoError(t, err, test.name)
+			continue
+		}
+		require.True(t, errors.Is(err, test.err), test.name)
+	}
+
+	// A limit of the maximum value signals that no limit is imposed, so
+	// it should be clamped to the capacity rather than rejected.
+	open := &OpenChannel{
+		FundingAmount:    capacity,
+		MaxValueInFlight: math.MaxUint64,
+		HtlcMinimum:      1000,
+	}
+	accept := &AcceptChannel{
+		MaxValueInFlight: math.MaxUint64,
+		HtlcMinimum:      1000,
+	}
+	require.NoError(t, ValidateInFlightLimits(open, accept))
+
+	// Validating the limits must not modify the messages.
+	require.Equal(t, MilliSatoshi(math.MaxUint64), open.MaxValueInFlight)
+	require.Equal(t, MilliSatoshi(math.MaxUint64), accept.MaxValueInFlight)
+
+	// Clamping them does, and leaves other limits untouched.
+	accept.MaxValueInFlight = capacityMSat / 2
+	ClampInFlightLimits(open, accept)
+	require.Equal(t, capacityMSat, open.MaxValueInFlight)
+	require.Equal(t, capacityMSat/2, accept.MaxValueInFlight)
+
+	// A limit signaling no limit at all is still validated against the
+	// minimum HTLC of its side.
+	open.MaxValueInFlight = math.MaxUint64
+	open.HtlcMinimum = capacityMSat + 1
+	err := ValidateInFlightLimits(open, accept)
+	require.True(t, errors.Is(err, ErrInFlightLimitBelowHtlcMin))
+}
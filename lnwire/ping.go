@@ -30,6 +30,10 @@ func NewPing(numBytes uint16) *Ping {
 // A compile time check to ensure Ping implements the lnwire.Message interface.
 var _ Message = (*Ping)(nil)
 
+// A compile time check to ensure Ping implements the lnwire.SizedMessage
+// interface.
+var _ SizedMessage = (*Ping)(nil)
+
 // Decode deserializes a serialized Ping message stored in the passed io.Reader
 // observing the specified protocol version.
 //
@@ -58,6 +62,13 @@ func (p *Ping) MsgType() MessageType {
 	return MsgPing
 }
 
+// SerializedBodyLen returns the length of the encoded payload of the Ping.
+//
+// This is part of the lnwire.SizedMessage interface.
+func (p *Ping) SerializedBodyLen(uint32) (int, bool) {
+	return 2 + 2 + len(p.PaddingBytes), true
+}
+
 // MaxPayloadLength returns the maximum allowed payload size for a Ping
 // complete message observing the specified protocol version.
 //
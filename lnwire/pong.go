@@ -26,6 +26,10 @@ func NewPong(pongBytes []byte) *Pong {
 // A compile time check to ensure Pong implements the lnwire.Message interface.
 var _ Message = (*Pong)(nil)
 
+// A compile time check to ensure Pong implements the lnwire.SizedMessage
+// interface.
+var _ SizedMessage = (*Pong)(nil)
+
 // Decode deserializes a serialized Pong message stored in the passed io.Reader
 // observing the specified protocol version.
 //
@@ -54,6 +58,13 @@ func (p *Pong) MsgType() MessageType {
 	return MsgPong
 }
 
+// SerializedBodyLen returns the length of the encoded payload of the Pong.
+//
+// This is part of the lnwire.SizedMessage interface.
+func (p *Pong) SerializedBodyLen(uint32) (int, bool) {
+	return 2 + len(p.PongBytes), true
+}
+
 // MaxPayloadLength returns the maximum allowed payload size for a Pong
 // complete message observing the specified protocol version.
 //
@@ -1,12 +1,33 @@
 package lnwire
 
 import (
+	"errors"
+	"fmt"
 	"io"
 	"math"
 
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 )
 
+// ErrZeroNumBlocks is returned when a QueryChannelRange doesn't cover any
+// blocks.
+var ErrZeroNumBlocks = errors.New("query channel range covers zero blocks")
+
+// ErrQueryRangeTooLarge is returned when a QueryChannelRange covers more
+// blocks than we're willing to scan in response to a single query.
+type ErrQueryRangeTooLarge struct {
+	numBlocks uint32
+	maxBlocks uint32
+}
+
+// Error returns a human readable string describing the error.
+//
+// NOTE: implements the error interface.
+func (e ErrQueryRangeTooLarge) Error() string {
+	return fmt.Sprintf("query channel range of %d blocks exceeds "+
+		"maximum of %d blocks", e.numBlocks, e.maxBlocks)
+}
+
 // QueryChannelRange is a message sent by a node in order to query the
 // receiving node of the set of open channel they know of with short channel
 // ID's after the specified block height, capped at the number of blocks beyond
@@ -87,3 +108,21 @@ func (q *QueryChannelRange) LastBlockHeight() uint32 {
 	}
 	return uint32(lastBlockHeight)
 }
+
+// Validate ensures the range of the QueryChannelRange is sane, covering at
+// least one block and at most maxBlocks blocks. This bounds the amount of work
+// a single query from a peer can cause us to perform.
+func (q *QueryChannelRange) Validate(maxBlocks uint32) error {
+	if q.NumBlocks == 0 {
+		return ErrZeroNumBlocks
+	}
+
+	if q.NumBlocks > maxBlocks {
+		return ErrQueryRangeTooLarge{
+			numBlocks: q.NumBlocks,
+			maxBlocks: maxBlocks,
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,29 @@
+package lnwire
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestQueryChannelRangeValidate asserts that queries covering no blocks, or
+// more blocks than the given maximum, are rejected.
+func TestQueryChannelRangeValidate(t *testing.T) {
+	t.Parallel()
+
+	const maxBlocks = 1000
+
+	query := &QueryChannelRange{
+		FirstBlockHeight: 100,
+		NumBlocks:        maxBlocks,
+	}
+	require.NoError(t, query.Validate(maxBlocks))
+
+	query.NumBlocks = 0
+	require.Equal(t, ErrZeroNumBlocks, query.Validate(maxBlocks))
+
+	query.NumBlocks = math.MaxUint32
+	err := query.Validate(maxBlocks)
+	require.IsType(t, ErrQueryRangeTooLarge{}, err)
+}
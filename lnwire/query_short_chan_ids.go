@@ -33,6 +33,13 @@ const (
 	// zlib decoding instance. We do this in order to limit the total
 	// amount of memory allocated during a decoding instance.
 	maxZlibBufSize = 67413630
+
+	// DefaultMaxDecodedSCIDs is the default maximum number of short
+	// channel ID's we'll decode from a single message. A plain encoding
+	// can't carry more than ~8k within the max message size, but a zlib
+	// encoding can, so this bounds the memory a single message can cause
+	// us to allocate while leaving plenty of room for legitimate replies.
+	DefaultMaxDecodedSCIDs = 1 << 17
 )
 
 // ErrUnsortedSIDs is returned when decoding a QueryShortChannelID request whose
@@ -48,6 +55,18 @@ func (e ErrUnsortedSIDs) Error() string {
 		e.curSID, e.prevSID)
 }
 
+// ErrTooManySCIDs is returned when a message carries more short channel ID's
+// than we're willing to decode.
+type ErrTooManySCIDs struct {
+	maxSCIDs int
+}
+
+// Error returns a human-readable description of the error.
+func (e ErrTooManySCIDs) Error() string {
+	return fmt.Sprintf("message carries more than the max of %d short "+
+		"chan ID's", e.maxSCIDs)
+}
+
 // zlibDecodeMtx is a package level mutex that we'll use in order to ensure
 // that we'll only attempt a single zlib decoding instance at a time. This
 // allows us to also further bound our memory usage.
@@ -84,7 +103,8 @@ type QueryShortChanIDs struct {
 	// noSort indicates whether or not to sort the short channel ids before
 	// writing them out.
 	//
-	// NOTE: This should only be used during testing.
+	// NOTE: This should only be used during testing, or for queries built
+	// from an SCIDSet, whose short channel ids are already sorted.
 	noSort bool
 }
 
@@ -103,6 +123,10 @@ func NewQueryShortChanIDs(h chainhash.Hash, e ShortChanIDEncoding,
 // lnwire.Message interface.
 var _ Message = (*QueryShortChanIDs)(nil)
 
+// A compile time check to ensure QueryShortChanIDs implements the
+// lnwire.SizedMessage interface.
+var _ SizedMessage = (*QueryShortChanIDs)(nil)
+
 // Decode deserializes a serialized QueryShortChanIDs message stored in the
 // passed io.Reader observing the specified protocol version.
 //
@@ -113,7 +137,9 @@ func (q *QueryShortChanIDs) Decode(r io.Reader, pver uint32) error {
 		return err
 	}
 
-	q.EncodingType, q.ShortChanIDs, err = decodeShortChanIDs(r)
+	q.EncodingType, q.ShortChanIDs, err = decodeShortChanIDs(
+		r, DefaultMaxDecodedSCIDs,
+	)
 
 	return err
 }
@@ -121,8 +147,11 @@ func (q *QueryShortChanIDs) Decode(r io.Reader, pver uint32) error {
 // decodeShortChanIDs decodes a set of short channel ID's that have been
 // encoded. The first byte of the body details how the short chan ID's were
 // encoded. We'll use this type to govern exactly how we go about encoding the
-// set of short channel ID's.
-func decodeShortChanIDs(r io.Reader) (ShortChanIDEncoding, []ShortChannelID, error) {
+// set of short channel ID's. At most maxSCIDs short channel ID's are decoded,
+// otherwise ErrTooManySCIDs is returned.
+func decodeShortChanIDs(r io.Reader, maxSCIDs int) (ShortChanIDEncoding,
+	[]ShortChannelID, error) {
+
 	// First, we'll attempt to read the number of bytes in the body of the
 	// set of encoded short channel ID's.
 	var numBytesResp uint16
@@ -172,6 +201,9 @@ func decodeShortChanIDs(r io.Reader) (ShortChanIDEncoding, []ShortChannelID, err
 		if numShortChanIDs == 0 {
 			return encodingType, nil, nil
 		}
+		if numShortChanIDs > maxSCIDs {
+			return 0, nil, ErrTooManySCIDs{maxSCIDs}
+		}
 
 		// Finally, we'll read out the exact number of short channel
 		// ID's to conclude our parsing.
@@ -254,6 +286,12 @@ func decodeShortChanIDs(r io.Reader) (ShortChanIDEncoding, []ShortChannelID, err
 					"ID: %v", err)
 			}
 
+			// Before collecting the ID, we'll make sure we don't
+			// exceed the number of ID's we're willing to decode.
+			if len(shortChanIDs) == maxSCIDs {
+				return 0, nil, ErrTooManySCIDs{maxSCIDs}
+			}
+
 			// We successfully read the next ID, so we'll collect
 			// that in the set of final ID's to return.
 			shortChanIDs = append(shortChanIDs, cid)
@@ -295,6 +333,36 @@ func (q *QueryShortChanIDs) Encode(w io.Writer, pver uint32) error {
 	return encodeShortChanIDs(w, q.EncodingType, q.ShortChanIDs, q.noSort)
 }
 
+// SerializedBodyLen returns the length of the encoded payload of the query.
+// The length of a zlib encoded query is only known once compressed, so false
+// is returned for it.
+//
+// This is part of the lnwire.SizedMessage interface.
+func (q *QueryShortChanIDs) SerializedBodyLen(uint32) (int, bool) {
+	n, ok := shortChanIDsEncodedLen(q.EncodingType, len(q.ShortChanIDs))
+	if !ok {
+		return 0, false
+	}
+
+	return chainhash.HashSize + n, true
+}
+
+// shortChanIDsEncodedLen returns the length of numChanIDs short channel ID's
+// encoded by encodeShortChanIDs with the given encoding type, including the
+// length prefix. False is returned for encodings whose length can't be known
+// without encoding the short channel ID's.
+func shortChanIDsEncodedLen(encodingType ShortChanIDEncoding,
+	numChanIDs int) (int, bool) {
+
+	if encodingType != EncodingSortedPlain {
+		return 0, false
+	}
+
+	// The length prefix is followed by the encoding type and the short
+	// channel ID's themselves.
+	return 2 + 1 + numChanIDs*8, true
+}
+
 // encodeShortChanIDs encodes the passed short channel ID's into the passed
 // io.Writer, respecting the specified encoding type.
 func encodeShortChanIDs(w io.Writer, encodingType ShortChanIDEncoding,
@@ -1,6 +1,53 @@
 package lnwire
 
-import "io"
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+const (
+	// replyChannelRangeOverhead is the number of bytes of a
+	// ReplyChannelRange, including its type, that aren't taken up by the
+	// encoded short channel ID's: the type, chain hash, first block
+	// height, number of blocks, complete flag, length of the encoded short
+	// channel ID's and their encoding type.
+	replyChannelRangeOverhead = 2 + 32 + 4 + 4 + 1 + 2 + 1
+
+	// maxStreamedSCIDsLen is the max number of bytes the encoded short
+	// channel ID's of a single streamed ReplyChannelRange may take up.
+	maxStreamedSCIDsLen = MaxMessagePayload - replyChannelRangeOverhead
+)
+
+// ErrSCIDOutOfRange is returned when streaming a reply to a QueryChannelRange
+// with a short channel ID outside of the block range of the query.
+type ErrSCIDOutOfRange struct {
+	scid        ShortChannelID
+	firstHeight uint32
+	lastHeight  uint32
+}
+
+// Error returns a human-readable description of the error.
+func (e ErrSCIDOutOfRange) Error() string {
+	return fmt.Sprintf("sid %v outside of queried block range [%d, %d]",
+		e.scid, e.firstHeight, e.lastHeight)
+}
+
+// ErrBlockTooLarge is returned when streaming a reply to a QueryChannelRange
+// with more short channel ID's within a single block than fit within a single
+// ReplyChannelRange.
+type ErrBlockTooLarge struct {
+	height   uint32
+	numSCIDs int
+}
+
+// Error returns a human-readable description of the error.
+func (e ErrBlockTooLarge) Error() string {
+	return fmt.Sprintf("%d sids of block %d don't fit within a single "+
+		"reply", e.numSCIDs, e.height)
+}
 
 // ReplyChannelRange is the response to the QueryChannelRange message. It
 // includes the original query, and the next streaming chunk of encoded short
@@ -38,11 +85,24 @@ func NewReplyChannelRange() *ReplyChannelRange {
 // lnwire.Message interface.
 var _ Message = (*ReplyChannelRange)(nil)
 
+// A compile time check to ensure ReplyChannelRange implements the
+// lnwire.SizedMessage interface.
+var _ SizedMessage = (*ReplyChannelRange)(nil)
+
 // Decode deserializes a serialized ReplyChannelRange message stored in the
 // passed io.Reader observing the specified protocol version.
 //
 // This is part of the lnwire.Message interface.
 func (c *ReplyChannelRange) Decode(r io.Reader, pver uint32) error {
+	return c.DecodeWithMaxSCIDs(r, pver, DefaultMaxDecodedSCIDs)
+}
+
+// DecodeWithMaxSCIDs deserializes a serialized ReplyChannelRange message like
+// Decode, but fails with ErrTooManySCIDs if the message carries more than
+// maxSCIDs short channel ID's, regardless of their encoding.
+func (c *ReplyChannelRange) DecodeWithMaxSCIDs(r io.Reader, pver uint32,
+	maxSCIDs int) error {
+
 	err := c.QueryChannelRange.Decode(r, pver)
 	if err != nil {
 		return err
@@ -52,7 +112,7 @@ func (c *ReplyChannelRange) Decode(r io.Reader, pver uint32) error {
 		return err
 	}
 
-	c.EncodingType, c.ShortChanIDs, err = decodeShortChanIDs(r)
+	c.EncodingType, c.ShortChanIDs, err = decodeShortChanIDs(r, maxSCIDs)
 
 	return err
 }
@@ -73,6 +133,23 @@ func (c *ReplyChannelRange) Encode(w io.Writer, pver uint32) error {
 	return encodeShortChanIDs(w, c.EncodingType, c.ShortChanIDs, c.noSort)
 }
 
+// SerializedBodyLen returns the length of the encoded payload of the reply.
+// The length of a zlib encoded reply is only known once compressed, so false
+// is returned for it, in which case WriteMessage buffers the payload.
+//
+// This is part of the lnwire.SizedMessage interface.
+func (c *ReplyChannelRange) SerializedBodyLen(uint32) (int, bool) {
+	n, ok := shortChanIDsEncodedLen(c.EncodingType, len(c.ShortChanIDs))
+	if !ok {
+		return 0, false
+	}
+
+	// The reply starts out with the query it replies to, consisting of
+	// the chain hash, first block height and number of blocks, followed
+	// by the complete flag.
+	return 32 + 4 + 4 + 1 + n, true
+}
+
 // MsgType returns the integer uniquely identifying this message type on the
 // wire.
 //
@@ -88,3 +165,249 @@ func (c *ReplyChannelRange) MsgType() MessageType {
 func (c *ReplyChannelRange) MaxPayloadLength(uint32) uint32 {
 	return MaxMessagePayload
 }
+
+// WriteReplyChannelRangeStream replies to the given query by streaming the
+// short channel ID's returned by scidSource, which must be sorted, into as many
+// ReplyChannelRange messages written to w as needed. Unlike building a single
+// ReplyChannelRange, the short channel ID's are encoded, and compressed if
+// requested, as they're read, so only a single message is held in memory at
+// any time.
+//
+// As required by BOLT #7, the short channel ID's of a block are never split
+// across messages, the block ranges of the messages are consecutive and cover
+// the whole query, and only the final message is marked complete. Should the
+// short channel ID's of a single block not fit within a message,
+// ErrBlockTooLarge is returned rather than truncating the block. Short channel
+// ID's outside of the block range of the query are rejected with
+// ErrSCIDOutOfRange.
+func WriteReplyChannelRangeStream(w io.Writer, query QueryChannelRange,
+	scidSource func() (ShortChannelID, bool),
+	encoding ShortChanIDEncoding) error {
+
+	switch encoding {
+	case EncodingSortedPlain, EncodingSortedZlib:
+	default:
+		return ErrUnknownShortChanIDEncoding(encoding)
+	}
+
+	stream := &replyStream{
+		w:           w,
+		query:       query,
+		encoding:    encoding,
+		firstHeight: query.FirstBlockHeight,
+	}
+
+	var (
+		block   []ShortChannelID
+		lastID  ShortChannelID
+		haveAny bool
+	)
+	for {
+		scid, ok := scidSource()
+		if !ok {
+			break
+		}
+
+		if haveAny && scid.ToUint64() <= lastID.ToUint64() {
+			return ErrUnsortedSIDs{lastID, scid}
+		}
+		lastID, haveAny = scid, true
+
+		// As the replies must cover exactly the queried range, we
+		// can't include any short channel ID outside of it.
+		if scid.BlockHeight < query.FirstBlockHeight ||
+			scid.BlockHeight > query.LastBlockHeight() {
+
+			return ErrSCIDOutOfRange{
+				scid:        scid,
+				firstHeight: query.FirstBlockHeight,
+				lastHeight:  query.LastBlockHeight(),
+			}
+		}
+
+		// Once we reach the next block, the short channel ID's of the
+		// current one are complete and can be added to the reply.
+		if len(block) > 0 && scid.BlockHeight != block[0].BlockHeight {
+			if err := stream.addBlock(block); err != nil {
+				return err
+			}
+			block = block[:0]
+		}
+
+		block = append(block, scid)
+	}
+
+	if len(block) > 0 {
+		if err := stream.addBlock(block); err != nil {
+			return err
+		}
+	}
+
+	return stream.writeReply(query.LastBlockHeight(), true)
+}
+
+// replyStream accumulates the encoded short channel ID's of the
+// ReplyChannelRange currently being streamed.
+type replyStream struct {
+	w        io.Writer
+	query    QueryChannelRange
+	encoding ShortChanIDEncoding
+
+	// firstHeight is the first block height covered by the current reply.
+	firstHeight uint32
+
+	// body holds the encoded short channel ID's of the current reply.
+	body bytes.Buffer
+
+	// zlibWriter compresses short channel ID's into body when using the
+	// zlib encoding. It's only created once the first short channel ID
+	// is added, so that a reply without any doesn't carry a zlib header.
+	zlibWriter *zlib.Writer
+
+	// pending is the number of bytes written to zlibWriter since it was
+	// last flushed into body.
+	pending int
+
+	// numSCIDs is the number of short channel ID's in the current reply.
+	numSCIDs int
+}
+
+// zlibBound returns an upper bound on the number of bytes n uncompressed bytes
+// may take up once compressed, including the zlib header and trailer, and the
+// empty blocks emitted when flushing and closing the compressor.
+func zlibBound(n int) int {
+	return n + 5*(n/16383+3) + 6
+}
+
+// fits returns whether n more bytes of short channel ID's can be added to the
+// current reply. When using the zlib encoding, the compressor may be flushed in
+// order to determine this precisely.
+func (s *replyStream) fits(n int) (bool, error) {
+	if s.encoding == EncodingSortedPlain {
+		return s.body.Len()+n <= maxStreamedSCIDsLen, nil
+	}
+
+	if s.body.Len()+zlibBound(s.pending+n) <= maxStreamedSCIDsLen {
+		return true, nil
+	}
+
+	// The bound on the data we haven't flushed yet is too loose, so we'll
+	// flush it to learn its actual compressed size.
+	if s.zlibWriter == nil || s.pending == 0 {
+		return false, nil
+	}
+	if err := s.zlibWriter.Flush(); err != nil {
+		return false, err
+	}
+	s.pending = 0
+
+	return s.body.Len()+zlibBound(n) <= maxStreamedSCIDsLen, nil
+}
+
+// addBlock adds all short channel ID's of a single block to the reply, first
+// writing out the current reply if they don't fit within it. If they don't fit
+// within an empty reply either, ErrBlockTooLarge is returned.
+func (s *replyStream) addBlock(block []ShortChannelID) error {
+	fits, err := s.fits(len(block) * 8)
+	if err != nil {
+		return err
+	}
+
+	// The current reply holds the short channel ID's of earlier blocks,
+	// all of which lie within the queried range, so it can end right
+	// before this block without underflowing.
+	if !fits && s.numSCIDs > 0 {
+		err := s.writeReply(block[0].BlockHeight-1, false)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, scid := range block {
+		fits, err := s.fits(8)
+		if err != nil {
+			return err
+		}
+		if !fits {
+			return ErrBlockTooLarge{
+				height:   scid.BlockHeight,
+				numSCIDs: len(block),
+			}
+		}
+
+		if err := s.addSCID(scid); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addSCID encodes a single short channel ID into the current reply.
+func (s *replyStream) addSCID(scid ShortChannelID) error {
+	s.numSCIDs++
+
+	if s.encoding == EncodingSortedPlain {
+		return WriteElements(&s.body, scid)
+	}
+
+	if s.zlibWriter == nil {
+		s.zlibWriter = zlib.NewWriter(&s.body)
+	}
+	s.pending += 8
+
+	return WriteElements(s.zlibWriter, scid)
+}
+
+// writeReply writes out the current reply, covering the blocks up to and
+// including lastHeight, and resets the stream for the next one.
+func (s *replyStream) writeReply(lastHeight uint32, complete bool) error {
+	if s.zlibWriter != nil {
+		if err := s.zlibWriter.Close(); err != nil {
+			return err
+		}
+		s.zlibWriter = nil
+	}
+
+	header := QueryChannelRange{
+		ChainHash:        s.query.ChainHash,
+		FirstBlockHeight: s.firstHeight,
+		NumBlocks:        lastHeight - s.firstHeight + 1,
+	}
+
+	var completeFlag uint8
+	if complete {
+		completeFlag = 1
+	}
+
+	var msg bytes.Buffer
+	msg.Grow(replyChannelRangeOverhead + s.body.Len())
+
+	var msgType [2]byte
+	binary.BigEndian.PutUint16(msgType[:], uint16(MsgReplyChannelRange))
+	msg.Write(msgType[:])
+
+	if err := header.Encode(&msg, 0); err != nil {
+		return err
+	}
+	err := WriteElements(&msg,
+		completeFlag,
+		uint16(s.body.Len()+1),
+		s.encoding,
+	)
+	if err != nil {
+		return err
+	}
+	msg.Write(s.body.Bytes())
+
+	if _, err := s.w.Write(msg.Bytes()); err != nil {
+		return err
+	}
+
+	s.body.Reset()
+	s.pending = 0
+	s.numSCIDs = 0
+	s.firstHeight = lastHeight + 1
+
+	return nil
+}
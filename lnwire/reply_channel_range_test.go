@@ -3,6 +3,7 @@ package lnwire
 import (
 	"bytes"
 	"encoding/hex"
+	"math/rand"
 	"reflect"
 	"testing"
 
@@ -105,3 +106,258 @@ func TestReplyChannelRangeEmpty(t *testing.T) {
 		})
 	}
 }
+
+// TestReplyChannelRangeMaxSCIDs tests that decoding a ReplyChannelRange that
+// carries more short channel ID's than allowed fails, for both encodings.
+func TestReplyChannelRangeMaxSCIDs(t *testing.T) {
+	t.Parallel()
+
+	const maxSCIDs = 10
+
+	encodings := []ShortChanIDEncoding{
+		EncodingSortedPlain, EncodingSortedZlib,
+	}
+	for _, encoding := range encodings {
+		sids := make([]ShortChannelID, maxSCIDs+1)
+		for i := range sids {
+			sids[i] = NewShortChanIDFromInt(uint64(i + 1))
+		}
+
+		req := &ReplyChannelRange{
+			EncodingType: encoding,
+			ShortChanIDs: sids,
+		}
+
+		var b bytes.Buffer
+		if err := req.Encode(&b, 0); err != nil {
+			t.Fatalf("unable to encode req: %v", err)
+		}
+
+		// Allowing one more ID should decode all of them.
+		var req2 ReplyChannelRange
+		err := req2.DecodeWithMaxSCIDs(
+			bytes.NewReader(b.Bytes()), 0, maxSCIDs+1,
+		)
+		if err != nil {
+			t.Fatalf("unable to decode req: %v", err)
+		}
+		if len(req2.ShortChanIDs) != len(sids) {
+			t.Fatalf("expected %d sids, got %d", len(sids),
+				len(req2.ShortChanIDs))
+		}
+
+		err = req2.DecodeWithMaxSCIDs(
+			bytes.NewReader(b.Bytes()), 0, maxSCIDs,
+		)
+		if _, ok := err.(ErrTooManySCIDs); !ok {
+			t.Fatalf("expected ErrTooManySCIDs for encoding %v, "+
+				"got: %v", encoding, err)
+		}
+	}
+}
+
+// TestWriteReplyChannelRangeStream tests that streaming a large set of short
+// channel ID's results in replies that fit within the max message size, cover
+// the whole query without splitting blocks, and carry all short channel ID's.
+func TestWriteReplyChannelRangeStream(t *testing.T) {
+	t.Parallel()
+
+	// We'll use random transaction indexes and positions to defeat the
+	// compression, ensuring the zlib encoding also needs multiple replies.
+	rng := rand.New(rand.NewSource(1))
+
+	var sids []ShortChannelID
+	for height := uint32(1000); len(sids) < 30000; height++ {
+		var txIndex uint32
+		for i := 0; i < 1+rng.Intn(5); i++ {
+			txIndex += 1 + uint32(rng.Intn(1000))
+			sids = append(sids, ShortChannelID{
+				BlockHeight: height,
+				TxIndex:     txIndex,
+				TxPosition:  uint16(rng.Intn(4)),
+			})
+		}
+	}
+
+	query := QueryChannelRange{
+		FirstBlockHeight: 500,
+		NumBlocks:        100000,
+	}
+
+	encodings := []ShortChanIDEncoding{
+		EncodingSortedPlain, EncodingSortedZlib,
+	}
+	for _, encoding := range encodings {
+		next := 0
+		source := func() (ShortChannelID, bool) {
+			if next == len(sids) {
+				return ShortChannelID{}, false
+			}
+			next++
+			return sids[next-1], true
+		}
+
+		var b bytes.Buffer
+		err := WriteReplyChannelRangeStream(&b, query, source, encoding)
+		if err != nil {
+			t.Fatalf("unable to stream replies: %v", err)
+		}
+
+		var (
+			replies    []*ReplyChannelRange
+			nextHeight = query.FirstBlockHeight
+			decoded    []ShortChannelID
+		)
+		for b.Len() > 0 {
+			startLen := b.Len()
+			msg, err := ReadMessage(&b, 0)
+			if err != nil {
+				t.Fatalf("unable to read reply: %v", err)
+			}
+			reply := msg.(*ReplyChannelRange)
+			replies = append(replies, reply)
+
+			msgLen := startLen - b.Len()
+			if msgLen > MaxMessagePayload {
+				t.Fatalf("reply of %d bytes exceeds max "+
+					"message size", msgLen)
+			}
+
+			if reply.EncodingType != encoding {
+				t.Fatalf("expected encoding %v, got %v",
+					encoding, reply.EncodingType)
+			}
+			if reply.FirstBlockHeight != nextHeight {
+				t.Fatalf("expected reply to start at %d, got %d",
+					nextHeight, reply.FirstBlockHeight)
+			}
+			lastHeight := reply.LastBlockHeight()
+			for _, sid := range reply.ShortChanIDs {
+				if sid.BlockHeight < reply.FirstBlockHeight ||
+					sid.BlockHeight > lastHeight {
+
+					t.Fatalf("sid %v outside of reply range",
+						sid)
+				}
+			}
+			nextHeight = reply.LastBlockHeight() + 1
+
+			decoded = append(decoded, reply.ShortChanIDs...)
+		}
+
+		if len(replies) < 2 {
+			t.Fatalf("expected multiple replies for encoding %v, "+
+				"got %d", encoding, len(replies))
+		}
+		for i, reply := range replies {
+			isLast := i == len(replies)-1
+			if (reply.Complete == 1) != isLast {
+				t.Fatalf("reply %d has complete=%d", i,
+					reply.Complete)
+			}
+		}
+		if nextHeight != query.LastBlockHeight()+1 {
+			t.Fatalf("replies end at %d, expected %d",
+				nextHeight-1, query.LastBlockHeight())
+		}
+		if !reflect.DeepEqual(sids, decoded) {
+			t.Fatalf("decoded sids don't match for encoding %v",
+				encoding)
+		}
+	}
+}
+
+// TestWriteReplyChannelRangeStreamUnsorted tests that streaming short channel
+// ID's that aren't sorted results in an error.
+func TestWriteReplyChannelRangeStreamUnsorted(t *testing.T) {
+	t.Parallel()
+
+	sids := []ShortChannelID{
+		NewShortChanIDFromInt(2),
+		NewShortChanIDFromInt(1),
+	}
+	source := func() (ShortChannelID, bool) {
+		if len(sids) == 0 {
+			return ShortChannelID{}, false
+		}
+		sid := sids[0]
+		sids = sids[1:]
+		return sid, true
+	}
+
+	var b bytes.Buffer
+	err := WriteReplyChannelRangeStream(
+		&b, QueryChannelRange{NumBlocks: 10}, source,
+		EncodingSortedPlain,
+	)
+	if _, ok := err.(ErrUnsortedSIDs); !ok {
+		t.Fatalf("expected ErrUnsortedSIDs, got %v", err)
+	}
+}
+
+// TestWriteReplyChannelRangeStreamInvalid tests that streaming short channel
+// ID's outside of the queried range, or more within a single block than fit
+// within a reply, results in an error rather than a malformed reply.
+func TestWriteReplyChannelRangeStreamInvalid(t *testing.T) {
+	t.Parallel()
+
+	newSource := func(sids []ShortChannelID) func() (ShortChannelID, bool) {
+		return func() (ShortChannelID, bool) {
+			if len(sids) == 0 {
+				return ShortChannelID{}, false
+			}
+			sid := sids[0]
+			sids = sids[1:]
+			return sid, true
+		}
+	}
+
+	query := QueryChannelRange{
+		FirstBlockHeight: 100,
+		NumBlocks:        10,
+	}
+
+	// Short channel ID's at height zero, below the query, or above it
+	// must be rejected.
+	for _, height := range []uint32{0, 99, 110} {
+		source := newSource([]ShortChannelID{{BlockHeight: height}})
+
+		var b bytes.Buffer
+		err := WriteReplyChannelRangeStream(
+			&b, query, source, EncodingSortedPlain,
+		)
+		if _, ok := err.(ErrSCIDOutOfRange); !ok {
+			t.Fatalf("expected ErrSCIDOutOfRange for height %d, "+
+				"got %v", height, err)
+		}
+	}
+
+	// The boundaries of the query are within range.
+	source := newSource([]ShortChannelID{
+		{BlockHeight: 100}, {BlockHeight: 109},
+	})
+	var b bytes.Buffer
+	err := WriteReplyChannelRangeStream(
+		&b, query, source, EncodingSortedPlain,
+	)
+	if err != nil {
+		t.Fatalf("unable to stream replies: %v", err)
+	}
+
+	// A block with more short channel ID's than fit within a single
+	// reply must not be truncated.
+	var sids []ShortChannelID
+	for i := 0; i <= maxStreamedSCIDsLen/8; i++ {
+		sids = append(sids, ShortChannelID{
+			BlockHeight: 105,
+			TxIndex:     uint32(i),
+		})
+	}
+	b.Reset()
+	err = WriteReplyChannelRangeStream(
+		&b, query, newSource(sids), EncodingSortedPlain,
+	)
+	if _, ok := err.(ErrBlockTooLarge); !ok {
+		t.Fatalf("expected ErrBlockTooLarge, got %v", err)
+	}
+}
@@ -0,0 +1,96 @@
+package lnwire
+
+import (
+	"io"
+	"sort"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// SCIDSet is a set of short channel ID's that's kept sorted as short channel
+// ID's are added to it, so that it can be encoded, or turned into a
+// QueryShortChanIDs, without sorting it first. Adding short channel ID's in
+// ascending order, as they're usually learned of during gossip sync, only
+// appends them to the set.
+type SCIDSet struct {
+	scids []ShortChannelID
+}
+
+// NewSCIDSet creates a new SCIDSet containing the given short channel ID's.
+func NewSCIDSet(scids ...ShortChannelID) *SCIDSet {
+	s := &SCIDSet{
+		scids: make([]ShortChannelID, 0, len(scids)),
+	}
+	for _, scid := range scids {
+		s.Add(scid)
+	}
+
+	return s
+}
+
+// search returns the index of the short channel ID within the set, or the
+// index it would be inserted at if it isn't part of the set.
+func (s *SCIDSet) search(scid ShortChannelID) int {
+	id := scid.ToUint64()
+
+	// The common case of a short channel ID past all others doesn't
+	// require a search.
+	n := len(s.scids)
+	if n == 0 || s.scids[n-1].ToUint64() < id {
+		return n
+	}
+
+	return sort.Search(n, func(i int) bool {
+		return s.scids[i].ToUint64() >= id
+	})
+}
+
+// Add adds the short channel ID to the set, returning false if it was already
+// part of it.
+func (s *SCIDSet) Add(scid ShortChannelID) bool {
+	i := s.search(scid)
+	if i < len(s.scids) && s.scids[i] == scid {
+		return false
+	}
+
+	s.scids = append(s.scids, ShortChannelID{})
+	copy(s.scids[i+1:], s.scids[i:])
+	s.scids[i] = scid
+
+	return true
+}
+
+// Contains returns whether the short channel ID is part of the set.
+func (s *SCIDSet) Contains(scid ShortChannelID) bool {
+	i := s.search(scid)
+	return i < len(s.scids) && s.scids[i] == scid
+}
+
+// Len returns the number of short channel ID's within the set.
+func (s *SCIDSet) Len() int {
+	return len(s.scids)
+}
+
+// SCIDs returns a sorted copy of the short channel ID's within the set.
+func (s *SCIDSet) SCIDs() []ShortChannelID {
+	return append([]ShortChannelID(nil), s.scids...)
+}
+
+// Encode encodes the short channel ID's within the set into w using the given
+// encoding type, in the same manner as they're encoded within a
+// QueryShortChanIDs.
+func (s *SCIDSet) Encode(w io.Writer, encodingType ShortChanIDEncoding) error {
+	return encodeShortChanIDs(w, encodingType, s.scids, true)
+}
+
+// QueryShortChanIDs creates a QueryShortChanIDs message for the short channel
+// ID's within the set. As they're known to be sorted, they won't be sorted
+// again when encoding the message.
+func (s *SCIDSet) QueryShortChanIDs(chainHash chainhash.Hash,
+	encodingType ShortChanIDEncoding) *QueryShortChanIDs {
+
+	query := NewQueryShortChanIDs(chainHash, encodingType, s.SCIDs())
+	query.noSort = true
+
+	return query
+}
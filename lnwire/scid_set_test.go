@@ -0,0 +1,61 @@
+package lnwire
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestSCIDSet asserts that an SCIDSet stays sorted and free of duplicates as
+// short channel ID's are added, and that the queries built from it match those
+// built from an unsorted slice.
+func TestSCIDSet(t *testing.T) {
+	t.Parallel()
+
+	unsorted := []ShortChannelID{
+		NewShortChanIDFromInt(5), NewShortChanIDFromInt(1),
+		NewShortChanIDFromInt(9), NewShortChanIDFromInt(3),
+	}
+	set := NewSCIDSet(unsorted...)
+
+	// Adding a short channel ID twice has no effect.
+	require.False(t, set.Add(NewShortChanIDFromInt(3)))
+	require.True(t, set.Add(NewShortChanIDFromInt(7)))
+	require.True(t, set.Add(NewShortChanIDFromInt(10)))
+	require.True(t, set.Contains(NewShortChanIDFromInt(7)))
+	require.False(t, set.Contains(NewShortChanIDFromInt(8)))
+
+	expected := []ShortChannelID{
+		NewShortChanIDFromInt(1), NewShortChanIDFromInt(3),
+		NewShortChanIDFromInt(5), NewShortChanIDFromInt(7),
+		NewShortChanIDFromInt(9), NewShortChanIDFromInt(10),
+	}
+	require.Equal(t, len(expected), set.Len())
+	require.Equal(t, expected, set.SCIDs())
+
+	for _, encoding := range []ShortChanIDEncoding{
+		EncodingSortedPlain, EncodingSortedZlib,
+	} {
+		var setBytes, sliceBytes bytes.Buffer
+
+		query := set.QueryShortChanIDs([32]byte{1}, encoding)
+		_, err := WriteMessage(&setBytes, query, 0)
+		require.NoError(t, err)
+
+		scids := append([]ShortChannelID{
+			NewShortChanIDFromInt(10), NewShortChanIDFromInt(7),
+		}, unsorted...)
+		query = NewQueryShortChanIDs([32]byte{1}, encoding, scids)
+		_, err = WriteMessage(&sliceBytes, query, 0)
+		require.NoError(t, err)
+
+		require.Equal(t, sliceBytes.Bytes(), setBytes.Bytes())
+
+		// Encoding the set directly should match the encoded short
+		// channel ID's of the query, which follow the chain hash.
+		var encoded bytes.Buffer
+		require.NoError(t, set.Encode(&encoded, encoding))
+		require.Equal(t, setBytes.Bytes()[2+32:], encoded.Bytes())
+	}
+}
@@ -1,9 +1,51 @@
 package lnwire
 
 import (
+	"errors"
 	"fmt"
 )
 
+const (
+	// AliasStartBlockHeight is the first block height of the range that's
+	// reserved for alias short channel IDs. No real channel can be
+	// confirmed within this range for the foreseeable future.
+	AliasStartBlockHeight uint32 = 16_000_000
+
+	// AliasEndBlockHeight is the block height directly after the range
+	// that's reserved for alias short channel IDs.
+	AliasEndBlockHeight uint32 = 16_250_000
+
+	// maxScidField is the largest value that can be encoded within the
+	// 3-byte block height and transaction index fields of a
+	// ShortChannelID.
+	maxScidField = 0xFFFFFF
+)
+
+var (
+	// ErrZeroShortChanID is returned when a ShortChannelID is all zeros.
+	ErrZeroShortChanID = errors.New("short channel id is zero")
+
+	// ErrShortChanIDOverflow is returned when the block height or
+	// transaction index of a ShortChannelID don't fit within 3 bytes.
+	ErrShortChanIDOverflow = errors.New("short channel id field " +
+		"overflows 3 bytes")
+)
+
+// ErrFutureShortChanID is returned when a ShortChannelID references a block
+// beyond the current chain tip.
+type ErrFutureShortChanID struct {
+	scid     ShortChannelID
+	chainTip uint32
+}
+
+// Error returns a human readable string describing the error.
+//
+// NOTE: implements the error interface.
+func (e ErrFutureShortChanID) Error() string {
+	return fmt.Sprintf("short channel id %v references block beyond "+
+		"chain tip %d", e.scid, e.chainTip)
+}
+
 // ShortChannelID represents the set of data which is needed to retrieve all
 // necessary data to validate the channel existence.
 type ShortChannelID struct {
@@ -46,3 +88,36 @@ func (c ShortChannelID) ToUint64() uint64 {
 func (c ShortChannelID) String() string {
 	return fmt.Sprintf("%d:%d:%d", c.BlockHeight, c.TxIndex, c.TxPosition)
 }
+
+// IsAlias returns true if the ShortChannelID falls within the block height
+// range reserved for alias short channel IDs.
+func (c ShortChannelID) IsAlias() bool {
+	return c.BlockHeight >= AliasStartBlockHeight &&
+		c.BlockHeight < AliasEndBlockHeight
+}
+
+// IsValid returns an error if the ShortChannelID can't possibly reference a
+// channel given the current chain tip. Alias short channel IDs aren't bound to
+// the chain, so only their encoding is checked.
+func (c ShortChannelID) IsValid(chainTip uint32) error {
+	if c == (ShortChannelID{}) {
+		return ErrZeroShortChanID
+	}
+
+	if c.BlockHeight > maxScidField || c.TxIndex > maxScidField {
+		return ErrShortChanIDOverflow
+	}
+
+	if c.IsAlias() {
+		return nil
+	}
+
+	if c.BlockHeight > chainTip {
+		return ErrFutureShortChanID{
+			scid:     c,
+			chainTip: chainTip,
+		}
+	}
+
+	return nil
+}
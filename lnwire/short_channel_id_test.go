@@ -39,3 +39,80 @@ func TestShortChannelIDEncoding(t *testing.T) {
 		}
 	}
 }
+
+// TestShortChannelIDIsValid asserts that impossible short channel IDs are
+// rejected, while aliases aren't bound by the chain tip.
+func TestShortChannelIDIsValid(t *testing.T) {
+	t.Parallel()
+
+	const chainTip = 700000
+
+	testCases := []struct {
+		name    string
+		scid    ShortChannelID
+		isAlias bool
+		valid   bool
+	}{
+		{
+			name:  "zero",
+			scid:  ShortChannelID{},
+			valid: false,
+		},
+		{
+			name: "confirmed",
+			scid: ShortChannelID{
+				BlockHeight: chainTip,
+				TxIndex:     10,
+				TxPosition:  1,
+			},
+			valid: true,
+		},
+		{
+			name: "future block height",
+			scid: ShortChannelID{
+				BlockHeight: chainTip + 1,
+			},
+			valid: false,
+		},
+		{
+			name: "tx index overflow",
+			scid: ShortChannelID{
+				BlockHeight: chainTip,
+				TxIndex:     1 << 24,
+			},
+			valid: false,
+		},
+		{
+			name: "alias",
+			scid: ShortChannelID{
+				BlockHeight: AliasStartBlockHeight,
+				TxIndex:     1,
+			},
+			isAlias: true,
+			valid:   true,
+		},
+		{
+			name: "past alias range",
+			scid: ShortChannelID{
+				BlockHeight: AliasEndBlockHeight,
+			},
+			valid: false,
+		},
+	}
+
+	for _, testCase := range testCases {
+		err := testCase.scid.IsValid(chainTip)
+		if testCase.valid && err != nil {
+			t.Fatalf("%s: expected valid scid, got: %v",
+				testCase.name, err)
+		}
+		if !testCase.valid && err == nil {
+			t.Fatalf("%s: expected invalid scid", testCase.name)
+		}
+
+		if testCase.scid.IsAlias() != testCase.isAlias {
+			t.Fatalf("%s: expected alias=%v", testCase.name,
+				testCase.isAlias)
+		}
+	}
+}
@@ -1,7 +1,36 @@
 package lnwire
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
 	"io"
+
+	"github.com/btcsuite/btcd/txscript"
+)
+
+var (
+	// ErrNonStandardDeliveryAddress is returned when a delivery address
+	// isn't one of the standard script types allowed by the spec.
+	ErrNonStandardDeliveryAddress = errors.New("delivery address is not " +
+		"a standard script")
+
+	// ErrUpfrontShutdownMismatch is returned when the delivery address
+	// of a Shutdown message doesn't match the upfront shutdown script
+	// negotiated when opening the channel.
+	ErrUpfrontShutdownMismatch = errors.New("delivery address does not " +
+		"match upfront shutdown script")
+
+	// ErrAnySegwitNotNegotiated is returned when a delivery address pays
+	// to a segwit version only allowed if option_shutdown_anysegwit was
+	// negotiated, while it wasn't.
+	ErrAnySegwitNotNegotiated = errors.New("delivery address pays to " +
+		"segwit version not allowed without option_shutdown_anysegwit")
+
+	// ErrShutdownChanIDMismatch is returned when resolving a simultaneous
+	// shutdown whose messages reference different channels.
+	ErrShutdownChanIDMismatch = errors.New("shutdown messages reference " +
+		"different channels")
 )
 
 // Shutdown is sent by either side in order to initiate the cooperative closure
@@ -18,18 +47,95 @@ type Shutdown struct {
 }
 
 // DeliveryAddress is used to communicate the address to which funds from a
-// closed channel should be sent. The address can be a p2wsh, p2pkh, p2sh or
-// p2wpkh.
+// closed channel should be sent. The address can be a p2wsh, p2pkh, p2sh,
+// p2wpkh or p2tr.
 type DeliveryAddress []byte
 
 // deliveryAddressMaxSize is the maximum expected size in bytes of a
 // DeliveryAddress based on the types of scripts we know.
 // Following are the known scripts and their sizes in bytes.
+// - pay to future segwit version (option_shutdown_anysegwit): up to 42
 // - pay to witness script hash: 34
+// - pay to taproot: 34
 // - pay to pubkey hash: 25
 // - pay to script hash: 22
 // - pay to witness pubkey hash: 22.
-const deliveryAddressMaxSize = 34
+const deliveryAddressMaxSize = 42
+
+// IsStandardScript returns whether the delivery address is one of the script
+// types always allowed by the spec: p2pkh, p2sh, p2wpkh or p2wsh. Scripts
+// paying to later segwit versions, including p2tr, are only allowed if
+// option_shutdown_anysegwit was negotiated, which IsValidShutdownScript
+// accounts for.
+func (d DeliveryAddress) IsStandardScript() bool {
+	switch {
+	// OP_DUP OP_HASH160 <20 bytes> OP_EQUALVERIFY OP_CHECKSIG
+	case len(d) == 25 && d[0] == txscript.OP_DUP &&
+		d[1] == txscript.OP_HASH160 && d[2] == txscript.OP_DATA_20 &&
+		d[23] == txscript.OP_EQUALVERIFY &&
+		d[24] == txscript.OP_CHECKSIG:
+
+		return true
+
+	// OP_HASH160 <20 bytes> OP_EQUAL
+	case len(d) == 23 && d[0] == txscript.OP_HASH160 &&
+		d[1] == txscript.OP_DATA_20 && d[22] == txscript.OP_EQUAL:
+
+		return true
+
+	// OP_0 <20 bytes>
+	case len(d) == 22 && d[0] == txscript.OP_0 &&
+		d[1] == txscript.OP_DATA_20:
+
+		return true
+
+	// OP_0 <32 bytes>
+	case len(d) == 34 && d[0] == txscript.OP_0 &&
+		d[1] == txscript.OP_DATA_32:
+
+		return true
+
+	default:
+		return false
+	}
+}
+
+// IsValidShutdownScript checks whether the delivery address may be used to
+// close a channel. Besides p2pkh, p2sh, p2wpkh and p2wsh, which are always
+// allowed, scripts paying to segwit version 1 through 16, including p2tr, are
+// only allowed if option_shutdown_anysegwit was negotiated, as indicated by
+// anySegwit. ErrAnySegwitNotNegotiated is returned for those otherwise.
+func (d DeliveryAddress) IsValidShutdownScript(anySegwit bool) error {
+	switch {
+	case d.isFutureSegwitScript():
+		if !anySegwit {
+			return ErrAnySegwitNotNegotiated
+		}
+
+		return nil
+
+	case d.IsStandardScript():
+		return nil
+
+	default:
+		return ErrNonStandardDeliveryAddress
+	}
+}
+
+// isFutureSegwitScript returns whether the delivery address pays to a segwit
+// version other than zero: OP_1 through OP_16 followed by a single push of 2
+// to 40 bytes.
+func (d DeliveryAddress) isFutureSegwitScript() bool {
+	if len(d) < 4 || len(d) > deliveryAddressMaxSize {
+		return false
+	}
+
+	if d[0] < txscript.OP_1 || d[0] > txscript.OP_16 {
+		return false
+	}
+
+	return int(d[1]) == len(d)-2
+}
 
 // NewShutdown creates a new Shutdown message.
 func NewShutdown(cid ChannelID, addr DeliveryAddress) *Shutdown {
@@ -39,6 +145,69 @@ func NewShutdown(cid ChannelID, addr DeliveryAddress) *Shutdown {
 	}
 }
 
+// ValidateAgainstUpfront checks that the delivery address of the Shutdown is a
+// valid shutdown script, as checked by IsValidShutdownScript, and, if an
+// upfront shutdown script was negotiated when opening the channel, that it
+// matches it. An empty upfront script means none was negotiated. The
+// anySegwit flag indicates whether option_shutdown_anysegwit was negotiated.
+func (s *Shutdown) ValidateAgainstUpfront(upfront DeliveryAddress,
+	anySegwit bool) error {
+
+	if err := s.Address.IsValidShutdownScript(anySegwit); err != nil {
+		return err
+	}
+
+	if len(upfront) != 0 && !bytes.Equal(upfront, s.Address) {
+		return ErrUpfrontShutdownMismatch
+	}
+
+	return nil
+}
+
+// ShutdownResolution is the outcome of both sides of a channel having sent a
+// Shutdown message.
+type ShutdownResolution struct {
+	// LocalScript is the script our funds will be paid to.
+	LocalScript DeliveryAddress
+
+	// RemoteScript is the script the funds of the remote party will be
+	// paid to.
+	RemoteScript DeliveryAddress
+
+	// LocalInitiator is true if we're to initiate the fee negotiation by
+	// sending the first ClosingSigned, which is the case if we funded the
+	// channel.
+	LocalInitiator bool
+}
+
+// ResolveSimultaneousShutdown resolves the cooperative close of a channel once
+// both sides sent a Shutdown message, regardless of which was sent first. Both
+// delivery addresses must be valid shutdown scripts for the same channel,
+// given whether option_shutdown_anysegwit was negotiated as indicated by
+// anySegwit. As per BOLT #2, the funder of the channel initiates the fee
+// negotiation, so the outcome doesn't depend on the order in which the
+// messages crossed.
+func ResolveSimultaneousShutdown(local, remote *Shutdown, localIsFunder,
+	anySegwit bool) (*ShutdownResolution, error) {
+
+	if local.ChannelID != remote.ChannelID {
+		return nil, ErrShutdownChanIDMismatch
+	}
+
+	if err := local.ValidateAgainstUpfront(nil, anySegwit); err != nil {
+		return nil, fmt.Errorf("local shutdown: %w", err)
+	}
+	if err := remote.ValidateAgainstUpfront(nil, anySegwit); err != nil {
+		return nil, fmt.Errorf("remote shutdown: %w", err)
+	}
+
+	return &ShutdownResolution{
+		LocalScript:    local.Address,
+		RemoteScript:   remote.Address,
+		LocalInitiator: localIsFunder,
+	}, nil
+}
+
 // A compile-time check to ensure Shutdown implements the lnwire.Message
 // interface.
 var _ Message = (*Shutdown)(nil)
@@ -0,0 +1,326 @@
+package lnwire
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestDeliveryAddressIsStandardScript asserts that only the script types
+// always allowed by the spec are considered standard.
+func TestDeliveryAddressIsStandardScript(t *testing.T) {
+	t.Parallel()
+
+	hash20 := bytes.Repeat([]byte{0x01}, 20)
+	hash32 := bytes.Repeat([]byte{0x02}, 32)
+
+	concat := func(parts ...[]byte) DeliveryAddress {
+		return DeliveryAddress(bytes.Join(parts, nil))
+	}
+
+	testCases := []struct {
+		name     string
+		addr     DeliveryAddress
+		standard bool
+	}{
+		{
+			name: "p2pkh",
+			addr: concat(
+				[]byte{0x76, 0xa9, 0x14}, hash20,
+				[]byte{0x88, 0xac},
+			),
+			standard: true,
+		},
+		{
+			name:     "p2sh",
+			addr:     concat([]byte{0xa9, 0x14}, hash20, []byte{0x87}),
+			standard: true,
+		},
+		{
+			name:     "p2wpkh",
+			addr:     concat([]byte{0x00, 0x14}, hash20),
+			standard: true,
+		},
+		{
+			name:     "p2wsh",
+			addr:     concat([]byte{0x00, 0x20}, hash32),
+			standard: true,
+		},
+		{
+			name:     "p2tr",
+			addr:     concat([]byte{0x51, 0x20}, hash32),
+			standard: false,
+		},
+		{
+			name:     "empty",
+			addr:     DeliveryAddress{},
+			standard: false,
+		},
+		{
+			name:     "witness v0 with wrong push length",
+			addr:     concat([]byte{0x00, 0x14}, hash32),
+			standard: false,
+		},
+		{
+			name:     "future witness version",
+			addr:     concat([]byte{0x52, 0x20}, hash32),
+			standard: false,
+		},
+		{
+			name:     "op_return",
+			addr:     concat([]byte{0x6a, 0x14}, hash20),
+			standard: false,
+		},
+	}
+
+	for _, test := range testCases {
+		require.Equal(
+			t, test.standard, test.addr.IsStandardScript(),
+			test.name,
+		)
+	}
+}
+
+// TestDeliveryAddressIsValidShutdownScript asserts that scripts paying to
+// segwit versions above zero are only allowed once option_shutdown_anysegwit
+// was negotiated.
+func TestDeliveryAddressIsValidShutdownScript(t *testing.T) {
+	t.Parallel()
+
+	hash20 := bytes.Repeat([]byte{0x01}, 20)
+	hash32 := bytes.Repeat([]byte{0x02}, 32)
+
+	concat := func(parts ...[]byte) DeliveryAddress {
+		return DeliveryAddress(bytes.Join(parts, nil))
+	}
+
+	testCases := []struct {
+		name         string
+		addr         DeliveryAddress
+		errAnySegwit error
+		errClassic   error
+	}{
+		{
+			name: "p2pkh",
+			addr: concat(
+				[]byte{0x76, 0xa9, 0x14}, hash20,
+				[]byte{0x88, 0xac},
+			),
+		},
+		{
+			name: "p2wpkh",
+			addr: concat([]byte{0x00, 0x14}, hash20),
+		},
+		{
+			name:       "p2tr",
+			addr:       concat([]byte{0x51, 0x20}, hash32),
+			errClassic: ErrAnySegwitNotNegotiated,
+		},
+		{
+			name:       "witness v16 with 2 byte program",
+			addr:       DeliveryAddress{0x60, 0x02, 0x01, 0x02},
+			errClassic: ErrAnySegwitNotNegotiated,
+		},
+		{
+			name: "witness v2 with 40 byte program",
+			addr: concat(
+				[]byte{0x52, 0x28}, hash32, hash20[:8],
+			),
+			errClassic: ErrAnySegwitNotNegotiated,
+		},
+		{
+			name:         "witness v1 with 1 byte program",
+			addr:         DeliveryAddress{0x51, 0x01, 0x01},
+			errAnySegwit: ErrNonStandardDeliveryAddress,
+			errClassic:   ErrNonStandardDeliveryAddress,
+		},
+		{
+			name: "witness v1 with 41 byte program",
+			addr: concat(
+				[]byte{0x51, 0x29}, hash32, hash20[:9],
+			),
+			errAnySegwit: ErrNonStandardDeliveryAddress,
+			errClassic:   ErrNonStandardDeliveryAddress,
+		},
+		{
+			name:         "witness v1 with wrong push length",
+			addr:         concat([]byte{0x51, 0x14}, hash32),
+			errAnySegwit: ErrNonStandardDeliveryAddress,
+			errClassic:   ErrNonStandardDeliveryAddress,
+		},
+		{
+			name:         "op_return",
+			addr:         concat([]byte{0x6a, 0x14}, hash20),
+			errAnySegwit: ErrNonStandardDeliveryAddress,
+			errClassic:   ErrNonStandardDeliveryAddress,
+		},
+	}
+
+	for _, test := range testCases {
+		err := test.addr.IsValidShutdownScript(true)
+		require.Equal(t, test.errAnySegwit, err, test.name)
+
+		err = test.addr.IsValidShutdownScript(false)
+		require.Equal(t, test.errClassic, err, test.name)
+	}
+}
+
+// TestShutdownFutureSegwitRoundTrip asserts that a Shutdown paying to a future
+// segwit version with a 40 byte program, the largest allowed, survives a
+// round trip through the wire, and that a larger script is rejected.
+func TestShutdownFutureSegwitRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	addr := DeliveryAddress(append(
+		[]byte{0x52, 0x28}, bytes.Repeat([]byte{0x03}, 40)...,
+	))
+	require.NoError(t, addr.IsValidShutdownScript(true))
+
+	shutdown := NewShutdown(ChannelID{1}, addr)
+
+	var b bytes.Buffer
+	_, err := WriteMessage(&b, shutdown, 0)
+	require.NoError(t, err)
+
+	msg, err := ReadMessage(bytes.NewReader(b.Bytes()), 0)
+	require.NoError(t, err)
+	require.Equal(t, shutdown, msg)
+
+	// A script exceeding the maximum delivery address size can neither
+	// be written nor read.
+	shutdown.Address = append(addr, 0x00)
+
+	b.Reset()
+	_, err = WriteMessage(&b, shutdown, 0)
+	require.Error(t, err)
+
+	b.Reset()
+	require.NoError(t, WriteElements(&b, ChannelID{1}, shutdown.Address))
+	_, err = ReadMessage(bytes.NewReader(append(
+		[]byte{0x00, byte(MsgShutdown)}, b.Bytes()...,
+	)), 0)
+	require.Error(t, err)
+}
+
+// TestShutdownValidateAgainstUpfront asserts that a Shutdown is only valid if
+// its delivery address is a valid shutdown script and matches any upfront
+// shutdown script.
+func TestShutdownValidateAgainstUpfront(t *testing.T) {
+	t.Parallel()
+
+	p2wpkh := DeliveryAddress(append(
+		[]byte{0x00, 0x14}, bytes.Repeat([]byte{0x01}, 20)...,
+	))
+	otherP2wpkh := DeliveryAddress(append(
+		[]byte{0x00, 0x14}, bytes.Repeat([]byte{0x02}, 20)...,
+	))
+	p2tr := DeliveryAddress(append(
+		[]byte{0x51, 0x20}, bytes.Repeat([]byte{0x03}, 32)...,
+	))
+
+	shutdown := NewShutdown(ChannelID{}, p2wpkh)
+
+	// Without an upfront script, any standard script is allowed.
+	require.NoError(t, shutdown.ValidateAgainstUpfront(nil, false))
+	require.NoError(t, shutdown.ValidateAgainstUpfront(p2wpkh, false))
+	require.Equal(
+		t, ErrUpfrontShutdownMismatch,
+		shutdown.ValidateAgainstUpfront(otherP2wpkh, false),
+	)
+
+	// A taproot script is only allowed once option_shutdown_anysegwit
+	// was negotiated.
+	taproot := NewShutdown(ChannelID{}, p2tr)
+	require.Equal(
+		t, ErrAnySegwitNotNegotiated,
+		taproot.ValidateAgainstUpfront(nil, false),
+	)
+	require.NoError(t, taproot.ValidateAgainstUpfront(p2tr, true))
+
+	// A non-standard script should be rejected even if it matches the
+	// upfront script.
+	nonStandard := NewShutdown(ChannelID{}, DeliveryAddress{0x6a})
+	require.Equal(
+		t, ErrNonStandardDeliveryAddress,
+		nonStandard.ValidateAgainstUpfront(nil, true),
+	)
+	require.Equal(
+		t, ErrNonStandardDeliveryAddress,
+		nonStandard.ValidateAgainstUpfront(nonStandard.Address, true),
+	)
+}
+
+// TestResolveSimultaneousShutdown asserts that crossed Shutdown messages are
+// resolved in the same manner by both sides, with the funder initiating the
+// fee negotiation.
+func TestResolveSimultaneousShutdown(t *testing.T) {
+	t.Parallel()
+
+	chanID := ChannelID{0x01}
+	funderScript := DeliveryAddress(append(
+		[]byte{0x00, 0x14}, bytes.Repeat([]byte{0x01}, 20)...,
+	))
+	fundeeScript := DeliveryAddress(append(
+		[]byte{0x00, 0x20}, bytes.Repeat([]byte{0x02}, 32)...,
+	))
+	funderShutdown := NewShutdown(chanID, funderScript)
+	fundeeShutdown := NewShutdown(chanID, fundeeScript)
+
+	// The funder resolves the race with its own Shutdown as the local one,
+	// and initiates the fee negotiation.
+	res, err := ResolveSimultaneousShutdown(
+		funderShutdown, fundeeShutdown, true, false,
+	)
+	require.NoError(t, err)
+	require.Equal(t, &ShutdownResolution{
+		LocalScript:    funderScript,
+		RemoteScript:   fundeeScript,
+		LocalInitiator: true,
+	}, res)
+
+	// The fundee sees the same messages the other way around, and waits
+	// for the funder to initiate the fee negotiation.
+	res, err = ResolveSimultaneousShutdown(
+		fundeeShutdown, funderShutdown, false, false,
+	)
+	require.NoError(t, err)
+	require.Equal(t, &ShutdownResolution{
+		LocalScript:    fundeeScript,
+		RemoteScript:   funderScript,
+		LocalInitiator: false,
+	}, res)
+
+	// Messages for different channels can't be resolved.
+	otherShutdown := NewShutdown(ChannelID{0x02}, fundeeScript)
+	_, err = ResolveSimultaneousShutdown(
+		funderShutdown, otherShutdown, true, false,
+	)
+	require.Equal(t, ErrShutdownChanIDMismatch, err)
+
+	// A non-standard script on either side is rejected.
+	nonStandard := NewShutdown(chanID, DeliveryAddress{0x6a})
+	_, err = ResolveSimultaneousShutdown(
+		nonStandard, fundeeShutdown, true, true,
+	)
+	require.True(t, errors.Is(err, ErrNonStandardDeliveryAddress))
+	_, err = ResolveSimultaneousShutdown(
+		funderShutdown, nonStandard, true, true,
+	)
+	require.True(t, errors.Is(err, ErrNonStandardDeliveryAddress))
+
+	// A script paying to a future segwit version is only accepted once
+	// option_shutdown_anysegwit was negotiated.
+	futureSegwit := NewShutdown(chanID, DeliveryAddress{
+		0x60, 0x02, 0x01, 0x02,
+	})
+	_, err = ResolveSimultaneousShutdown(
+		funderShutdown, futureSegwit, true, false,
+	)
+	require.True(t, errors.Is(err, ErrAnySegwitNotNegotiated))
+	_, err = ResolveSimultaneousShutdown(
+		funderShutdown, futureSegwit, true, true,
+	)
+	require.NoError(t, err)
+}
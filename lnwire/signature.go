@@ -2,11 +2,31 @@ package lnwire
 
 import (
 	"fmt"
+	"io"
+	"math/big"
 
 	"github.com/btcsuite/btcd/btcec"
 	"github.com/lightningnetwork/lnd/input"
 )
 
+// secp256k1HalfOrder is half the order of the secp256k1 curve, the largest S
+// value a low-S signature may have.
+var secp256k1HalfOrder = new(big.Int).Rsh(btcec.S256().N, 1)
+
+// ErrHighSSig is returned when a message carries a signature whose S value
+// isn't low-S normalized.
+type ErrHighSSig struct {
+	msgType MessageType
+}
+
+// Error returns a human readable string describing the error.
+//
+// NOTE: implements the error interface.
+func (e ErrHighSSig) Error() string {
+	return fmt.Sprintf("message of type %v carries signature that isn't "+
+		"low-S", e.msgType)
+}
+
 // Sig is a fixed-sized ECDSA signature. Unlike Bitcoin, we use fixed sized
 // signatures on the wire, instead of DER encoded signatures. This type
 // provides several methods to convert to/from a regular Bitcoin DER encoded
@@ -111,6 +131,69 @@ func (b *Sig) ToSignatureBytes() []byte {
 	return sigBytes
 }
 
+// IsLowS returns true if the S value of the signature is at most half the
+// order of the curve. Only low-S signatures are accepted, as negating S yields
+// another valid signature for the same message, which would make signatures
+// malleable.
+func (b *Sig) IsLowS() bool {
+	s := new(big.Int).SetBytes(b[32:64])
+	return s.Cmp(secp256k1HalfOrder) <= 0
+}
+
+// messageSigs returns all signatures carried by the message.
+func messageSigs(msg Message) []Sig {
+	switch m := msg.(type) {
+	case *ChannelAnnouncement:
+		return []Sig{
+			m.NodeSig1, m.NodeSig2, m.BitcoinSig1, m.BitcoinSig2,
+		}
+	case *NodeAnnouncement:
+		return []Sig{m.Signature}
+	case *ChannelUpdate:
+		return []Sig{m.Signature}
+	case *AnnounceSignatures:
+		return []Sig{m.NodeSignature, m.BitcoinSignature}
+	case *CommitSig:
+		return append([]Sig{m.CommitSig}, m.HtlcSigs...)
+	case *FundingCreated:
+		return []Sig{m.CommitSig}
+	case *FundingSigned:
+		return []Sig{m.CommitSig}
+	case *ClosingSigned:
+		return []Sig{m.Signature}
+	default:
+		return nil
+	}
+}
+
+// ValidateLowS returns ErrHighSSig if any of the signatures carried by the
+// message isn't low-S normalized.
+func ValidateLowS(msg Message) error {
+	for _, sig := range messageSigs(msg) {
+		if !sig.IsLowS() {
+			return ErrHighSSig{msgType: msg.MsgType()}
+		}
+	}
+
+	return nil
+}
+
+// ReadMessageLowS reads the next message from r like ReadMessage, but rejects
+// it with ErrHighSSig if any of its signatures isn't low-S normalized. This
+// prevents relaying or storing a malleated, yet otherwise valid, signature.
+func ReadMessageLowS(r io.Reader, pver uint32) (Message, error) {
+	msg, err := ReadMessage(r, pver)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ValidateLowS(msg); err != nil {
+		return nil, err
+	}
+
+	return msg, nil
+}
+
 // extractCanonicalPadding is a utility function to extract the canonical
 // padding of a big-endian integer from the wire encoding (a 0-padded
 // big-endian integer) such that it passes btcec.canonicalPadding test.
@@ -1,6 +1,7 @@
 package lnwire
 
 import (
+	"bytes"
 	"fmt"
 	"math/big"
 	"testing"
@@ -88,3 +89,68 @@ func TestSignatureSerializeDeserialize(t *testing.T) {
 			err.Error())
 	}
 }
+
+// TestSigIsLowS asserts that only signatures with an S value of at most half
+// the curve order are considered low-S, and that messages carrying a high-S
+// signature are rejected when read with ReadMessageLowS.
+func TestSigIsLowS(t *testing.T) {
+	t.Parallel()
+
+	newSig := func(s *big.Int) Sig {
+		var sig Sig
+		sig[31] = 1
+		sBytes := s.Bytes()
+		copy(sig[64-len(sBytes):], sBytes)
+		return sig
+	}
+
+	halfOrder := new(big.Int).Rsh(btcec.S256().N, 1)
+	lowS := newSig(halfOrder)
+	highS := newSig(new(big.Int).Add(halfOrder, big.NewInt(1)))
+
+	if !lowS.IsLowS() {
+		t.Fatalf("expected S = N>>1 to be low-S")
+	}
+	if highS.IsLowS() {
+		t.Fatalf("expected S = N>>1 + 1 to be high-S")
+	}
+
+	update := &ChannelUpdate{Signature: lowS}
+	commitSig := &CommitSig{
+		CommitSig: lowS,
+		HtlcSigs:  []Sig{lowS, lowS},
+	}
+	for _, msg := range []Message{update, commitSig} {
+		var b bytes.Buffer
+		if _, err := WriteMessage(&b, msg, 0); err != nil {
+			t.Fatalf("unable to write msg: %v", err)
+		}
+		if _, err := ReadMessageLowS(&b, 0); err != nil {
+			t.Fatalf("unable to read low-S %v: %v", msg.MsgType(),
+				err)
+		}
+	}
+
+	// Swapping in a single high-S signature should cause the message to
+	// be rejected, while it's still accepted by ReadMessage.
+	update.Signature = highS
+	commitSig.HtlcSigs[1] = highS
+	for _, msg := range []Message{update, commitSig} {
+		var b bytes.Buffer
+		if _, err := WriteMessage(&b, msg, 0); err != nil {
+			t.Fatalf("unable to write msg: %v", err)
+		}
+		encoded := b.Bytes()
+
+		_, err := ReadMessageLowS(bytes.NewReader(encoded), 0)
+		if _, ok := err.(ErrHighSSig); !ok {
+			t.Fatalf("expected ErrHighSSig for %v, got %v",
+				msg.MsgType(), err)
+		}
+
+		_, err = ReadMessage(bytes.NewReader(encoded), 0)
+		if err != nil {
+			t.Fatalf("unable to read %v: %v", msg.MsgType(), err)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package lnwire
+
+import "github.com/btcsuite/btcd/chaincfg/chainhash"
+
+// SignedGossipMessage is a gossip message carrying signatures over its own
+// contents, allowing them to be signed and verified without regard to the
+// concrete message type.
+//
+// NOTE: AnnounceSignatures isn't a SignedGossipMessage, as the signatures it
+// carries cover the ChannelAnnouncement of the channel rather than the
+// AnnounceSignatures itself.
+type SignedGossipMessage interface {
+	Message
+
+	// DataToSign returns the serialized message excluding its signatures,
+	// but including any extra opaque data, which is what the signatures
+	// commit to.
+	DataToSign() ([]byte, error)
+
+	// DigestToSign returns the double-sha256 of DataToSign, which is the
+	// digest the signatures are produced over.
+	DigestToSign() ([]byte, error)
+}
+
+// A compile time check to ensure ChannelAnnouncement implements the
+// lnwire.SignedGossipMessage interface.
+var _ SignedGossipMessage = (*ChannelAnnouncement)(nil)
+
+// A compile time check to ensure NodeAnnouncement implements the
+// lnwire.SignedGossipMessage interface.
+var _ SignedGossipMessage = (*NodeAnnouncement)(nil)
+
+// A compile time check to ensure ChannelUpdate implements the
+// lnwire.SignedGossipMessage interface.
+var _ SignedGossipMessage = (*ChannelUpdate)(nil)
+
+// digestToSign returns the double-sha256 of the data to sign of msg.
+func digestToSign(msg SignedGossipMessage) ([]byte, error) {
+	data, err := msg.DataToSign()
+	if err != nil {
+		return nil, err
+	}
+
+	return chainhash.DoubleHashB(data), nil
+}
+
+// DigestToSign returns the digest all four signatures of the announcement are
+// produced over.
+//
+// This is part of the lnwire.SignedGossipMessage interface.
+func (a *ChannelAnnouncement) DigestToSign() ([]byte, error) {
+	return digestToSign(a)
+}
+
+// DigestToSign returns the digest the signature of the announcement is
+// produced over.
+//
+// This is part of the lnwire.SignedGossipMessage interface.
+func (a *NodeAnnouncement) DigestToSign() ([]byte, error) {
+	return digestToSign(a)
+}
+
+// DigestToSign returns the digest the signature of the update is produced
+// over.
+//
+// This is part of the lnwire.SignedGossipMessage interface.
+func (a *ChannelUpdate) DigestToSign() ([]byte, error) {
+	return digestToSign(a)
+}
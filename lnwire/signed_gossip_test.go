@@ -0,0 +1,69 @@
+package lnwire
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/stretchr/testify/require"
+)
+
+// TestDigestToSign asserts that the digest of each signed gossip message
+// covers its extra opaque data, but not its signatures.
+func TestDigestToSign(t *testing.T) {
+	t.Parallel()
+
+	sig := Sig{0x01}
+	chanAnn := &ChannelAnnouncement{Features: NewRawFeatureVector()}
+	nodeAnn := &NodeAnnouncement{Features: NewRawFeatureVector()}
+	update := &ChannelUpdate{}
+
+	msgs := []struct {
+		msg    SignedGossipMessage
+		setSig func()
+		extra  *ExtraOpaqueData
+	}{
+		{
+			msg: chanAnn,
+			setSig: func() {
+				chanAnn.NodeSig1 = sig
+				chanAnn.BitcoinSig2 = sig
+			},
+			extra: &chanAnn.ExtraOpaqueData,
+		},
+		{
+			msg: nodeAnn,
+			setSig: func() {
+				nodeAnn.Signature = sig
+			},
+			extra: &nodeAnn.ExtraOpaqueData,
+		},
+		{
+			msg: update,
+			setSig: func() {
+				update.Signature = sig
+			},
+			extra: &update.ExtraOpaqueData,
+		},
+	}
+
+	for _, m := range msgs {
+		data, err := m.msg.DataToSign()
+		require.NoError(t, err)
+
+		digest, err := m.msg.DigestToSign()
+		require.NoError(t, err)
+		require.Equal(t, chainhash.DoubleHashB(data), digest)
+
+		// Setting the signatures mustn't change the digest.
+		m.setSig()
+		sigDigest, err := m.msg.DigestToSign()
+		require.NoError(t, err)
+		require.Equal(t, digest, sigDigest)
+
+		// Adding extra opaque data must change it.
+		*m.extra = ExtraOpaqueData{0x01, 0x00}
+		extraDigest, err := m.msg.DigestToSign()
+		require.NoError(t, err)
+		require.NotEqual(t, digest, extraDigest)
+	}
+}
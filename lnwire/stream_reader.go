@@ -0,0 +1,164 @@
+package lnwire
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrShortFrame is returned when reading a framed message whose length prefix
+// is too short for the frame to even hold the message type.
+var ErrShortFrame = errors.New("message frame too short to hold its type")
+
+// ErrMalformedFrame is returned by a StreamReader when a message of the stream
+// can't be decoded. As the message is framed by its length prefix, the stream
+// remains readable past it.
+type ErrMalformedFrame struct {
+	index int
+	err   error
+}
+
+// Error returns a human readable string describing the error.
+//
+// NOTE: implements the error interface.
+func (e ErrMalformedFrame) Error() string {
+	return fmt.Sprintf("unable to decode message %d of stream: %v",
+		e.index, e.err)
+}
+
+// Unwrap returns the error encountered while decoding the message.
+func (e ErrMalformedFrame) Unwrap() error {
+	return e.err
+}
+
+// Index returns the position of the malformed message within the stream,
+// counting skipped messages as well.
+func (e ErrMalformedFrame) Index() int {
+	return e.index
+}
+
+// StreamReader reads a stream of messages that are each prefixed by their
+// length as a 2-byte big-endian integer, such as a capture of the messages
+// exchanged with a peer. Since the length prefix frames each message, a
+// message that fails to decode doesn't prevent the messages following it from
+// being read, which allows partially corrupt captures to be analyzed.
+type StreamReader struct {
+	r    io.Reader
+	pver uint32
+
+	// numFrames is the number of frames read from the stream so far.
+	numFrames int
+}
+
+// NewStreamReader creates a new StreamReader reading messages from r,
+// observing the specified protocol version.
+func NewStreamReader(r io.Reader, pver uint32) *StreamReader {
+	return &StreamReader{
+		r:    r,
+		pver: pver,
+	}
+}
+
+// Next reads the next message of the stream. If the message can't be decoded,
+// ErrMalformedFrame is returned and the reader moves on to the following
+// message, so Next can be called again. Messages of an unknown odd type are
+// skipped, as we're free to ignore them. Once the stream ends at a frame
+// boundary io.EOF is returned, while a stream ending mid-frame results in
+// io.ErrUnexpectedEOF, neither of which can be recovered from.
+func (s *StreamReader) Next() (Message, error) {
+	for {
+		var l [2]byte
+		if _, err := io.ReadFull(s.r, l[:]); err != nil {
+			return nil, err
+		}
+
+		frame := make([]byte, binary.BigEndian.Uint16(l[:]))
+		if _, err := io.ReadFull(s.r, frame); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+
+		index := s.numFrames
+		s.numFrames++
+
+		msg, err := ReadFramedMessage(frame, s.pver)
+		if err == nil {
+			return msg, nil
+		}
+
+		var unknownErr *UnknownMessage
+		if errors.As(err, &unknownErr) &&
+			unknownErr.messageType%2 == 1 {
+
+			continue
+		}
+
+		return nil, ErrMalformedFrame{index: index, err: err}
+	}
+}
+
+// ReadMessageAt reads the message framed in the same manner as those read by a
+// StreamReader that starts at the given offset of r, observing the specified
+// protocol version. The message is returned along with the number of bytes its
+// frame takes up, including the length prefix, which is the offset of the
+// following message relative to this one. This allows a store backed by a
+// memory-mapped file to iterate over its messages without copying them into
+// intermediate buffers. io.EOF is returned if the offset is at the end of r.
+// As the length of the frame is known, a message exceeding the size limit of
+// its type is rejected with ErrMsgTooLarge before being decoded.
+func ReadMessageAt(r io.ReaderAt, offset int64, pver uint32) (Message, int,
+	error) {
+
+	var l [2]byte
+	n, err := r.ReadAt(l[:], offset)
+	switch {
+	// A reader is allowed to report the end of its data along with the
+	// final bytes, so we'll only fail if we didn't get all of them.
+	case n == len(l):
+
+	case n == 0 && err == io.EOF:
+		return nil, 0, io.EOF
+
+	case err == io.EOF:
+		return nil, 0, io.ErrUnexpectedEOF
+
+	default:
+		return nil, 0, err
+	}
+
+	frameLen := int(binary.BigEndian.Uint16(l[:]))
+	if frameLen < 2 {
+		return nil, 0, ErrShortFrame
+	}
+	frame := io.NewSectionReader(r, offset+int64(len(l)), int64(frameLen))
+
+	// With the frame at hand, we'll make sure the message isn't larger
+	// than its type allows before decoding it, as its trailing bytes
+	// would otherwise go unnoticed.
+	var mType [2]byte
+	n, err = frame.ReadAt(mType[:], 0)
+	switch {
+	case n == len(mType):
+
+	case err == io.EOF:
+		return nil, 0, io.ErrUnexpectedEOF
+
+	default:
+		return nil, 0, err
+	}
+
+	msgType := MessageType(binary.BigEndian.Uint16(mType[:]))
+	if err := CheckMsgSize(msgType, frameLen-len(mType)); err != nil {
+		return nil, 0, err
+	}
+
+	msg, err := ReadMessage(frame, pver)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return msg, len(l) + frameLen, nil
+}
@@ -0,0 +1,158 @@
+package lnwire
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestStreamReader asserts that a StreamReader recovers from malformed
+// messages of a stream, skips messages of unknown odd types, and reports the
+// end of the stream.
+func TestStreamReader(t *testing.T) {
+	t.Parallel()
+
+	var stream bytes.Buffer
+	writeFrame := func(frame []byte) {
+		var l [2]byte
+		binary.BigEndian.PutUint16(l[:], uint16(len(frame)))
+		stream.Write(l[:])
+		stream.Write(frame)
+	}
+	writeMsg := func(msg Message) {
+		var b bytes.Buffer
+		_, err := WriteMessage(&b, msg, 0)
+		require.NoError(t, err)
+		writeFrame(b.Bytes())
+	}
+
+	ping := &Ping{NumPongBytes: 10, PaddingBytes: PingPayload{}}
+	pong := NewPong([]byte{1, 2, 3})
+	fulfill := NewUpdateFulfillHTLC(ChannelID{1}, 2, [32]byte{3})
+
+	// A truncated message, followed by messages of an unknown odd and an
+	// unknown even type.
+	var truncated bytes.Buffer
+	_, err := WriteMessage(&truncated, fulfill, 0)
+	require.NoError(t, err)
+
+	writeMsg(ping)
+	writeFrame(truncated.Bytes()[:10])
+	writeFrame([]byte{0xff, 0xfd, 0x01})
+	writeMsg(pong)
+	writeFrame([]byte{0xff, 0xfe, 0x01})
+	writeMsg(fulfill)
+
+	r := NewStreamReader(&stream, 0)
+
+	msg, err := r.Next()
+	require.NoError(t, err)
+	require.Equal(t, ping, msg)
+
+	_, err = r.Next()
+	var frameErr ErrMalformedFrame
+	require.True(t, errors.As(err, &frameErr))
+	require.Equal(t, 1, frameErr.Index())
+
+	// The message of an unknown odd type is skipped.
+	msg, err = r.Next()
+	require.NoError(t, err)
+	require.Equal(t, pong, msg)
+
+	_, err = r.Next()
+	require.True(t, errors.As(err, &frameErr))
+	require.Equal(t, 4, frameErr.Index())
+
+	msg, err = r.Next()
+	require.NoError(t, err)
+	require.Equal(t, fulfill, msg)
+
+	_, err = r.Next()
+	require.Equal(t, io.EOF, err)
+
+	// A stream ending within a frame can't be recovered from.
+	writeFrame(truncated.Bytes())
+	stream.Truncate(stream.Len() - 1)
+
+	_, err = r.Next()
+	require.Equal(t, io.ErrUnexpectedEOF, err)
+}
+
+// TestReadMessageAt asserts that the framed messages of a stream can be read
+// by their offsets.
+func TestReadMessageAt(t *testing.T) {
+	t.Parallel()
+
+	msgs := []Message{
+		&Ping{NumPongBytes: 10, PaddingBytes: PingPayload{}},
+		NewPong([]byte{1, 2, 3}),
+		NewUpdateFulfillHTLC(ChannelID{1}, 2, [32]byte{3}),
+	}
+
+	var stream bytes.Buffer
+	for _, msg := range msgs {
+		var b bytes.Buffer
+		_, err := WriteMessage(&b, msg, 0)
+		require.NoError(t, err)
+
+		var l [2]byte
+		binary.BigEndian.PutUint16(l[:], uint16(b.Len()))
+		stream.Write(l[:])
+		stream.Write(b.Bytes())
+	}
+	r := bytes.NewReader(stream.Bytes())
+
+	var offset, lastOffset int64
+	for _, expected := range msgs {
+		msg, n, err := ReadMessageAt(r, offset, 0)
+		require.NoError(t, err)
+		require.Equal(t, expected, msg)
+
+		lastOffset = offset
+		offset += int64(n)
+	}
+
+	_, _, err := ReadMessageAt(r, offset, 0)
+	require.Equal(t, io.EOF, err)
+
+	// An offset within the length prefix of a frame, or a frame cut short,
+	// results in an error.
+	_, _, err = ReadMessageAt(r, offset-1, 0)
+	require.Equal(t, io.ErrUnexpectedEOF, err)
+
+	truncated := bytes.NewReader(stream.Bytes()[:stream.Len()-1])
+	_, _, err = ReadMessageAt(truncated, lastOffset, 0)
+	var partialErr PartialMessageError
+	require.True(t, errors.As(err, &partialErr))
+
+	// A frame too short to hold the message type is rejected.
+	shortFrame := bytes.NewReader([]byte{0x00, 0x01, 0x00})
+	_, _, err = ReadMessageAt(shortFrame, 0, 0)
+	require.Equal(t, ErrShortFrame, err)
+
+	// A frame exceeding the size limit of its message type is rejected,
+	// even though decoding the message wouldn't reach its trailing bytes.
+	fulfill := NewUpdateFulfillHTLC(ChannelID{1}, 2, [32]byte{3})
+	var b bytes.Buffer
+	_, err = WriteMessage(&b, fulfill, 0)
+	require.NoError(t, err)
+	b.Write(make([]byte, MaxMsgSize(MsgUpdateFulfillHTLC)))
+
+	var bloated bytes.Buffer
+	var l [2]byte
+	binary.BigEndian.PutUint16(l[:], uint16(b.Len()))
+	bloated.Write(l[:])
+	bloated.Write(b.Bytes())
+
+	_, _, err = ReadMessageAt(bytes.NewReader(bloated.Bytes()), 0, 0)
+	require.IsType(t, ErrMsgTooLarge{}, err)
+
+	_, err = NewStreamReader(&bloated, 0).Next()
+	var malformedErr ErrMalformedFrame
+	require.True(t, errors.As(err, &malformedErr))
+	require.IsType(t, ErrMsgTooLarge{}, malformedErr.Unwrap())
+}
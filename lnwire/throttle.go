@@ -0,0 +1,129 @@
+package lnwire
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+var (
+	// ErrMsgRateExceeded is returned by a ThrottledReader when a peer sends
+	// more messages than its budget allows.
+	ErrMsgRateExceeded = errors.New("peer exceeded message rate limit")
+
+	// ErrByteRateExceeded is returned by a ThrottledReader when a peer
+	// sends more bytes than its budget allows.
+	ErrByteRateExceeded = errors.New("peer exceeded byte rate limit")
+)
+
+// ThrottleConfig houses the budget of a peer enforced by a ThrottledReader. A
+// zero rate disables the respective limit.
+type ThrottleConfig struct {
+	// MsgsPerSecond is the number of messages per second a peer may send
+	// on average.
+	MsgsPerSecond float64
+
+	// MsgBurst is the number of messages a peer may send at once before
+	// MsgsPerSecond is enforced.
+	MsgBurst int
+
+	// BytesPerSecond is the number of bytes per second a peer may send on
+	// average.
+	BytesPerSecond float64
+
+	// ByteBurst is the number of bytes a peer may send at once before
+	// BytesPerSecond is enforced. It's raised to MaxMessagePayload if
+	// lower, so that any single valid message can be read.
+	ByteBurst int
+}
+
+// ThrottledReader reads messages like ReadMessage, while enforcing a per-peer
+// budget of messages and bytes per second using token buckets. Once the budget
+// is exhausted, an error is returned rather than blocking, so that the
+// transport can disconnect an abusive peer.
+type ThrottledReader struct {
+	msgLimiter  *rate.Limiter
+	byteLimiter *rate.Limiter
+
+	// now returns the current time, and can be overridden in tests.
+	now func() time.Time
+
+	mtx sync.Mutex
+}
+
+// NewThrottledReader creates a new ThrottledReader enforcing the given budget.
+func NewThrottledReader(cfg ThrottleConfig) *ThrottledReader {
+	msgLimit := rate.Inf
+	if cfg.MsgsPerSecond > 0 {
+		msgLimit = rate.Limit(cfg.MsgsPerSecond)
+	}
+
+	byteLimit := rate.Inf
+	if cfg.BytesPerSecond > 0 {
+		byteLimit = rate.Limit(cfg.BytesPerSecond)
+	}
+
+	msgBurst := cfg.MsgBurst
+	if msgBurst < 1 {
+		msgBurst = 1
+	}
+
+	byteBurst := cfg.ByteBurst
+	if byteBurst < MaxMessagePayload {
+		byteBurst = MaxMessagePayload
+	}
+
+	return &ThrottledReader{
+		msgLimiter:  rate.NewLimiter(msgLimit, msgBurst),
+		byteLimiter: rate.NewLimiter(byteLimit, byteBurst),
+		now:         time.Now,
+	}
+}
+
+// ReadMessage reads the next message from r, observing the specified protocol
+// version. If the peer exceeded its message budget, ErrMsgRateExceeded is
+// returned before the message is decoded, so a flood of messages doesn't cost
+// us the effort of parsing them. If decoding the message exceeded the byte
+// budget of the peer, ErrByteRateExceeded is returned instead of the message.
+func (t *ThrottledReader) ReadMessage(r io.Reader, pver uint32) (Message,
+	error) {
+
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	if !t.msgLimiter.AllowN(t.now(), 1) {
+		return nil, ErrMsgRateExceeded
+	}
+
+	cr := &countingReader{r: r}
+	msg, err := ReadMessage(cr, pver)
+
+	// Any bytes we read count against the budget, even if the message
+	// turned out to be invalid.
+	if cr.n > 0 && !t.byteLimiter.AllowN(t.now(), cr.n) {
+		return nil, ErrByteRateExceeded
+	}
+
+	return msg, err
+}
+
+// countingReader wraps an io.Reader, counting the number of bytes read through
+// it.
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+// Read reads from the underlying reader, adding the number of bytes read to
+// the count.
+//
+// NOTE: implements the io.Reader interface.
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += n
+
+	return n, err
+}
@@ -0,0 +1,79 @@
+package lnwire
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestThrottledReader simulates a peer flooding us with messages, and asserts
+// that the throttle kicks in once the message and byte budgets are exhausted,
+// and lifts again once the peer backs off.
+func TestThrottledReader(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1600000000, 0)
+	newReader := func(cfg ThrottleConfig) *ThrottledReader {
+		reader := NewThrottledReader(cfg)
+		reader.now = func() time.Time {
+			return now
+		}
+		return reader
+	}
+
+	var ping bytes.Buffer
+	_, err := WriteMessage(&ping, NewPing(10), 0)
+	require.NoError(t, err)
+	pingBytes := ping.Bytes()
+
+	readPing := func(reader *ThrottledReader) error {
+		_, err := reader.ReadMessage(bytes.NewReader(pingBytes), 0)
+		return err
+	}
+
+	// Without any limits, a flood of messages is read just fine.
+	reader := newReader(ThrottleConfig{})
+	for i := 0; i < 1000; i++ {
+		require.NoError(t, readPing(reader))
+	}
+
+	// With a budget of 10 messages per second and a burst of 5, only the
+	// first 5 messages of a flood are read.
+	reader = newReader(ThrottleConfig{
+		MsgsPerSecond: 10,
+		MsgBurst:      5,
+	})
+	for i := 0; i < 5; i++ {
+		require.NoError(t, readPing(reader))
+	}
+	require.Equal(t, ErrMsgRateExceeded, readPing(reader))
+
+	// Once the peer backs off for a tenth of a second, another message is
+	// allowed, but only one.
+	now = now.Add(100 * time.Millisecond)
+	require.NoError(t, readPing(reader))
+	require.Equal(t, ErrMsgRateExceeded, readPing(reader))
+
+	// The byte budget is enforced in the same manner, with the burst
+	// raised to allow a message of the maximum size.
+	reader = newReader(ThrottleConfig{
+		BytesPerSecond: 1000,
+		ByteBurst:      1,
+	})
+	require.Equal(t, MaxMessagePayload, reader.byteLimiter.Burst())
+
+	numAllowed := 0
+	for i := 0; i < MaxMessagePayload; i++ {
+		if readPing(reader) != nil {
+			break
+		}
+		numAllowed++
+	}
+	require.Equal(t, MaxMessagePayload/len(pingBytes), numAllowed)
+	require.Equal(t, ErrByteRateExceeded, readPing(reader))
+
+	now = now.Add(time.Second)
+	require.NoError(t, readPing(reader))
+}
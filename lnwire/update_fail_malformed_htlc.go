@@ -2,9 +2,25 @@ package lnwire
 
 import (
 	"crypto/sha256"
+	"fmt"
 	"io"
 )
 
+// ErrInvalidMalformedFailureCode is returned when the failure code of an
+// UpdateFailMalformedHTLC doesn't have the BADONION flag set, which the spec
+// requires for all malformed HTLC failures.
+type ErrInvalidMalformedFailureCode struct {
+	code FailCode
+}
+
+// Error returns a human readable string describing the error.
+//
+// NOTE: implements the error interface.
+func (e ErrInvalidMalformedFailureCode) Error() string {
+	return fmt.Sprintf("failure code %v (0x%04x) of malformed htlc is "+
+		"missing BADONION flag", e.code, uint16(e.code))
+}
+
 // UpdateFailMalformedHTLC is sent by either the payment forwarder or by
 // payment receiver to the payment sender in order to notify it that the onion
 // blob can't be parsed. For that reason we send this message instead of
@@ -26,6 +42,32 @@ type UpdateFailMalformedHTLC struct {
 	FailureCode FailCode
 }
 
+// NewBlindedFailMalformedHTLC creates a new UpdateFailMalformedHTLC for an HTLC
+// that was received over a blinded route. Nodes within a blinded route must
+// report any failure as invalid_onion_blinding, along with the hash of the
+// onion they received, to avoid revealing which hop failed.
+func NewBlindedFailMalformedHTLC(chanID ChannelID, id uint64,
+	onionBlob []byte) *UpdateFailMalformedHTLC {
+
+	return &UpdateFailMalformedHTLC{
+		ChanID:       chanID,
+		ID:           id,
+		ShaOnionBlob: sha256.Sum256(onionBlob),
+		FailureCode:  CodeInvalidBlinding,
+	}
+}
+
+// ValidateFailureCode ensures that the failure code of the message has the
+// BADONION flag set. Receiving a malformed HTLC failure without it is a
+// protocol violation.
+func (c *UpdateFailMalformedHTLC) ValidateFailureCode() error {
+	if c.FailureCode&FlagBadOnion == 0 {
+		return ErrInvalidMalformedFailureCode{code: c.FailureCode}
+	}
+
+	return nil
+}
+
 // A compile time check to ensure UpdateFailMalformedHTLC implements the
 // lnwire.Message interface.
 var _ Message = (*UpdateFailMalformedHTLC)(nil)
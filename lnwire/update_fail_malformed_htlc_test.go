@@ -0,0 +1,47 @@
+package lnwire
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestBlindedFailMalformedHTLC asserts that a malformed failure for a blinded
+// HTLC carries the invalid blinding code and the hash of the onion, and that it
+// survives a round trip.
+func TestBlindedFailMalformedHTLC(t *testing.T) {
+	t.Parallel()
+
+	onionBlob := bytes.Repeat([]byte{0x01}, OnionPacketSize)
+	msg := NewBlindedFailMalformedHTLC(ChannelID{2}, 5, onionBlob)
+
+	require.Equal(t, CodeInvalidBlinding, msg.FailureCode)
+	require.Equal(t, sha256.Sum256(onionBlob), msg.ShaOnionBlob)
+	require.NoError(t, msg.ValidateFailureCode())
+
+	var b bytes.Buffer
+	_, err := WriteMessage(&b, msg, 0)
+	require.NoError(t, err)
+
+	decoded, err := ReadMessage(&b, 0)
+	require.NoError(t, err)
+	require.Equal(t, msg, decoded)
+}
+
+// TestUpdateFailMalformedHTLCValidateFailureCode asserts that failure codes
+// without the BADONION flag are rejected.
+func TestUpdateFailMalformedHTLCValidateFailureCode(t *testing.T) {
+	t.Parallel()
+
+	msg := &UpdateFailMalformedHTLC{
+		FailureCode: CodeInvalidOnionHmac,
+	}
+	require.NoError(t, msg.ValidateFailureCode())
+
+	msg.FailureCode = CodeTemporaryChannelFailure
+	require.IsType(
+		t, ErrInvalidMalformedFailureCode{}, msg.ValidateFailureCode(),
+	)
+}
@@ -1,9 +1,15 @@
 package lnwire
 
 import (
+	"errors"
 	"io"
 )
 
+// ErrUpdateFeeFromNonFunder is returned when an UpdateFee is received from the
+// party that didn't fund the channel, which isn't allowed to send one.
+var ErrUpdateFeeFromNonFunder = errors.New("update_fee sent by " +
+	"non-funder of channel")
+
 // UpdateFee is the message the channel initiator sends to the other peer if
 // the channel commitment fee needs to be updated.
 type UpdateFee struct {
@@ -26,6 +32,17 @@ func NewUpdateFee(chanID ChannelID, feePerKw uint32) *UpdateFee {
 	}
 }
 
+// ValidateUpdateFeeSender ensures that the sender of the UpdateFee is allowed
+// to send it. As per BOLT #2, only the funder of the channel may update its
+// fee, so ErrUpdateFeeFromNonFunder is returned if senderIsFunder is false.
+func (c *UpdateFee) ValidateUpdateFeeSender(senderIsFunder bool) error {
+	if !senderIsFunder {
+		return ErrUpdateFeeFromNonFunder
+	}
+
+	return nil
+}
+
 // A compile time check to ensure UpdateFee implements the lnwire.Message
 // interface.
 var _ Message = (*UpdateFee)(nil)
@@ -0,0 +1,19 @@
+package lnwire
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestUpdateFeeValidateSender asserts that only the funder of a channel may
+// send an UpdateFee.
+func TestUpdateFeeValidateSender(t *testing.T) {
+	t.Parallel()
+
+	msg := NewUpdateFee(ChannelID{1}, 253)
+	require.NoError(t, msg.ValidateUpdateFeeSender(true))
+	require.Equal(
+		t, ErrUpdateFeeFromNonFunder, msg.ValidateUpdateFeeSender(false),
+	)
+}
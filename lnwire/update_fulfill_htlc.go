@@ -1,9 +1,28 @@
 package lnwire
 
 import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
 	"io"
 )
 
+// ErrPreimageMismatch is returned when the preimage of an UpdateFulfillHTLC
+// doesn't hash to the payment hash of the HTLC it claims to settle.
+type ErrPreimageMismatch struct {
+	chanID      ChannelID
+	htlcID      uint64
+	paymentHash [32]byte
+}
+
+// Error returns a human readable string describing the error.
+//
+// NOTE: implements the error interface.
+func (e ErrPreimageMismatch) Error() string {
+	return fmt.Sprintf("preimage for htlc %d of channel %v doesn't match "+
+		"payment hash %x", e.htlcID, e.chanID, e.paymentHash)
+}
+
 // UpdateFulfillHTLC is sent by Alice to Bob when she wishes to settle a
 // particular HTLC referenced by its HTLCKey within a specific active channel
 // referenced by ChannelPoint.  A subsequent CommitSig message will be sent by
@@ -34,6 +53,23 @@ func NewUpdateFulfillHTLC(chanID ChannelID, id uint64,
 	}
 }
 
+// VerifyPreimage checks that the preimage carried by the message hashes to the
+// given payment hash, returning ErrPreimageMismatch otherwise. The comparison
+// is done in constant time. An HTLC must never be settled without this check
+// succeeding.
+func (c *UpdateFulfillHTLC) VerifyPreimage(paymentHash [32]byte) error {
+	hash := sha256.Sum256(c.PaymentPreimage[:])
+	if subtle.ConstantTimeCompare(hash[:], paymentHash[:]) != 1 {
+		return ErrPreimageMismatch{
+			chanID:      c.ChanID,
+			htlcID:      c.ID,
+			paymentHash: paymentHash,
+		}
+	}
+
+	return nil
+}
+
 // A compile time check to ensure UpdateFulfillHTLC implements the lnwire.Message
 // interface.
 var _ Message = (*UpdateFulfillHTLC)(nil)
@@ -0,0 +1,31 @@
+package lnwire
+
+import (
+	"crypto/sha256"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestUpdateFulfillHTLCVerifyPreimage asserts that only the payment hash of
+// the preimage carried by an UpdateFulfillHTLC is accepted.
+func TestUpdateFulfillHTLCVerifyPreimage(t *testing.T) {
+	t.Parallel()
+
+	var preimage [32]byte
+	copy(preimage[:], "a preimage known to the recipient")
+	paymentHash := sha256.Sum256(preimage[:])
+
+	msg := NewUpdateFulfillHTLC(ChannelID{1}, 7, preimage)
+	require.NoError(t, msg.VerifyPreimage(paymentHash))
+
+	// Hashing the preimage twice, or flipping a single bit of the hash,
+	// must both be rejected.
+	doubleHash := sha256.Sum256(paymentHash[:])
+	err := msg.VerifyPreimage(doubleHash)
+	require.IsType(t, ErrPreimageMismatch{}, err)
+
+	paymentHash[31] ^= 1
+	err = msg.VerifyPreimage(paymentHash)
+	require.IsType(t, ErrPreimageMismatch{}, err)
+}
@@ -0,0 +1,238 @@
+package lnwire
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/lightningnetwork/lnd/tlv"
+)
+
+const (
+	// RetryDelayRecordType is the TLV type of the optional record within a
+	// Warning that carries the number of seconds the sender suggests the
+	// receiver wait before reconnecting. The type is odd so that peers
+	// that don't understand it can safely ignore it.
+	RetryDelayRecordType tlv.Type = 1
+)
+
+// WarningData is a set of bytes associated with a particular sent warning. It's
+// encoded and printed just like ErrorData.
+type WarningData ErrorData
+
+// Warning is used to express a recoverable condition to a peer. Unlike Error,
+// receiving a Warning doesn't require the channel to be failed or the
+// connection to be torn down.
+type Warning struct {
+	// ChanID references the active channel in which the warning occurred
+	// within. If the ChanID is all zeros, then this warning applies to
+	// the entire established connection.
+	ChanID ChannelID
+
+	// Data is the attached warning data that describes the exact
+	// condition which caused the warning message to be sent.
+	Data WarningData
+
+	// ExtraOpaqueData is the set of data that was appended to this
+	// message, which may carry optional TLV records such as the suggested
+	// retry delay. It's retained as is, so that we know exactly which
+	// records the sender included.
+	ExtraOpaqueData ExtraOpaqueData
+}
+
+// NewWarning creates a new Warning message.
+func NewWarning() *Warning {
+	return &Warning{}
+}
+
+// A compile time check to ensure Warning implements the lnwire.Message
+// interface.
+var _ Message = (*Warning)(nil)
+
+// A compile time check to ensure Warning implements the
+// lnwire.UnknownRecordsMessage interface.
+var _ UnknownRecordsMessage = (*Warning)(nil)
+
+// Warning returns the string representation of the Warning.
+func (c *Warning) Warning() string {
+	msg := "non-ascii data"
+	if isASCII(c.Data) {
+		msg = string(c.Data)
+	}
+
+	return fmt.Sprintf("chan_id=%v, warning=%v", c.ChanID, msg)
+}
+
+// SetRetryDelay sets the suggested delay the receiver should wait before
+// reconnecting, replacing any existing suggestion. The delay is rounded down
+// to whole seconds. All other records within the ExtraOpaqueData are
+// preserved.
+func (c *Warning) SetRetryDelay(delay time.Duration) error {
+	retryDelay := uint32(delay / time.Second)
+	return c.ExtraOpaqueData.replaceRecord(
+		tlv.MakePrimitiveRecord(RetryDelayRecordType, &retryDelay),
+	)
+}
+
+// SuggestedRetryDelay returns the delay the sender suggested we wait before
+// reconnecting, and whether a suggestion was present at all. An error is
+// returned if the ExtraOpaqueData isn't a valid TLV stream.
+func (c *Warning) SuggestedRetryDelay() (time.Duration, bool, error) {
+	var retryDelay uint32
+	parsedTypes, err := c.ExtraOpaqueData.ExtractRecords(
+		tlv.MakePrimitiveRecord(RetryDelayRecordType, &retryDelay),
+	)
+	if err != nil {
+		return 0, false, err
+	}
+
+	if _, ok := parsedTypes[RetryDelayRecordType]; !ok {
+		return 0, false, nil
+	}
+
+	return time.Duration(retryDelay) * time.Second, true, nil
+}
+
+// Decode deserializes a serialized Warning message stored in the passed
+// io.Reader observing the specified protocol version.
+//
+// This is part of the lnwire.Message interface.
+func (c *Warning) Decode(r io.Reader, pver uint32) error {
+	return c.DecodeWithMaxDataLen(r, pver, DefaultMaxErrorDataLen)
+}
+
+// DecodeWithMaxDataLen deserializes a serialized Warning message like Decode,
+// but fails with ErrErrorDataTooLarge if it carries more than maxDataLen bytes
+// of data.
+func (c *Warning) DecodeWithMaxDataLen(r io.Reader, pver uint32,
+	maxDataLen int) error {
+
+	if err := ReadElements(r, &c.ChanID); err != nil {
+		return err
+	}
+
+	data, err := readErrorData(r, maxDataLen)
+	if err != nil {
+		return err
+	}
+	c.Data = WarningData(data)
+
+	// The retry delay is only part of the message as of
+	// ProtocolVersionWarningRetry.
+	if pver < ProtocolVersionWarningRetry {
+		return nil
+	}
+
+	// The retry delay is carried within an optional TLV stream following
+	// the fixed fields, so we'll collect the remainder as is. A warning
+	// from a peer that doesn't know of it will simply have nothing left
+	// to read.
+	return c.ExtraOpaqueData.Decode(r)
+}
+
+// Encode serializes the target Warning into the passed io.Writer observing
+// the protocol version specified.
+//
+// This is part of the lnwire.Message interface.
+func (c *Warning) Encode(w io.Writer, pver uint32) error {
+	if err := WriteElements(w,
+		c.ChanID,
+		c.Data,
+	); err != nil {
+		return err
+	}
+
+	// The retry delay is only part of the message as of
+	// ProtocolVersionWarningRetry, so the TLV stream carrying it is
+	// omitted for earlier versions. A plain warning doesn't carry any extra
+	// data at all, keeping it byte-for-byte identical to one sent by a peer
+	// without support for the retry delay.
+	if pver < ProtocolVersionWarningRetry {
+		return nil
+	}
+
+	return c.ExtraOpaqueData.Encode(w)
+}
+
+// MsgType returns the integer uniquely identifying a Warning message on the
+// wire.
+//
+// This is part of the lnwire.Message interface.
+func (c *Warning) MsgType() MessageType {
+	return MsgWarning
+}
+
+// MaxPayloadLength returns the maximum allowed payload size for a Warning
+// complete message observing the specified protocol version.
+//
+// This is part of the lnwire.Message interface.
+func (c *Warning) MaxPayloadLength(uint32) uint32 {
+	return MaxMessagePayload
+}
+
+// ParsedOptionalRecords returns the sorted set of TLV types carried within the
+// ExtraOpaqueData of the message.
+//
+// This is part of the lnwire.OptionalRecordsMessage interface.
+func (c *Warning) ParsedOptionalRecords() []uint64 {
+	return c.ExtraOpaqueData.parsedOptionalRecords()
+}
+
+// UnknownRecords returns the TLV records carried within the ExtraOpaqueData of
+// the message other than the retry delay.
+//
+// This is part of the lnwire.UnknownRecordsMessage interface.
+func (c *Warning) UnknownRecords() []UnknownRecord {
+	return c.ExtraOpaqueData.unknownRecords(RetryDelayRecordType)
+}
+
+// ClassifyFailure maps an error encountered while handling messages from a
+// peer to the message that should be sent to it in response. Errors that are
+// recoverable result in a Warning, allowing the connection and channel to be
+// kept, in which case true is returned. All other errors result in an Error,
+// after which the channel identified by chanID, or all channels with the peer
+// if it's zero, are to be failed.
+//
+// The recoverable conditions are:
+//   - messages of unknown odd type, or not part of the protocol version in
+//     use.
+//   - malformed gossip, such as unsorted short channel IDs, unknown address
+//     types and invalid node aliases.
+//   - a cooperative close fee outside of the acceptable range.
+//   - a non-standard shutdown delivery address, or one paying to a segwit
+//     version not allowed without option_shutdown_anysegwit.
+func ClassifyFailure(chanID ChannelID, err error) (Message, bool) {
+	var (
+		unknownMsg      *UnknownMessage
+		unsupportedVer  ErrUnsupportedProtocolVersion
+		unsortedSIDs    ErrUnsortedSIDs
+		unknownAddrType *ErrUnknownAddrType
+		invalidAlias    *ErrInvalidNodeAlias
+		feeOutOfRange   ErrFeeOutOfRange
+	)
+
+	// As required by BOLT #1, a message of unknown even type must fail
+	// the connection, while one of unknown odd type may be ignored.
+	recoverable := (errors.As(err, &unknownMsg) &&
+		unknownMsg.messageType%2 == 1) ||
+		errors.As(err, &unsupportedVer) ||
+		errors.As(err, &unsortedSIDs) ||
+		errors.As(err, &unknownAddrType) ||
+		errors.As(err, &invalidAlias) ||
+		errors.As(err, &feeOutOfRange) ||
+		errors.Is(err, ErrNonStandardDeliveryAddress) ||
+		errors.Is(err, ErrAnySegwitNotNegotiated)
+
+	if recoverable {
+		return &Warning{
+			ChanID: chanID,
+			Data:   WarningData(err.Error()),
+		}, true
+	}
+
+	return &Error{
+		ChanID: chanID,
+		Data:   ErrorData(err.Error()),
+	}, false
+}
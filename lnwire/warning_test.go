@@ -0,0 +1,205 @@
+package lnwire
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/lightningnetwork/lnd/tlv"
+	"github.com/stretchr/testify/require"
+)
+
+// TestClassifyFailure asserts that recoverable errors are answered with a
+// Warning, while all other errors are answered with an Error.
+func TestClassifyFailure(t *testing.T) {
+	t.Parallel()
+
+	chanID := ChannelID{1}
+
+	testCases := []struct {
+		name        string
+		err         error
+		recoverable bool
+	}{
+		{
+			name:        "unknown odd message",
+			err:         &UnknownMessage{MessageType(65535)},
+			recoverable: true,
+		},
+		{
+			name:        "unknown even message",
+			err:         &UnknownMessage{MessageType(65534)},
+			recoverable: false,
+		},
+		{
+			name: "unsupported protocol version",
+			err: ErrUnsupportedProtocolVersion{
+				msgType: MsgWarning,
+			},
+			recoverable: true,
+		},
+		{
+			name: "unsorted short channel ids",
+			err: ErrUnsortedSIDs{
+				prevSID: NewShortChanIDFromInt(2),
+				curSID:  NewShortChanIDFromInt(1),
+			},
+			recoverable: true,
+		},
+		{
+			name: "fee out of range",
+			err: ErrFeeOutOfRange{
+				fee: 1000,
+				min: 100,
+				max: 500,
+			},
+			recoverable: true,
+		},
+		{
+			name:        "unknown address type",
+			err:         &ErrUnknownAddrType{addrType: 99},
+			recoverable: true,
+		},
+		{
+			name:        "invalid node alias",
+			err:         &ErrInvalidNodeAlias{},
+			recoverable: true,
+		},
+		{
+			name: "wrapped non-standard delivery address",
+			err: fmt.Errorf("unable to handle shutdown: %w",
+				ErrNonStandardDeliveryAddress),
+			recoverable: true,
+		},
+		{
+			name:        "any segwit delivery address",
+			err:         ErrAnySegwitNotNegotiated,
+			recoverable: true,
+		},
+		{
+			name:        "upfront shutdown mismatch",
+			err:         ErrUpfrontShutdownMismatch,
+			recoverable: false,
+		},
+		{
+			name:        "unclassified error",
+			err:         errors.New("invalid commitment signature"),
+			recoverable: false,
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			msg, recoverable := ClassifyFailure(chanID, testCase.err)
+			require.Equal(t, testCase.recoverable, recoverable)
+
+			if !recoverable {
+				errMsg, ok := msg.(*Error)
+				require.True(t, ok)
+				require.Equal(t, chanID, errMsg.ChanID)
+				require.Equal(
+					t, testCase.err.Error(),
+					string(errMsg.Data),
+				)
+				return
+			}
+
+			warning, ok := msg.(*Warning)
+			require.True(t, ok)
+			require.Equal(t, chanID, warning.ChanID)
+			require.Equal(
+				t, testCase.err.Error(), string(warning.Data),
+			)
+		})
+	}
+}
+
+// TestErrorDataLimit asserts that Errors and Warnings carrying more data than
+// allowed are rejected when decoded, both with the default and a custom limit.
+func TestErrorDataLimit(t *testing.T) {
+	t.Parallel()
+
+	testCases := []Message{
+		&Error{Data: make(ErrorData, DefaultMaxErrorDataLen)},
+		&Error{Data: make(ErrorData, DefaultMaxErrorDataLen+1)},
+		&Warning{Data: make(WarningData, DefaultMaxErrorDataLen)},
+		&Warning{Data: make(WarningData, DefaultMaxErrorDataLen+1)},
+	}
+	for i, msg := range testCases {
+		var b bytes.Buffer
+		_, err := WriteMessage(&b, msg, ProtocolVersionLatest)
+		require.NoError(t, err)
+
+		_, err = ReadMessage(&b, ProtocolVersionLatest)
+
+		// Only the messages carrying data at the limit are accepted.
+		if i%2 == 0 {
+			require.NoError(t, err)
+			continue
+		}
+
+		var limitErr ErrErrorDataTooLarge
+		require.True(t, errors.As(err, &limitErr))
+		require.Equal(t, DefaultMaxErrorDataLen+1, limitErr.DataLen())
+		require.Equal(t, DefaultMaxErrorDataLen, limitErr.Limit())
+	}
+
+	// A different limit can be enforced when decoding the messages
+	// directly.
+	var b bytes.Buffer
+	errMsg := &Error{Data: make(ErrorData, 100)}
+	require.NoError(t, errMsg.Encode(&b, ProtocolVersionLatest))
+	payload := b.Bytes()
+
+	err := (&Error{}).DecodeWithMaxDataLen(
+		bytes.NewReader(payload), ProtocolVersionLatest, 100,
+	)
+	require.NoError(t, err)
+	err = (&Error{}).DecodeWithMaxDataLen(
+		bytes.NewReader(payload), ProtocolVersionLatest, 99,
+	)
+	require.Equal(t, ErrErrorDataTooLarge{dataLen: 100, limit: 99}, err)
+
+	// As a warning shares the encoding of an error, the same payload
+	// decodes as one.
+	err = (&Warning{}).DecodeWithMaxDataLen(
+		bytes.NewReader(payload), ProtocolVersionLatest, 99,
+	)
+	require.Equal(t, ErrErrorDataTooLarge{dataLen: 100, limit: 99}, err)
+}
+
+// TestWarningRetryDelay asserts that a suggested retry delay survives a round
+// trip through the wire, and that setting it preserves any other records.
+func TestWarningRetryDelay(t *testing.T) {
+	t.Parallel()
+
+	warning := &Warning{Data: WarningData("try again later")}
+	_, ok, err := warning.SuggestedRetryDelay()
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	unknownValue := []byte{0x03}
+	err = warning.ExtraOpaqueData.PackRecords(
+		tlv.MakePrimitiveRecord(tlv.Type(3), &unknownValue),
+	)
+	require.NoError(t, err)
+	require.NoError(t, warning.SetRetryDelay(90*time.Second))
+
+	var b bytes.Buffer
+	_, err = WriteMessage(&b, warning, ProtocolVersionLatest)
+	require.NoError(t, err)
+	msg, err := ReadMessage(&b, ProtocolVersionLatest)
+	require.NoError(t, err)
+
+	decoded := msg.(*Warning)
+	delay, ok, err := decoded.SuggestedRetryDelay()
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, 90*time.Second, delay)
+	require.Equal(t, []UnknownRecord{
+		{Type: 3, Value: unknownValue},
+	}, decoded.UnknownRecords())
+}
@@ -14,21 +14,12 @@ import (
 func SignAnnouncement(signer lnwallet.MessageSigner, pubKey *btcec.PublicKey,
 	msg lnwire.Message) (input.Signature, error) {
 
-	var (
-		data []byte
-		err  error
-	)
-
-	switch m := msg.(type) {
-	case *lnwire.ChannelAnnouncement:
-		data, err = m.DataToSign()
-	case *lnwire.ChannelUpdate:
-		data, err = m.DataToSign()
-	case *lnwire.NodeAnnouncement:
-		data, err = m.DataToSign()
-	default:
-		return nil, fmt.Errorf("can't sign %T message", m)
+	signedMsg, ok := msg.(lnwire.SignedGossipMessage)
+	if !ok {
+		return nil, fmt.Errorf("can't sign %T message", msg)
 	}
+
+	data, err := signedMsg.DataToSign()
 	if err != nil {
 		return nil, fmt.Errorf("unable to get data to sign: %v", err)
 	}
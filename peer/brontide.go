@@ -951,10 +951,12 @@ func (p *Brontide) readNextMessage() (lnwire.Message, error) {
 		return nil, err
 	}
 
-	// Next, create a new io.Reader implementation from the raw message,
-	// and use this to decode the message directly from.
-	msgReader := bytes.NewReader(rawMsg)
-	nextMsg, err := lnwire.ReadMessage(msgReader, 0)
+	// Next, decode the message directly from the raw message. As it holds
+	// exactly the framed message, this also rejects it if the peer
+	// bloated it beyond what its type allows.
+	nextMsg, err := lnwire.ReadFramedMessage(
+		rawMsg, lnwire.ProtocolVersionLatest,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -1368,6 +1370,10 @@ out:
 				break out
 			}
 
+		case *lnwire.Warning:
+			peerLog.Warnf("Received warning from peer %v: %v",
+				p, msg.Warning())
+
 		case *lnwire.Error:
 			targetChan = msg.ChanID
 			isLinkUpdate = p.handleError(msg)
@@ -1584,6 +1590,9 @@ func messageSummary(msg lnwire.Message) string {
 		return fmt.Sprintf("chan_id=%v, id=%v, fail_code=%v",
 			msg.ChanID, msg.ID, msg.FailureCode)
 
+	case *lnwire.Warning:
+		return fmt.Sprintf("%v", msg.Warning())
+
 	case *lnwire.Error:
 		return fmt.Sprintf("%v", msg.Error())
 
@@ -1765,7 +1774,9 @@ func (p *Brontide) writeMessage(msg lnwire.Message) error {
 	err := p.cfg.WritePool.Submit(func(buf *bytes.Buffer) error {
 		// Using a buffer allocated by the write pool, encode the
 		// message directly into the buffer.
-		_, writeErr := lnwire.WriteMessage(buf, msg, 0)
+		_, writeErr := lnwire.WriteMessage(
+			buf, msg, lnwire.ProtocolVersionLatest,
+		)
 		if writeErr != nil {
 			return writeErr
 		}
@@ -2717,7 +2728,7 @@ func (p *Brontide) WipeChannel(chanPoint *wire.OutPoint) {
 func (p *Brontide) handleInitMsg(msg *lnwire.Init) error {
 	// First, merge any features from the legacy global features field into
 	// those presented in the local features fields.
-	err := msg.Features.Merge(msg.GlobalFeatures)
+	features, err := msg.MergedFeatures()
 	if err != nil {
 		return fmt.Errorf("unable to merge legacy global features: %v",
 			err)
@@ -2726,7 +2737,7 @@ func (p *Brontide) handleInitMsg(msg *lnwire.Init) error {
 	// Then, finalize the remote feature vector providing the flattened
 	// feature bit namespace.
 	p.remoteFeatures = lnwire.NewFeatureVector(
-		msg.Features, lnwire.Features,
+		features, lnwire.Features,
 	)
 
 	// Now that we have their features loaded, we'll ensure that they
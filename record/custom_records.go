@@ -1,6 +1,13 @@
 package record
 
-import "fmt"
+import (
+	"crypto/sha256"
+	"fmt"
+	"sort"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/lightningnetwork/lnd/tlv"
+)
 
 const (
 	// CustomTypeStart is the start of the custom tlv type range as defined
@@ -22,3 +29,35 @@ func (c CustomSet) Validate() error {
 
 	return nil
 }
+
+// Digest returns the sha256 hash of the custom records serialized as a TLV
+// stream, i.e. sorted by their type, with each type and length encoded as a
+// BigSize integer followed by the value. The digest therefore only depends on
+// the records themselves, allowing applications to use it as an integrity tag
+// for the data they carry across hops. An empty and a nil set share the same
+// digest.
+func (c CustomSet) Digest() chainhash.Hash {
+	keys := make([]uint64, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i] < keys[j]
+	})
+
+	// Writing to the hash never fails, so we can safely ignore the errors
+	// returned when encoding the records.
+	var buf [8]byte
+	h := sha256.New()
+	for _, key := range keys {
+		value := c[key]
+		_ = tlv.WriteVarInt(h, key, &buf)
+		_ = tlv.WriteVarInt(h, uint64(len(value)), &buf)
+		_, _ = h.Write(value)
+	}
+
+	var digest chainhash.Hash
+	copy(digest[:], h.Sum(nil))
+
+	return digest
+}
@@ -0,0 +1,65 @@
+package record_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"testing"
+
+	"github.com/lightningnetwork/lnd/record"
+	"github.com/lightningnetwork/lnd/tlv"
+)
+
+// TestCustomSetDigest asserts that the digest of a set of custom records is
+// the hash of their TLV stream encoding, and only depends on their contents.
+func TestCustomSetDigest(t *testing.T) {
+	t.Parallel()
+
+	set := record.CustomSet{
+		record.CustomTypeStart + 2: []byte{0x02},
+		record.CustomTypeStart:     []byte{0x00, 0x01},
+		1 << 40:                    nil,
+	}
+
+	records := tlv.MapToRecords(set)
+	tlv.SortRecords(records)
+	stream, err := tlv.NewStream(records...)
+	if err != nil {
+		t.Fatalf("unable to create stream: %v", err)
+	}
+	var b bytes.Buffer
+	if err := stream.Encode(&b); err != nil {
+		t.Fatalf("unable to encode stream: %v", err)
+	}
+
+	expected := sha256.Sum256(b.Bytes())
+	digest := set.Digest()
+	if !bytes.Equal(digest[:], expected[:]) {
+		t.Fatalf("expected digest %x, got %x", expected, digest)
+	}
+
+	// The digest must be stable across map iteration orders.
+	for i := 0; i < 10; i++ {
+		if set.Digest() != digest {
+			t.Fatalf("digest not deterministic")
+		}
+	}
+
+	// Changing any value, or moving it to another type, must change the
+	// digest.
+	set[record.CustomTypeStart+2] = []byte{0x03}
+	if set.Digest() == digest {
+		t.Fatalf("digest unchanged after modifying value")
+	}
+	set[record.CustomTypeStart+2] = []byte{0x02}
+
+	set[record.CustomTypeStart+4] = set[record.CustomTypeStart+2]
+	delete(set, record.CustomTypeStart+2)
+	if set.Digest() == digest {
+		t.Fatalf("digest unchanged after modifying type")
+	}
+
+	// A nil and an empty set share the same digest.
+	if record.CustomSet(nil).Digest() != (record.CustomSet{}).Digest() {
+		t.Fatalf("nil and empty set have different digests")
+	}
+}
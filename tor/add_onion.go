@@ -5,14 +5,52 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"strconv"
+	"strings"
 )
 
 var (
 	// ErrNoPrivateKey is an error returned by the OnionStore.PrivateKey
 	// method when a private key hasn't yet been stored.
 	ErrNoPrivateKey = errors.New("private key not found")
+
+	// ErrUnknownOnion is returned by GetOnionInfo when the onion service
+	// wasn't created through the controller.
+	ErrUnknownOnion = errors.New("onion service not created by controller")
+
+	// ErrCloseCircuitWithoutMaxStreams is returned by AddOnion when asked
+	// to close circuits exceeding the max number of streams without
+	// setting one.
+	ErrCloseCircuitWithoutMaxStreams = errors.New("max streams close " +
+		"circuit requires max streams to be set")
+
+	// ErrNonAnonymousModeDisabled is returned by AddOnion when asked to
+	// create a non-anonymous onion service while the Tor server isn't
+	// configured to allow them.
+	ErrNonAnonymousModeDisabled = errors.New("non-anonymous onion " +
+		"services require the Tor server to be configured with " +
+		"HiddenServiceSingleHopMode and HiddenServiceNonAnonymousMode")
 )
 
+// ErrSwappedOnionPorts is returned by GetOnionInfo when an onion service maps
+// its virtual port to a target port we aren't listening on, while we are
+// listening on the virtual port itself, which indicates that the two were
+// swapped when creating it.
+type ErrSwappedOnionPorts struct {
+	serviceID   string
+	virtualPort int
+	targetPort  int
+}
+
+// Error returns a human readable string describing the error.
+//
+// NOTE: implements the error interface.
+func (e ErrSwappedOnionPorts) Error() string {
+	return fmt.Sprintf("onion service %v maps virtual port %d to target "+
+		"port %d, which appear to be swapped", e.serviceID,
+		e.virtualPort, e.targetPort)
+}
+
 // OnionType denotes the type of the onion service.
 type OnionType int
 
@@ -78,6 +116,18 @@ func (f *OnionFile) DeletePrivateKey(_ OnionType) error {
 	return os.Remove(f.privateKeyPath)
 }
 
+// OnionPortMapping is a single port mapping of an onion service, as requested
+// from the Tor server when creating it.
+type OnionPortMapping struct {
+	// VirtualPort is the externally reachable port of the onion address.
+	VirtualPort int
+
+	// Target is where the Tor server forwards traffic arriving at the
+	// virtual port to. This is either a local port, or a host:port pair
+	// if a target IP address was configured.
+	Target string
+}
+
 // AddOnionConfig houses all of the required parameters in order to successfully
 // create a new onion service or restore an existing one.
 type AddOnionConfig struct {
@@ -101,12 +151,37 @@ type AddOnionConfig struct {
 	// NOTE: If not specified, then nothing will be stored, making onion
 	// services unrecoverable after shutdown.
 	Store OnionStore
+
+	// MaxStreams is the maximum number of streams that may be open at once
+	// on a single rendezvous circuit to the onion service. Once reached,
+	// additional streams are rejected.
+	//
+	// NOTE: If zero, the number of streams is unlimited.
+	MaxStreams uint16
+
+	// MaxStreamsCloseCircuit closes the rendezvous circuit instead of only
+	// rejecting the stream once MaxStreams is exceeded. It requires
+	// MaxStreams to be set.
+	MaxStreamsCloseCircuit bool
+
+	// NonAnonymous creates a single-hop onion service, which connects
+	// directly to the introduction points and rendezvous points instead
+	// of through a circuit. This reduces latency, at the cost of the
+	// location of the service no longer being hidden. It requires the Tor
+	// server to be configured with HiddenServiceSingleHopMode and
+	// HiddenServiceNonAnonymousMode, which is checked before the onion
+	// service is created.
+	NonAnonymous bool
 }
 
 // AddOnion creates an onion service and returns its onion address. Once
 // created, the new onion service will remain active until the connection
 // between the controller and the Tor server is closed.
 func (c *Controller) AddOnion(cfg AddOnionConfig) (*OnionAddr, error) {
+	if cfg.MaxStreamsCloseCircuit && cfg.MaxStreams == 0 {
+		return nil, ErrCloseCircuitWithoutMaxStreams
+	}
+
 	// Before sending the request to create an onion service to the Tor
 	// server, we'll make sure that it supports V3 onion services if that
 	// was the type requested.
@@ -116,6 +191,18 @@ func (c *Controller) AddOnion(cfg AddOnionConfig) (*OnionAddr, error) {
 		}
 	}
 
+	// A non-anonymous onion service can only be created if the Tor server
+	// allows it, so we'll check that up front to provide a clear error.
+	if cfg.NonAnonymous {
+		enabled, err := c.NonAnonymousMode()
+		if err != nil {
+			return nil, err
+		}
+		if !enabled {
+			return nil, ErrNonAnonymousModeDisabled
+		}
+	}
+
 	// We'll start off by checking if the store contains an existing private
 	// key. If it does not, then we should request the server to create a
 	// new onion service and return its private key. Otherwise, we'll
@@ -146,31 +233,54 @@ func (c *Controller) AddOnion(cfg AddOnionConfig) (*OnionAddr, error) {
 	// Now, we'll create a mapping from the virtual port to each target
 	// port. If no target ports were specified, we'll use the virtual port
 	// to provide a one-to-one mapping.
-	var portParam string
+	targetPorts := cfg.TargetPorts
+	if len(targetPorts) == 0 {
+		targetPorts = []int{cfg.VirtualPort}
+	}
 
-	// Helper function which appends the correct Port param depending on
-	// whether the user chose to use a custom target IP address or not.
-	pushPortParam := func(targetPort int) {
-		if c.targetIPAddress == "" {
-			portParam += fmt.Sprintf("Port=%d,%d ", cfg.VirtualPort,
+	// Each target includes the custom target IP address if the user chose
+	// to use one.
+	var (
+		portMappings []OnionPortMapping
+		portParam    string
+	)
+	for _, targetPort := range targetPorts {
+		target := strconv.Itoa(targetPort)
+		if c.targetIPAddress != "" {
+			target = fmt.Sprintf("%s:%d", c.targetIPAddress,
 				targetPort)
-		} else {
-			portParam += fmt.Sprintf("Port=%d,%s:%d ", cfg.VirtualPort,
-				c.targetIPAddress, targetPort)
 		}
+
+		portMappings = append(portMappings, OnionPortMapping{
+			VirtualPort: cfg.VirtualPort,
+			Target:      target,
+		})
+		portParam += fmt.Sprintf("Port=%d,%s ", cfg.VirtualPort, target)
 	}
 
-	if len(cfg.TargetPorts) == 0 {
-		pushPortParam(cfg.VirtualPort)
-	} else {
-		for _, targetPort := range cfg.TargetPorts {
-			pushPortParam(targetPort)
-		}
+	// Set any requested flags, and limit the number of streams to the
+	// onion service if requested, both of which the Tor server expects
+	// before the port mappings.
+	var flags []string
+	if cfg.NonAnonymous {
+		flags = append(flags, "NonAnonymous")
+	}
+	if cfg.MaxStreamsCloseCircuit {
+		flags = append(flags, "MaxStreamsCloseCircuit")
+	}
+
+	var optionsParam string
+	if len(flags) > 0 {
+		optionsParam += "Flags=" + strings.Join(flags, ",") + " "
+	}
+	if cfg.MaxStreams != 0 {
+		optionsParam += fmt.Sprintf("MaxStreams=%d ", cfg.MaxStreams)
 	}
 
 	// Send the command to create the onion service to the Tor server and
 	// await its response.
-	cmd := fmt.Sprintf("ADD_ONION %s %s", keyParam, portParam)
+	cmd := fmt.Sprintf("ADD_ONION %s %s%s", keyParam, optionsParam,
+		portParam)
 	_, reply, err := c.sendCommand(cmd)
 	if err != nil {
 		return nil, err
@@ -207,6 +317,15 @@ func (c *Controller) AddOnion(cfg AddOnionConfig) (*OnionAddr, error) {
 		}
 	}
 
+	// We'll keep track of the port mappings we requested, so that they
+	// can be inspected through GetOnionInfo later on.
+	c.onionsMtx.Lock()
+	if c.onions == nil {
+		c.onions = make(map[string][]OnionPortMapping)
+	}
+	c.onions[serviceID] = portMappings
+	c.onionsMtx.Unlock()
+
 	// Finally, we'll return the onion address composed of the service ID,
 	// along with the onion suffix, and the port this onion service can be
 	// reached at externally.
@@ -215,3 +334,134 @@ func (c *Controller) AddOnion(cfg AddOnionConfig) (*OnionAddr, error) {
 		Port:         cfg.VirtualPort,
 	}, nil
 }
+
+// GetOnionInfo returns the port mappings that were requested when creating the
+// onion service with the given service ID, with or without the onion suffix.
+// Before returning them, the Tor server is queried to ensure the onion
+// service is still active, and it's forgotten about if it isn't.
+// ErrUnknownOnion is returned if the onion service wasn't created through the
+// controller.
+//
+// If the local ports traffic is expected to arrive at are given, each mapping
+// is checked to target one of them. A mapping whose virtual port is one of
+// them instead results in ErrSwappedOnionPorts, as otherwise the onion service
+// would silently be unreachable.
+func (c *Controller) GetOnionInfo(serviceID string,
+	listenPorts ...int) ([]OnionPortMapping, error) {
+
+	serviceID = strings.TrimSuffix(serviceID, OnionSuffix)
+
+	c.onionsMtx.Lock()
+	portMappings, ok := c.onions[serviceID]
+	c.onionsMtx.Unlock()
+	if !ok {
+		return nil, ErrUnknownOnion
+	}
+
+	// The Tor server only tells us which onion services created through
+	// this connection are active, not their port mappings, so that's what
+	// we'll cross-check.
+	//
+	//	C: GETINFO onions/current
+	//	S: 250-onions/current=testonion1234567
+	//	S: 250 OK
+	_, reply, err := c.sendCommand("GETINFO onions/current")
+	if err != nil {
+		return nil, err
+	}
+
+	active := false
+	for _, field := range strings.Fields(reply) {
+		field = strings.TrimPrefix(field, "onions/current=")
+		if field == serviceID {
+			active = true
+			break
+		}
+	}
+	if !active {
+		c.forgetOnion(serviceID)
+
+		return nil, fmt.Errorf("onion service %v not active according "+
+			"to Tor server", serviceID)
+	}
+
+	if len(listenPorts) > 0 {
+		err := checkOnionPorts(serviceID, portMappings, listenPorts)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	mappings := make([]OnionPortMapping, len(portMappings))
+	copy(mappings, portMappings)
+
+	return mappings, nil
+}
+
+// DelOnion tells the Tor server to remove the onion service with the given
+// service ID, with or without the onion suffix, and forgets about its port
+// mappings.
+func (c *Controller) DelOnion(serviceID string) error {
+	serviceID = strings.TrimSuffix(serviceID, OnionSuffix)
+
+	//	C: DEL_ONION testonion1234567
+	//	S: 250 OK
+	_, _, err := c.sendCommand("DEL_ONION " + serviceID)
+	if err != nil {
+		return err
+	}
+
+	c.forgetOnion(serviceID)
+
+	return nil
+}
+
+// forgetOnion removes the port mappings tracked for the onion service with the
+// given service ID.
+func (c *Controller) forgetOnion(serviceID string) {
+	c.onionsMtx.Lock()
+	delete(c.onions, serviceID)
+	c.onionsMtx.Unlock()
+}
+
+// checkOnionPorts ensures that each of the port mappings of an onion service
+// targets one of the given local ports.
+func checkOnionPorts(serviceID string, portMappings []OnionPortMapping,
+	listenPorts []int) error {
+
+	listening := make(map[int]struct{}, len(listenPorts))
+	for _, port := range listenPorts {
+		listening[port] = struct{}{}
+	}
+
+	for _, mapping := range portMappings {
+		// The target is either a port, or a host:port pair if a target
+		// IP address was configured.
+		target := mapping.Target
+		if i := strings.LastIndex(target, ":"); i >= 0 {
+			target = target[i+1:]
+		}
+		targetPort, err := strconv.Atoi(target)
+		if err != nil {
+			return fmt.Errorf("invalid target %q of onion service "+
+				"%v", mapping.Target, serviceID)
+		}
+
+		if _, ok := listening[targetPort]; ok {
+			continue
+		}
+
+		if _, ok := listening[mapping.VirtualPort]; ok {
+			return ErrSwappedOnionPorts{
+				serviceID:   serviceID,
+				virtualPort: mapping.VirtualPort,
+				targetPort:  targetPort,
+			}
+		}
+
+		return fmt.Errorf("onion service %v targets port %d, which "+
+			"isn't listened on", serviceID, targetPort)
+	}
+
+	return nil
+}
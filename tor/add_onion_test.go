@@ -5,6 +5,8 @@ import (
 	"io/ioutil"
 	"path/filepath"
 	"testing"
+
+	"github.com/stretchr/testify/require"
 )
 
 // TestOnionFile tests that the OnionFile implementation of the OnionStore
@@ -49,3 +51,200 @@ func TestOnionFile(t *testing.T) {
 		t.Fatal("found deleted private key")
 	}
 }
+
+// TestGetOnionInfo asserts that the port mappings requested when creating an
+// onion service can be retrieved, as long as the Tor server reports it as
+// active.
+func TestGetOnionInfo(t *testing.T) {
+	t.Parallel()
+
+	c, proxy := newTestController(t)
+	defer proxy.close()
+	c.targetIPAddress = "10.0.0.1"
+
+	// Onion services we haven't created shouldn't be known.
+	_, err := c.GetOnionInfo("testonion1234567")
+	require.Equal(t, ErrUnknownOnion, err)
+
+	go func() {
+		proxy.expect(
+			t, "ADD_ONION NEW:RSA1024 Port=9735,10.0.0.1:9736 "+
+				"Port=9735,10.0.0.1:9737 ",
+			"250-ServiceID=testonion1234567", "250 OK",
+		)
+		proxy.expect(
+			t, "GETINFO onions/current",
+			"250-onions/current=testonion1234567", "250 OK",
+		)
+		proxy.expect(
+			t, "GETINFO onions/current", "250-onions/current=",
+			"250 OK",
+		)
+	}()
+
+	addr, err := c.AddOnion(AddOnionConfig{
+		Type:        V2,
+		VirtualPort: 9735,
+		TargetPorts: []int{9736, 9737},
+	})
+	require.NoError(t, err)
+
+	expected := []OnionPortMapping{
+		{VirtualPort: 9735, Target: "10.0.0.1:9736"},
+		{VirtualPort: 9735, Target: "10.0.0.1:9737"},
+	}
+	mappings, err := c.GetOnionInfo(addr.OnionService)
+	require.NoError(t, err)
+	require.Equal(t, expected, mappings)
+
+	// Once the Tor server no longer reports the onion service as active,
+	// an error should be returned, and the onion service forgotten.
+	_, err = c.GetOnionInfo(addr.OnionService)
+	require.Error(t, err)
+	require.NotEqual(t, ErrUnknownOnion, err)
+
+	_, err = c.GetOnionInfo(addr.OnionService)
+	require.Equal(t, ErrUnknownOnion, err)
+}
+
+// TestGetOnionInfoSwappedPorts asserts that port mappings not targeting any of
+// the ports we listen on are detected, including swapped ones.
+func TestGetOnionInfoSwappedPorts(t *testing.T) {
+	t.Parallel()
+
+	c, proxy := newTestController(t)
+	defer proxy.close()
+
+	active := []string{"250-onions/current=testonion1234567", "250 OK"}
+	go func() {
+		proxy.expect(
+			t, "ADD_ONION NEW:RSA1024 Port=9735,80 ",
+			"250-ServiceID=testonion1234567", "250 OK",
+		)
+		proxy.expect(t, "GETINFO onions/current", active...)
+		proxy.expect(t, "GETINFO onions/current", active...)
+		proxy.expect(t, "GETINFO onions/current", active...)
+		proxy.expect(t, "DEL_ONION testonion1234567", "250 OK")
+	}()
+
+	// The virtual and target port were swapped, as we listen on 9735.
+	addr, err := c.AddOnion(AddOnionConfig{
+		Type:        V2,
+		VirtualPort: 9735,
+		TargetPorts: []int{80},
+	})
+	require.NoError(t, err)
+
+	_, err = c.GetOnionInfo(addr.OnionService, 9735)
+	require.Equal(t, ErrSwappedOnionPorts{
+		serviceID:   "testonion1234567",
+		virtualPort: 9735,
+		targetPort:  80,
+	}, err)
+
+	// Neither port being listened on is an error as well.
+	_, err = c.GetOnionInfo(addr.OnionService, 9736)
+	require.Error(t, err)
+	_, swapped := err.(ErrSwappedOnionPorts)
+	require.False(t, swapped)
+
+	// The mapping is fine if we do listen on the target port.
+	_, err = c.GetOnionInfo(addr.OnionService, 80)
+	require.NoError(t, err)
+
+	// Once the onion service is deleted, it's forgotten about.
+	require.NoError(t, c.DelOnion(addr.OnionService))
+	_, err = c.GetOnionInfo(addr.OnionService)
+	require.Equal(t, ErrUnknownOnion, err)
+}
+
+// TestAddOnionMaxStreams asserts that the stream limits of an onion service
+// are passed on to the Tor server ahead of the port mappings.
+func TestAddOnionMaxStreams(t *testing.T) {
+	t.Parallel()
+
+	c, proxy := newTestController(t)
+	defer proxy.close()
+
+	// Closing circuits is meaningless without a limit to exceed.
+	_, err := c.AddOnion(AddOnionConfig{
+		Type:                   V2,
+		VirtualPort:            9735,
+		MaxStreamsCloseCircuit: true,
+	})
+	require.Equal(t, ErrCloseCircuitWithoutMaxStreams, err)
+
+	go func() {
+		proxy.expect(
+			t, "ADD_ONION NEW:RSA1024 MaxStreams=10 Port=9735,9735 ",
+			"250-ServiceID=testonion1234567", "250 OK",
+		)
+		proxy.expect(
+			t, "ADD_ONION NEW:RSA1024 Flags=MaxStreamsCloseCircuit "+
+				"MaxStreams=20 Port=9735,9735 ",
+			"250-ServiceID=testonion7654321", "250 OK",
+		)
+	}()
+
+	_, err = c.AddOnion(AddOnionConfig{
+		Type:        V2,
+		VirtualPort: 9735,
+		MaxStreams:  10,
+	})
+	require.NoError(t, err)
+
+	_, err = c.AddOnion(AddOnionConfig{
+		Type:                   V2,
+		VirtualPort:            9735,
+		MaxStreams:             20,
+		MaxStreamsCloseCircuit: true,
+	})
+	require.NoError(t, err)
+}
+
+// TestAddOnionNonAnonymous asserts that a non-anonymous onion service is only
+// requested once the Tor server is known to allow it.
+func TestAddOnionNonAnonymous(t *testing.T) {
+	t.Parallel()
+
+	c, proxy := newTestController(t)
+	defer proxy.close()
+
+	const getConf = "GETCONF HiddenServiceSingleHopMode " +
+		"HiddenServiceNonAnonymousMode"
+
+	go func() {
+		proxy.expect(t, getConf,
+			"250-HiddenServiceSingleHopMode=1",
+			"250 HiddenServiceNonAnonymousMode=1",
+		)
+		proxy.expect(
+			t, "ADD_ONION NEW:RSA1024 Flags=NonAnonymous,"+
+				"MaxStreamsCloseCircuit MaxStreams=10 "+
+				"Port=9735,9735 ",
+			"250-ServiceID=testonion1234567", "250 OK",
+		)
+		proxy.expect(t, getConf,
+			"250-HiddenServiceSingleHopMode=1",
+			"250 HiddenServiceNonAnonymousMode",
+		)
+	}()
+
+	_, err := c.AddOnion(AddOnionConfig{
+		Type:                   V2,
+		VirtualPort:            9735,
+		MaxStreams:             10,
+		MaxStreamsCloseCircuit: true,
+		NonAnonymous:           true,
+	})
+	require.NoError(t, err)
+
+	// Without the Tor server acknowledging the lack of anonymity, the
+	// onion service shouldn't be requested.
+	_, err = c.AddOnion(AddOnionConfig{
+		Type:         V2,
+		VirtualPort:  9735,
+		NonAnonymous: true,
+	})
+	require.Equal(t, ErrNonAnonymousModeDisabled, err)
+}
@@ -0,0 +1,244 @@
+package tor
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// confCircuitBuildTimeout is the configuration option holding how long
+	// the Tor server waits for a circuit to be built before giving up.
+	confCircuitBuildTimeout = "CircuitBuildTimeout"
+
+	// confLearnCircuitBuildTimeout is the configuration option determining
+	// whether the Tor server adapts the circuit build timeout to the
+	// observed network conditions.
+	confLearnCircuitBuildTimeout = "LearnCircuitBuildTimeout"
+
+	// confConnectionPadding is the configuration option determining
+	// whether padding is sent over connections to relays.
+	confConnectionPadding = "ConnectionPadding"
+
+	// confReducedConnectionPadding is the configuration option determining
+	// whether less padding is sent, and connections are closed sooner, to
+	// save bandwidth.
+	confReducedConnectionPadding = "ReducedConnectionPadding"
+
+	// confHiddenServiceSingleHopMode is the configuration option
+	// determining whether the Tor server builds single-hop circuits for
+	// its onion services.
+	confHiddenServiceSingleHopMode = "HiddenServiceSingleHopMode"
+
+	// confHiddenServiceNonAnonymousMode is the configuration option that
+	// must be set along with confHiddenServiceSingleHopMode to acknowledge
+	// that onion services won't be anonymous.
+	confHiddenServiceNonAnonymousMode = "HiddenServiceNonAnonymousMode"
+)
+
+// ConnectionPaddingMode is the connection padding setting of the Tor server.
+type ConnectionPaddingMode uint8
+
+const (
+	// ConnectionPaddingAuto indicates that the Tor server only pads
+	// connections if both ends support it, which is its default.
+	ConnectionPaddingAuto ConnectionPaddingMode = iota
+
+	// ConnectionPaddingEnabled indicates that the Tor server always pads
+	// connections.
+	ConnectionPaddingEnabled
+
+	// ConnectionPaddingDisabled indicates that the Tor server never pads
+	// connections.
+	ConnectionPaddingDisabled
+)
+
+// String returns a human readable string describing the padding mode.
+func (m ConnectionPaddingMode) String() string {
+	switch m {
+	case ConnectionPaddingAuto:
+		return "auto"
+	case ConnectionPaddingEnabled:
+		return "enabled"
+	case ConnectionPaddingDisabled:
+		return "disabled"
+	default:
+		return fmt.Sprintf("unknown(%d)", uint8(m))
+	}
+}
+
+// CircuitSettings houses the settings of the Tor server that affect how long
+// circuits take to be built and how much padding is sent over connections,
+// trading off privacy against performance.
+type CircuitSettings struct {
+	// CircuitBuildTimeout is how long the Tor server waits for a circuit
+	// to be built before giving up.
+	CircuitBuildTimeout time.Duration
+
+	// LearnCircuitBuildTimeout is true if the Tor server adapts the
+	// circuit build timeout to the observed network conditions, in which
+	// case CircuitBuildTimeout is only used as the initial value.
+	LearnCircuitBuildTimeout bool
+
+	// ConnectionPadding is the padding mode of connections to relays.
+	ConnectionPadding ConnectionPaddingMode
+
+	// ReducedConnectionPadding is true if the Tor server sends less
+	// padding, and closes connections sooner, to save bandwidth.
+	ReducedConnectionPadding bool
+}
+
+// GetConf sends a "GETCONF" command to the Tor server for the given
+// configuration options and returns their values, keyed by the name of each
+// option as reported by the server. Options that are set to their default
+// value without the server reporting it are mapped to an empty string, and
+// options with multiple values are reported with the last one.
+func (c *Controller) GetConf(keys ...string) (map[string]string, error) {
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no configuration options requested")
+	}
+
+	// The Tor server replies with a line per option, each holding either
+	// its value or only its name if it's unset.
+	//
+	//	C: GETCONF CircuitBuildTimeout ConnectionPadding
+	//	S: 250-CircuitBuildTimeout=60
+	//	S: 250 ConnectionPadding
+	cmd := "GETCONF " + strings.Join(keys, " ")
+	_, reply, err := c.sendCommand(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	conf := make(map[string]string, len(keys))
+	for _, line := range strings.Split(reply, "\n") {
+		keyValue := strings.SplitN(line, "=", 2)
+		if keyValue[0] == "" {
+			continue
+		}
+
+		var value string
+		if len(keyValue) == 2 {
+			value = strings.Trim(keyValue[1], "\"")
+		}
+		conf[keyValue[0]] = value
+	}
+
+	return conf, nil
+}
+
+// CircuitSettings queries the Tor server for its circuit build timeout and
+// connection padding settings. Settings that the server reports as unset are
+// returned with the default values of the Tor server.
+func (c *Controller) CircuitSettings() (*CircuitSettings, error) {
+	conf, err := c.GetConf(
+		confCircuitBuildTimeout, confLearnCircuitBuildTimeout,
+		confConnectionPadding, confReducedConnectionPadding,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// We'll start out with the defaults of the Tor server, which are used
+	// for any option it doesn't report a value for.
+	settings := &CircuitSettings{
+		CircuitBuildTimeout:      60 * time.Second,
+		LearnCircuitBuildTimeout: true,
+		ConnectionPadding:        ConnectionPaddingAuto,
+	}
+
+	if value := conf[confCircuitBuildTimeout]; value != "" {
+		// Intervals are reported as a number of seconds, although
+		// we'll also accept an explicit unit.
+		secs, err := strconv.ParseUint(
+			strings.TrimSuffix(value, " seconds"), 10, 32,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %v value %q: %v",
+				confCircuitBuildTimeout, value, err)
+		}
+		settings.CircuitBuildTimeout = time.Duration(secs) * time.Second
+	}
+
+	if value := conf[confLearnCircuitBuildTimeout]; value != "" {
+		learn, err := parseConfBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %v value: %v",
+				confLearnCircuitBuildTimeout, err)
+		}
+		settings.LearnCircuitBuildTimeout = learn
+	}
+
+	switch value := conf[confConnectionPadding]; value {
+	case "", "auto":
+		settings.ConnectionPadding = ConnectionPaddingAuto
+	case "1":
+		settings.ConnectionPadding = ConnectionPaddingEnabled
+	case "0":
+		settings.ConnectionPadding = ConnectionPaddingDisabled
+	default:
+		return nil, fmt.Errorf("invalid %v value %q",
+			confConnectionPadding, value)
+	}
+
+	if value := conf[confReducedConnectionPadding]; value != "" {
+		reduced, err := parseConfBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %v value: %v",
+				confReducedConnectionPadding, err)
+		}
+		settings.ReducedConnectionPadding = reduced
+	}
+
+	return settings, nil
+}
+
+// NonAnonymousMode queries the Tor server for whether it's configured to allow
+// non-anonymous, single-hop onion services, which requires both
+// HiddenServiceSingleHopMode and HiddenServiceNonAnonymousMode to be set.
+func (c *Controller) NonAnonymousMode() (bool, error) {
+	conf, err := c.GetConf(
+		confHiddenServiceSingleHopMode,
+		confHiddenServiceNonAnonymousMode,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	// Both options are disabled by default, so any option the server
+	// doesn't report a value for is disabled.
+	for _, key := range []string{
+		confHiddenServiceSingleHopMode,
+		confHiddenServiceNonAnonymousMode,
+	} {
+		value := conf[key]
+		if value == "" {
+			return false, nil
+		}
+
+		enabled, err := parseConfBool(value)
+		if err != nil {
+			return false, fmt.Errorf("invalid %v value: %v", key,
+				err)
+		}
+		if !enabled {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// parseConfBool parses a boolean configuration value of the Tor server, which
+// is reported as either 0 or 1.
+func parseConfBool(value string) (bool, error) {
+	switch value {
+	case "0":
+		return false, nil
+	case "1":
+		return true, nil
+	default:
+		return false, fmt.Errorf("expected 0 or 1, got %q", value)
+	}
+}
@@ -0,0 +1,70 @@
+package tor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestCircuitSettings ensures that the circuit settings of the Tor server are
+// parsed from its GETCONF replies, falling back to the defaults for unset
+// options and rejecting malformed values.
+func TestCircuitSettings(t *testing.T) {
+	t.Parallel()
+
+	c, proxy := newTestController(t)
+	defer proxy.close()
+
+	const cmd = "GETCONF CircuitBuildTimeout LearnCircuitBuildTimeout " +
+		"ConnectionPadding ReducedConnectionPadding"
+
+	go func() {
+		proxy.expect(t, cmd,
+			"250-CircuitBuildTimeout=30",
+			"250-LearnCircuitBuildTimeout=0",
+			"250-ConnectionPadding=1",
+			"250 ReducedConnectionPadding=1",
+		)
+		proxy.expect(t, cmd,
+			"250-CircuitBuildTimeout",
+			"250-LearnCircuitBuildTimeout",
+			"250-ConnectionPadding",
+			"250 ReducedConnectionPadding",
+		)
+		proxy.expect(t, cmd,
+			"250-CircuitBuildTimeout=60",
+			"250-LearnCircuitBuildTimeout=1",
+			"250-ConnectionPadding=sometimes",
+			"250 ReducedConnectionPadding=0",
+		)
+		proxy.expect(t, cmd, "552 Unrecognized configuration key")
+	}()
+
+	settings, err := c.CircuitSettings()
+	require.NoError(t, err)
+	require.Equal(t, &CircuitSettings{
+		CircuitBuildTimeout:      30 * time.Second,
+		LearnCircuitBuildTimeout: false,
+		ConnectionPadding:        ConnectionPaddingEnabled,
+		ReducedConnectionPadding: true,
+	}, settings)
+
+	// Options reported without a value are set to their defaults.
+	settings, err = c.CircuitSettings()
+	require.NoError(t, err)
+	require.Equal(t, &CircuitSettings{
+		CircuitBuildTimeout:      60 * time.Second,
+		LearnCircuitBuildTimeout: true,
+		ConnectionPadding:        ConnectionPaddingAuto,
+		ReducedConnectionPadding: false,
+	}, settings)
+
+	// An unknown padding mode can't be represented.
+	_, err = c.CircuitSettings()
+	require.Error(t, err)
+
+	// An error reply from the server should be surfaced to the caller.
+	_, err = c.CircuitSettings()
+	require.Error(t, err)
+}
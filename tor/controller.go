@@ -9,10 +9,13 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/textproto"
 	"strconv"
 	"strings"
+	"sync"
 	"sync/atomic"
+	"syscall"
 )
 
 const (
@@ -36,18 +39,41 @@ const (
 	// services through Tor's control port.
 	MinTorVersion = "0.3.3.6"
 
-	// authSafeCookie is the name of the SAFECOOKIE authentication method.
-	authSafeCookie = "SAFECOOKIE"
+	// AuthSafeCookie is the name of the SAFECOOKIE authentication method.
+	AuthSafeCookie = "SAFECOOKIE"
 
-	// authHashedPassword is the name of the HASHEDPASSWORD authentication
+	// AuthHashedPassword is the name of the HASHEDPASSWORD authentication
 	// method.
-	authHashedPassword = "HASHEDPASSWORD"
+	AuthHashedPassword = "HASHEDPASSWORD"
 
-	// authNull is the name of the NULL authentication method.
-	authNull = "NULL"
+	// AuthNull is the name of the NULL authentication method.
+	AuthNull = "NULL"
+
+	// SignalNewNym is the signal that instructs the Tor server to switch
+	// to clean circuits, so that new application requests don't share
+	// any circuits with old ones.
+	SignalNewNym = "NEWNYM"
+
+	// SignalReload is the signal that instructs the Tor server to reload
+	// its configuration.
+	SignalReload = "RELOAD"
 )
 
 var (
+	// ErrTorNotRunning is returned by Start when the connection to the
+	// control port was refused, which usually means the Tor server isn't
+	// running or isn't listening on the configured control port.
+	ErrTorNotRunning = errors.New("tor server not running")
+
+	// ErrTorAddrUnreachable is returned by Start when the control address
+	// couldn't be reached at all, e.g. because it doesn't resolve or no
+	// route to it exists.
+	ErrTorAddrUnreachable = errors.New("tor control address unreachable")
+
+	// ErrControllerQuiesced is returned when attempting to send a command
+	// through a controller that has been quiesced.
+	ErrControllerQuiesced = errors.New("tor controller is quiesced")
+
 	// serverKey is the key used when computing the HMAC-SHA256 of a message
 	// from the server.
 	serverKey = []byte("Tor safe cookie authentication " +
@@ -57,6 +83,13 @@ var (
 	// message from the controller.
 	controllerKey = []byte("Tor safe cookie authentication " +
 		"controller-to-server hash")
+
+	// supportedSignals is the set of signals that can be sent to the Tor
+	// server through the Signal method.
+	supportedSignals = map[string]struct{}{
+		SignalNewNym: {},
+		SignalReload: {},
+	}
 )
 
 // Controller is an implementation of the Tor Control protocol. This is used in
@@ -71,6 +104,14 @@ var (
 //   * place under sub-package?
 //   * support async replies from the server
 type Controller struct {
+	// AuthPreference, if non-empty, is the ordered list of authentication
+	// methods the controller may use, e.g. to force SAFECOOKIE even when a
+	// password is configured, or to forbid NULL authentication. The first
+	// method in the list supported by the Tor server is used, and if none
+	// are, authentication fails rather than falling back to any other
+	// method. If empty, the controller picks the method itself.
+	AuthPreference []string
+
 	// started is used atomically in order to prevent multiple calls to
 	// Start.
 	started int32
@@ -79,6 +120,10 @@ type Controller struct {
 	// Stop.
 	stopped int32
 
+	// quiesced is used atomically to signal that the controller was
+	// quiesced, after which no further commands are sent.
+	quiesced int32
+
 	// conn is the underlying connection between the controller and the
 	// Tor server. It provides read and write methods to simplify the
 	// text-based messages within the connection.
@@ -93,6 +138,11 @@ type Controller struct {
 	// HASHEDPASSWORD authentication method with this value.
 	password string
 
+	// cookie, if non-nil, is the authentication cookie provided by the
+	// caller, to be used instead of reading it from the file reported by
+	// the Tor server when authenticating through the SAFECOOKIE method.
+	cookie []byte
+
 	// version is the current version of the Tor server.
 	version string
 
@@ -100,6 +150,13 @@ type Controller struct {
 	// to connect to the LND node.  This is required when the Tor server
 	// runs on another host, otherwise the service will not be reachable.
 	targetIPAddress string
+
+	// onions maps the service ID of each onion service created through
+	// the controller to the port mappings requested for it.
+	onions map[string][]OnionPortMapping
+
+	// onionsMtx guards onions.
+	onionsMtx sync.Mutex
 }
 
 // NewController returns a new Tor controller that will be able to interact with
@@ -111,9 +168,24 @@ func NewController(controlAddr string, targetIPAddress string,
 		controlAddr:     controlAddr,
 		targetIPAddress: targetIPAddress,
 		password:        password,
+		onions:          make(map[string][]OnionPortMapping),
 	}
 }
 
+// NewControllerWithCookie returns a new Tor controller that authenticates with
+// the Tor server through the SAFECOOKIE method using the given cookie, rather
+// than reading it from the cookie file reported by the server. This supports
+// deployments where the cookie is provided out-of-band, and the cookie file
+// isn't accessible from lnd's filesystem.
+func NewControllerWithCookie(controlAddr string, targetIPAddress string,
+	cookie []byte) *Controller {
+
+	c := NewController(controlAddr, targetIPAddress, "")
+	c.cookie = append([]byte{}, cookie...)
+
+	return c
+}
+
 // Start establishes and authenticates the connection between the controller and
 // a Tor server. Once done, the controller will be able to send commands and
 // expect responses.
@@ -124,7 +196,7 @@ func (c *Controller) Start() error {
 
 	conn, err := textproto.Dial("tcp", c.controlAddr)
 	if err != nil {
-		return fmt.Errorf("unable to connect to Tor server: %v", err)
+		return classifyDialErr(err)
 	}
 
 	c.conn = conn
@@ -132,6 +204,26 @@ func (c *Controller) Start() error {
 	return c.authenticate()
 }
 
+// classifyDialErr wraps an error returned when dialing the Tor control port
+// with ErrTorNotRunning or ErrTorAddrUnreachable when the cause is known, so
+// that callers can give an actionable error message through errors.Is.
+func classifyDialErr(err error) error {
+	var dnsErr *net.DNSError
+	switch {
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return fmt.Errorf("%w: %v", ErrTorNotRunning, err)
+
+	case errors.Is(err, syscall.EHOSTUNREACH),
+		errors.Is(err, syscall.ENETUNREACH),
+		errors.As(err, &dnsErr):
+
+		return fmt.Errorf("%w: %v", ErrTorAddrUnreachable, err)
+
+	default:
+		return fmt.Errorf("unable to connect to Tor server: %v", err)
+	}
+}
+
 // Stop closes the connection between the controller and the Tor server.
 func (c *Controller) Stop() error {
 	if !atomic.CompareAndSwapInt32(&c.stopped, 0, 1) {
@@ -144,13 +236,15 @@ func (c *Controller) Stop() error {
 // sendCommand sends a command to the Tor server and returns its response, as a
 // single space-delimited string, and code.
 func (c *Controller) sendCommand(command string) (int, string, error) {
+	if atomic.LoadInt32(&c.quiesced) == 1 {
+		return 0, "", ErrControllerQuiesced
+	}
+
 	if err := c.conn.Writer.PrintfLine(command); err != nil {
 		return 0, "", err
 	}
 
-	// We'll use ReadResponse as it has built-in support for multi-line
-	// text protocol responses.
-	code, reply, err := c.conn.Reader.ReadResponse(success)
+	code, reply, err := readResponse(&c.conn.Reader, success)
 	if err != nil {
 		return code, reply, err
 	}
@@ -158,6 +252,102 @@ func (c *Controller) sendCommand(command string) (int, string, error) {
 	return code, reply, nil
 }
 
+// responseBufPool is a pool of buffers used to accumulate multi-line replies
+// from the Tor server, so that reading a reply doesn't allocate a new buffer
+// each time.
+var responseBufPool = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
+// readResponse reads a, possibly multi-line, reply from the Tor server. The
+// parsed code, message and error are identical to those of the ReadResponse
+// method of textproto.Reader, but the lines of the message are accumulated
+// within a pooled buffer rather than concatenated one by one, which is
+// quadratic in the number of lines of the reply.
+func readResponse(r *textproto.Reader, expectCode int) (int, string, error) {
+	line, err := r.ReadLine()
+	if err != nil {
+		return 0, "", err
+	}
+
+	code, continued, message, err := parseCodeLine(line, expectCode)
+	if !continued {
+		return code, message, err
+	}
+
+	buf := responseBufPool.Get().(*bytes.Buffer)
+	defer func() {
+		buf.Reset()
+		responseBufPool.Put(buf)
+	}()
+
+	buf.WriteString(message)
+	for continued {
+		line, readErr := r.ReadLine()
+		if readErr != nil {
+			return 0, "", readErr
+		}
+
+		// Lines that can't be parsed, or that carry a different code,
+		// are included verbatim within the message.
+		code2, cont, moreMessage, parseErr := parseCodeLine(line, 0)
+		buf.WriteByte('\n')
+		if parseErr != nil || code2 != code {
+			buf.WriteString(strings.TrimRight(line, "\r\n"))
+			continued = true
+			continue
+		}
+
+		buf.WriteString(moreMessage)
+		continued = cont
+	}
+
+	message = buf.String()
+
+	// Replace the error of the first line with one carrying the full
+	// message.
+	if err != nil && message != "" {
+		err = &textproto.Error{Code: code, Msg: message}
+	}
+
+	return code, message, err
+}
+
+// parseCodeLine parses a single line of a reply from the Tor server into its
+// code, whether the reply continues on the next line, and its message. If
+// expectCode is non-zero, an error is returned along with the parsed values if
+// the code doesn't match it, following the rules of textproto.
+func parseCodeLine(line string, expectCode int) (int, bool, string, error) {
+	if len(line) < 4 || line[3] != ' ' && line[3] != '-' {
+		return 0, false, "", textproto.ProtocolError(
+			"short response: " + line,
+		)
+	}
+
+	continued := line[3] == '-'
+	code, err := strconv.Atoi(line[0:3])
+	if err != nil || code < 100 {
+		return code, continued, "", textproto.ProtocolError(
+			"invalid response code: " + line,
+		)
+	}
+
+	message := line[4:]
+	if 1 <= expectCode && expectCode < 10 && code/100 != expectCode ||
+		10 <= expectCode && expectCode < 100 && code/10 != expectCode ||
+		100 <= expectCode && expectCode < 1000 && code != expectCode {
+
+		return code, continued, message, &textproto.Error{
+			Code: code,
+			Msg:  message,
+		}
+	}
+
+	return code, continued, message, nil
+}
+
 // parseTorReply parses the reply from the Tor server after receiving a command
 // from a controller. This will parse the relevant reply parameters into a map
 // of keys and values.
@@ -200,24 +390,40 @@ func (c *Controller) authenticate() error {
 	// used later on.
 	c.version = protocolInfo.version()
 
+	// If the operator pinned the authentication methods to use, we'll
+	// only consider those.
+	if len(c.AuthPreference) > 0 {
+		return c.authenticateWithPreference(protocolInfo)
+	}
+
 	switch {
 	// If a password was provided, then we should attempt to use the
 	// HASHEDPASSWORD authentication method.
 	case c.password != "":
-		if !protocolInfo.supportsAuthMethod(authHashedPassword) {
+		if !protocolInfo.supportsAuthMethod(AuthHashedPassword) {
 			return fmt.Errorf("%v authentication method not "+
-				"supported", authHashedPassword)
+				"supported", AuthHashedPassword)
 		}
 
 		return c.authenticateViaHashedPassword()
 
+	// If a cookie was provided, then we must use the SAFECOOKIE
+	// authentication method, as that's what it was provided for.
+	case c.cookie != nil:
+		if !protocolInfo.supportsAuthMethod(AuthSafeCookie) {
+			return fmt.Errorf("%v authentication method not "+
+				"supported", AuthSafeCookie)
+		}
+
+		return c.authenticateViaSafeCookie(protocolInfo)
+
 	// Otherwise, attempt to authentication via the SAFECOOKIE method as it
 	// provides the most security.
-	case protocolInfo.supportsAuthMethod(authSafeCookie):
+	case protocolInfo.supportsAuthMethod(AuthSafeCookie):
 		return c.authenticateViaSafeCookie(protocolInfo)
 
 	// Fallback to the NULL method if any others aren't supported.
-	case protocolInfo.supportsAuthMethod(authNull):
+	case protocolInfo.supportsAuthMethod(AuthNull):
 		return c.authenticateViaNull()
 
 	// No supported authentication methods, fail.
@@ -227,6 +433,39 @@ func (c *Controller) authenticate() error {
 	}
 }
 
+// authenticateWithPreference authenticates the controller with the Tor server
+// using the first method within AuthPreference that the server supports.
+func (c *Controller) authenticateWithPreference(info protocolInfo) error {
+	for _, method := range c.AuthPreference {
+		if !info.supportsAuthMethod(method) {
+			continue
+		}
+
+		switch method {
+		case AuthHashedPassword:
+			if c.password == "" {
+				return fmt.Errorf("%v authentication method "+
+					"requires a password", AuthHashedPassword)
+			}
+
+			return c.authenticateViaHashedPassword()
+
+		case AuthSafeCookie:
+			return c.authenticateViaSafeCookie(info)
+
+		case AuthNull:
+			return c.authenticateViaNull()
+
+		default:
+			return fmt.Errorf("unknown authentication method %v",
+				method)
+		}
+	}
+
+	return fmt.Errorf("none of the preferred authentication methods %v "+
+		"are supported by the Tor server", c.AuthPreference)
+}
+
 // authenticateViaNull authenticates the controller with the Tor server using
 // the NULL authentication method.
 func (c *Controller) authenticateViaNull() error {
@@ -339,9 +578,21 @@ func (c *Controller) authenticateViaSafeCookie(info protocolInfo) error {
 	return nil
 }
 
-// getAuthCookie retrieves the authentication cookie in bytes from the Tor
-// server. Cookie authentication must be enabled for this to work.
+// getAuthCookie retrieves the authentication cookie in bytes, either as
+// provided to the controller or from the Tor server. Cookie authentication
+// must be enabled for this to work.
 func (c *Controller) getAuthCookie(info protocolInfo) ([]byte, error) {
+	// If the cookie was provided to us, there's no need to read it from
+	// the cookie file.
+	if c.cookie != nil {
+		if len(c.cookie) != cookieLen {
+			return nil, errors.New("invalid authentication cookie " +
+				"length")
+		}
+
+		return c.cookie, nil
+	}
+
 	// Retrieve the cookie file path from the PROTOCOLINFO reply.
 	cookieFilePath, ok := info["COOKIEFILE"]
 	if !ok {
@@ -438,3 +689,51 @@ func (c *Controller) protocolInfo() (protocolInfo, error) {
 
 	return protocolInfo(parseTorReply(reply)), nil
 }
+
+// Signal sends the given signal to the Tor server through the SIGNAL command.
+// Only the signals found within supportedSignals are allowed, e.g. NEWNYM to
+// rotate our Tor identity or RELOAD to have the server reload its
+// configuration.
+func (c *Controller) Signal(signal string) error {
+	if _, ok := supportedSignals[signal]; !ok {
+		return fmt.Errorf("unsupported signal: %v", signal)
+	}
+
+	// If successful, the Tor server will reply with a single "250 OK"
+	// line, which sendCommand already checks for us.
+	cmd := fmt.Sprintf("SIGNAL %s", signal)
+	_, _, err := c.sendCommand(cmd)
+	return err
+}
+
+// Quiesce minimizes what the control connection can be used for once our onion
+// services have been set up, reducing the blast radius should the connection
+// later be compromised. It issues a single SETEVENTS command without any
+// events, which unsubscribes the connection from all asynchronous events, and
+// then refuses to send any further commands, returning ErrControllerQuiesced
+// instead. The connection itself is kept open, as closing it would remove any
+// onion services that weren't created detached.
+//
+// DROPGUARDS and DROPTIMEOUTS are deliberately not issued: they reset state of
+// the Tor server that isn't tied to the control connection, and dropping
+// guards in particular weakens the anonymity of the server.
+//
+// Quiesce is a no-op if no onion services were created through the
+// controller, or if it was already quiesced.
+func (c *Controller) Quiesce() error {
+	c.onionsMtx.Lock()
+	numOnions := len(c.onions)
+	c.onionsMtx.Unlock()
+
+	if numOnions == 0 || atomic.LoadInt32(&c.quiesced) == 1 {
+		return nil
+	}
+
+	if _, _, err := c.sendCommand("SETEVENTS"); err != nil {
+		return err
+	}
+
+	atomic.StoreInt32(&c.quiesced, 1)
+
+	return nil
+}
@@ -1,6 +1,22 @@
 package tor
 
-import "testing"
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
 
 // TestParseTorVersion is a series of tests for different version strings that
 // check the correctness of determining whether they support creating v3 onion
@@ -74,3 +90,442 @@ func TestParseTorVersion(t *testing.T) {
 		}
 	}
 }
+
+// testProxy emulates a Tor server on the other end of an in-memory connection
+// to a Controller, allowing tests to inspect the commands sent by the
+// controller and reply to them.
+type testProxy struct {
+	serverConn *textproto.Conn
+	clientConn net.Conn
+}
+
+// newTestController creates a Controller backed by an in-memory connection
+// along with the proxy used to act as the Tor server.
+func newTestController(t *testing.T) (*Controller, *testProxy) {
+	clientConn, serverConn := net.Pipe()
+
+	c := &Controller{
+		conn: textproto.NewConn(clientConn),
+	}
+	proxy := &testProxy{
+		serverConn: textproto.NewConn(serverConn),
+		clientConn: clientConn,
+	}
+
+	return c, proxy
+}
+
+// expect reads the next command sent by the controller, asserts it matches
+// the expected one and then writes back the given reply lines.
+func (p *testProxy) expect(t *testing.T, cmd string, reply ...string) {
+	line, err := p.serverConn.ReadLine()
+	if err != nil {
+		t.Errorf("unable to read command: %v", err)
+		return
+	}
+	if line != cmd {
+		t.Errorf("expected command %q, got %q", cmd, line)
+	}
+
+	for _, r := range reply {
+		if err := p.serverConn.PrintfLine(r); err != nil {
+			t.Errorf("unable to write reply: %v", err)
+			return
+		}
+	}
+}
+
+// close tears down both ends of the in-memory connection.
+func (p *testProxy) close() {
+	p.serverConn.Close()
+	p.clientConn.Close()
+}
+
+// TestSignal ensures that only supported signals are sent to the Tor server
+// and that its reply is properly checked.
+func TestSignal(t *testing.T) {
+	t.Parallel()
+
+	c, proxy := newTestController(t)
+	defer proxy.close()
+
+	// An unknown signal shouldn't make it to the Tor server at all.
+	require.Error(t, c.Signal("SHUTDOWN"))
+
+	go func() {
+		proxy.expect(t, "SIGNAL NEWNYM", "250 OK")
+		proxy.expect(t, "SIGNAL RELOAD", "552 Unrecognized signal")
+	}()
+
+	// A supported signal should be sent as is, and succeed once the
+	// server acknowledges it.
+	require.NoError(t, c.Signal(SignalNewNym))
+
+	// An error reply from the server should be surfaced to the caller.
+	require.Error(t, c.Signal(SignalReload))
+}
+
+// TestStartDialErrors ensures that failures to dial the Tor control port are
+// classified so that callers can tell why the connection failed.
+func TestStartDialErrors(t *testing.T) {
+	t.Parallel()
+
+	// Grab a local port that nothing is listening on, so that dialing it
+	// is refused.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := l.Addr().String()
+	require.NoError(t, l.Close())
+
+	c := NewController(addr, "", "")
+	err = c.Start()
+	require.True(t, errors.Is(err, ErrTorNotRunning), err)
+
+	// Unreachable hosts and networks, as well as addresses that fail to
+	// resolve, should be reported as unreachable.
+	unreachableErrs := []error{
+		&net.OpError{
+			Op:  "dial",
+			Err: os.NewSyscallError("connect", syscall.EHOSTUNREACH),
+		},
+		&net.OpError{
+			Op:  "dial",
+			Err: os.NewSyscallError("connect", syscall.ENETUNREACH),
+		},
+		&net.OpError{
+			Op:  "dial",
+			Err: &net.DNSError{Err: "no such host", Name: "tor"},
+		},
+	}
+	for _, dialErr := range unreachableErrs {
+		err := classifyDialErr(dialErr)
+		require.True(t, errors.Is(err, ErrTorAddrUnreachable), err)
+	}
+
+	// Any other error should be neither.
+	err = classifyDialErr(&net.OpError{
+		Op:  "dial",
+		Err: os.NewSyscallError("connect", syscall.EACCES),
+	})
+	require.False(t, errors.Is(err, ErrTorNotRunning))
+	require.False(t, errors.Is(err, ErrTorAddrUnreachable))
+}
+
+// TestAuthenticateWithProvidedCookie ensures that a controller provided with a
+// cookie authenticates through the SAFECOOKIE method using it, without
+// reading the cookie file reported by the Tor server.
+func TestAuthenticateWithProvidedCookie(t *testing.T) {
+	t.Parallel()
+
+	cookie := bytes.Repeat([]byte{0x01}, cookieLen)
+	serverNonce := bytes.Repeat([]byte{0x02}, nonceLen)
+
+	c, proxy := newTestController(t)
+	defer proxy.close()
+	c.cookie = cookie
+
+	protocolInfoReply := []string{
+		"250-PROTOCOLINFO 1",
+		"250-AUTH METHODS=COOKIE,SAFECOOKIE " +
+			"COOKIEFILE=\"/nonexistent/control_auth_cookie\"",
+		"250-VERSION Tor=\"0.4.5.6\"",
+		"250 OK",
+	}
+
+	go func() {
+		proxy.expect(t, "PROTOCOLINFO 1", protocolInfoReply...)
+
+		// We'll act as the Tor server, computing our hash with the
+		// client nonce we receive.
+		line, err := proxy.serverConn.ReadLine()
+		if err != nil {
+			t.Errorf("unable to read command: %v", err)
+			return
+		}
+		challengePrefix := "AUTHCHALLENGE SAFECOOKIE "
+		if !strings.HasPrefix(line, challengePrefix) {
+			t.Errorf("expected auth challenge, got %q", line)
+			return
+		}
+		clientNonce, err := hex.DecodeString(
+			strings.TrimPrefix(line, challengePrefix),
+		)
+		if err != nil {
+			t.Errorf("unable to decode client nonce: %v", err)
+			return
+		}
+
+		hmacMessage := bytes.Join(
+			[][]byte{cookie, clientNonce, serverNonce}, []byte{},
+		)
+		serverHash := computeHMAC256(serverKey, hmacMessage)
+		err = proxy.serverConn.PrintfLine(
+			"250 AUTHCHALLENGE SERVERHASH=%x SERVERNONCE=%x",
+			serverHash, serverNonce,
+		)
+		if err != nil {
+			t.Errorf("unable to write reply: %v", err)
+			return
+		}
+
+		clientHash := computeHMAC256(controllerKey, hmacMessage)
+		proxy.expect(
+			t, fmt.Sprintf("AUTHENTICATE %x", clientHash), "250 OK",
+		)
+
+		// A cookie of invalid length should be rejected before
+		// starting the authentication routine.
+		proxy.expect(t, "PROTOCOLINFO 1", protocolInfoReply...)
+	}()
+
+	require.NoError(t, c.authenticate())
+
+	c.cookie = cookie[1:]
+	require.Error(t, c.authenticate())
+}
+
+// TestAuthenticateWithPreference ensures that the controller only uses the
+// authentication methods it was configured to prefer, in order, without
+// falling back to any others.
+func TestAuthenticateWithPreference(t *testing.T) {
+	t.Parallel()
+
+	protocolInfoReply := func(methods string) []string {
+		return []string{
+			"250-PROTOCOLINFO 1",
+			"250-AUTH METHODS=" + methods,
+			"250-VERSION Tor=\"0.4.5.6\"",
+			"250 OK",
+		}
+	}
+
+	c, proxy := newTestController(t)
+	defer proxy.close()
+	c.password = "hunter2"
+
+	go func() {
+		// NULL should be used even though a password is set, as it's
+		// the first preferred method the server supports.
+		proxy.expect(
+			t, "PROTOCOLINFO 1",
+			protocolInfoReply("SAFECOOKIE,NULL")...,
+		)
+		proxy.expect(t, "AUTHENTICATE", "250 OK")
+
+		// The password should be used once it's the first supported
+		// preferred method.
+		proxy.expect(
+			t, "PROTOCOLINFO 1",
+			protocolInfoReply("HASHEDPASSWORD,NULL")...,
+		)
+		proxy.expect(t, "AUTHENTICATE \"hunter2\"", "250 OK")
+
+		// If none of the preferred methods are supported, we
+		// shouldn't fall back to any other method.
+		proxy.expect(
+			t, "PROTOCOLINFO 1", protocolInfoReply("NULL")...,
+		)
+	}()
+
+	c.AuthPreference = []string{AuthNull, AuthHashedPassword}
+	require.NoError(t, c.authenticate())
+
+	c.AuthPreference = []string{AuthSafeCookie, AuthHashedPassword}
+	require.NoError(t, c.authenticate())
+
+	c.AuthPreference = []string{AuthSafeCookie}
+	require.Error(t, c.authenticate())
+}
+
+// newTextprotoReader returns a textproto.Reader reading the given raw reply.
+func newTextprotoReader(reply string) *textproto.Reader {
+	return textproto.NewReader(bufio.NewReader(strings.NewReader(reply)))
+}
+
+// largeReply returns a synthetic multi-line reply with the given number of
+// onion services, as would be returned for GETINFO onions/current.
+func largeReply(numLines int) string {
+	var b strings.Builder
+	b.WriteString("250-onions/current=\r\n")
+	for i := 0; i < numLines; i++ {
+		fmt.Fprintf(&b, "250-%056d\r\n", i)
+	}
+	b.WriteString("250 OK\r\n")
+
+	return b.String()
+}
+
+// TestReadResponse asserts that readResponse parses replies identically to
+// the ReadResponse method of textproto.Reader.
+func TestReadResponse(t *testing.T) {
+	t.Parallel()
+
+	replies := []string{
+		"250 OK\r\n",
+		"250-version=0.4.5.7\r\n250 OK\r\n",
+		"515 Authentication failed\r\n",
+		"552-Unrecognized key\r\n552 Unrecognized\r\n",
+		"250-first\r\nnot a code line\r\n251-other\r\n250 OK\r\n",
+		"25\r\n",
+		"abc OK\r\n",
+		"250-truncated\r\n",
+		largeReply(100),
+	}
+
+	for _, reply := range replies {
+		expCode, expMsg, expErr := newTextprotoReader(reply).
+			ReadResponse(success)
+		code, msg, err := readResponse(
+			newTextprotoReader(reply), success,
+		)
+
+		require.Equal(t, expCode, code, reply)
+		require.Equal(t, expMsg, msg, reply)
+
+		// The quoting of the offending line within protocol errors
+		// differs between Go versions, so only their type is checked.
+		if _, ok := expErr.(textproto.ProtocolError); ok {
+			require.IsType(t, expErr, err, reply)
+			continue
+		}
+		require.Equal(t, expErr, err, reply)
+	}
+}
+
+// BenchmarkReadResponse measures reading a large multi-line reply with
+// readResponse.
+func BenchmarkReadResponse(b *testing.B) {
+	reply := largeReply(5000)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_, _, err := readResponse(newTextprotoReader(reply), success)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkTextprotoReadResponse measures reading a large multi-line reply
+// with the ReadResponse method of textproto.Reader, for comparison.
+func BenchmarkTextprotoReadResponse(b *testing.B) {
+	reply := largeReply(5000)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		r := newTextprotoReader(reply)
+		if _, _, err := r.ReadResponse(success); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// TestQuiesce ensures that quiescing the controller only unsubscribes from
+// events once onion services were set up, and that no further commands are
+// sent afterwards.
+func TestQuiesce(t *testing.T) {
+	t.Parallel()
+
+	c, proxy := newTestController(t)
+	defer proxy.close()
+
+	go func() {
+		proxy.expect(t, "SIGNAL NEWNYM", "250 OK")
+		proxy.expect(t, "SETEVENTS", "250 OK")
+	}()
+
+	// Without any onion services, quiescing shouldn't send anything, so
+	// the next command the server sees is the signal.
+	require.NoError(t, c.Quiesce())
+	require.NoError(t, c.Signal(SignalNewNym))
+
+	c.onions = map[string][]OnionPortMapping{
+		"testonion1234567": nil,
+	}
+	require.NoError(t, c.Quiesce())
+
+	// Once quiesced, commands should be refused without reaching the
+	// server, and quiescing again should be a no-op.
+	require.Equal(t, ErrControllerQuiesced, c.Signal(SignalNewNym))
+	require.NoError(t, c.Quiesce())
+}
+
+// fuzzSeedReplies returns raw replies from the Tor server covering the forms
+// the controller parses, to be used as the seed corpus of the fuzz harnesses
+// within fuzz/tor.
+func fuzzSeedReplies() [][]byte {
+	replies := []string{
+		// A single line reply.
+		"250 OK\r\n",
+
+		// A PROTOCOLINFO reply.
+		"250-PROTOCOLINFO 1\r\n" +
+			"250-AUTH METHODS=COOKIE,SAFECOOKIE,HASHEDPASSWORD " +
+			"COOKIEFILE=\"/home/user/.tor/control_auth_cookie\"\r\n" +
+			"250-VERSION Tor=\"0.4.5.7\"\r\n" +
+			"250 OK\r\n",
+
+		// An AUTHCHALLENGE reply.
+		"250 AUTHCHALLENGE SERVERHASH=" + strings.Repeat("ab", 32) +
+			" SERVERNONCE=" + strings.Repeat("cd", 32) + "\r\n",
+
+		// A multi-line reply to GETINFO onions/current.
+		largeReply(3),
+
+		// A data reply, whose value spans multiple lines terminated by
+		// a single period.
+		"250+onions/current=\r\ntestonion1234567\r\n" +
+			"testonion7654321\r\n.\r\n250 OK\r\n",
+
+		// Malformed quoting and escaping.
+		"250-VERSION Tor=\"0.4.5.7\r\n250 OK\r\n",
+		"250 COOKIEFILE=\"C:\\\\tor\\\"cookie\" A==B =\r\n",
+
+		// Error replies.
+		"515 Authentication failed: Wrong length on authentication " +
+			"cookie.\r\n",
+		"552-Unrecognized key\r\n552 Unrecognized\r\n",
+	}
+
+	seeds := make([][]byte, 0, len(replies))
+	for _, reply := range replies {
+		seeds = append(seeds, []byte(reply))
+	}
+
+	return seeds
+}
+
+// TestParseSeedReplies ensures that the seed replies, along with every
+// truncation of them, can be read and parsed without panicking. Setting the
+// TOR_FUZZ_CORPUS_DIR environment variable also exports the seeds into the
+// given directory, e.g. fuzz/tor/parse_tor_reply/corpus, for use by go-fuzz.
+func TestParseSeedReplies(t *testing.T) {
+	t.Parallel()
+
+	seeds := fuzzSeedReplies()
+	for _, seed := range seeds {
+		for i := 0; i <= len(seed); i++ {
+			truncated := seed[:i]
+
+			_ = parseTorReply(string(truncated))
+
+			_, reply, _ := readResponse(
+				newTextprotoReader(string(truncated)), success,
+			)
+			info := protocolInfo(parseTorReply(reply))
+			_ = info.version()
+			_ = info.supportsAuthMethod(AuthSafeCookie)
+		}
+	}
+
+	corpusDir := os.Getenv("TOR_FUZZ_CORPUS_DIR")
+	if corpusDir == "" {
+		return
+	}
+
+	require.NoError(t, os.MkdirAll(corpusDir, 0700))
+	for i, seed := range seeds {
+		path := filepath.Join(corpusDir, fmt.Sprintf("seed-%d", i))
+		require.NoError(t, ioutil.WriteFile(path, seed, 0600))
+	}
+}
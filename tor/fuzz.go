@@ -0,0 +1,34 @@
+// +build gofuzz
+
+package tor
+
+import (
+	"net/textproto"
+)
+
+// ParseTorReply exposes parseTorReply to the fuzz harnesses within fuzz/tor.
+func ParseTorReply(reply string) map[string]string {
+	return parseTorReply(reply)
+}
+
+// ReadResponse exposes readResponse to the fuzz harnesses within fuzz/tor,
+// expecting a successful reply.
+func ReadResponse(r *textproto.Reader) (int, string, error) {
+	return readResponse(r, success)
+}
+
+// ProtocolInfo parses the given reply as one to a PROTOCOLINFO command and
+// returns the Tor version and whether each authentication method is
+// supported, exercising the same accessors used while authenticating.
+func ProtocolInfo(reply string) (string, map[string]bool) {
+	info := protocolInfo(parseTorReply(reply))
+
+	methods := make(map[string]bool)
+	for _, method := range []string{
+		AuthSafeCookie, AuthHashedPassword, AuthNull,
+	} {
+		methods[method] = info.supportsAuthMethod(method)
+	}
+
+	return info.version(), methods
+}
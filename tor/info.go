@@ -0,0 +1,199 @@
+package tor
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+const (
+	// infoVersion is the GETINFO key holding the version of the Tor
+	// server.
+	infoVersion = "version"
+
+	// infoSOCKSListeners is the GETINFO key holding the addresses the Tor
+	// server accepts SOCKS connections on.
+	infoSOCKSListeners = "net/listeners/socks"
+
+	// infoDNSListeners is the GETINFO key holding the addresses the Tor
+	// server accepts DNS requests on.
+	infoDNSListeners = "net/listeners/dns"
+
+	// infoBootstrapPhase is the GETINFO key holding the progress of the
+	// Tor server in connecting to the Tor network.
+	infoBootstrapPhase = "status/bootstrap-phase"
+)
+
+// BootstrapInfo houses the information about the Tor server needed to route
+// connections through it.
+type BootstrapInfo struct {
+	// Version is the version of the Tor server.
+	Version string
+
+	// SOCKSPort is the port the Tor server accepts SOCKS connections on.
+	SOCKSPort int
+
+	// DNSPort is the port the Tor server accepts DNS requests on, or zero
+	// if it doesn't.
+	DNSPort int
+
+	// BootstrapProgress is the percentage of the Tor server's progress in
+	// connecting to the Tor network. It's 100 once the Tor server is
+	// ready to build circuits.
+	BootstrapProgress int
+
+	// BootstrapSummary is a human readable description of the current
+	// bootstrap phase.
+	BootstrapSummary string
+}
+
+// GetInfo sends a "GETINFO" command to the Tor server for the given keys and
+// returns their values, keyed by the name of each key as reported by the
+// server. Quoted values are returned without their quotes.
+func (c *Controller) GetInfo(keys ...string) (map[string]string, error) {
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no information requested")
+	}
+
+	// The Tor server replies with a line per key, followed by a final OK.
+	//
+	//	C: GETINFO version net/listeners/socks
+	//	S: 250-version=0.4.5.7
+	//	S: 250-net/listeners/socks="127.0.0.1:9050"
+	//	S: 250 OK
+	cmd := "GETINFO " + strings.Join(keys, " ")
+	_, reply, err := c.sendCommand(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	info := make(map[string]string, len(keys))
+	for _, line := range strings.Split(reply, "\n") {
+		keyValue := strings.SplitN(line, "=", 2)
+		if len(keyValue) != 2 {
+			continue
+		}
+
+		info[keyValue[0]] = strings.Trim(keyValue[1], "\"")
+	}
+
+	return info, nil
+}
+
+// BootstrapInfo queries the Tor server for its version, the ports it accepts
+// SOCKS connections and DNS requests on, and its progress in connecting to the
+// Tor network, all within a single GETINFO command. An error is returned if
+// the Tor server doesn't report its version or bootstrap phase, or doesn't
+// accept SOCKS connections on a TCP port.
+func (c *Controller) BootstrapInfo() (*BootstrapInfo, error) {
+	info, err := c.GetInfo(
+		infoVersion, infoSOCKSListeners, infoDNSListeners,
+		infoBootstrapPhase,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	bootstrapInfo := &BootstrapInfo{
+		Version: info[infoVersion],
+	}
+	if bootstrapInfo.Version == "" {
+		return nil, fmt.Errorf("tor server didn't report its version")
+	}
+
+	bootstrapInfo.SOCKSPort, err = listenerPort(info[infoSOCKSListeners])
+	if err != nil {
+		return nil, fmt.Errorf("invalid socks listener: %v", err)
+	}
+	if bootstrapInfo.SOCKSPort == 0 {
+		return nil, fmt.Errorf("tor server doesn't accept socks " +
+			"connections on a tcp port")
+	}
+
+	// The Tor server doesn't need to accept DNS requests, in which case
+	// the port remains zero.
+	bootstrapInfo.DNSPort, err = listenerPort(info[infoDNSListeners])
+	if err != nil {
+		return nil, fmt.Errorf("invalid dns listener: %v", err)
+	}
+
+	phase := info[infoBootstrapPhase]
+	if phase == "" {
+		return nil, fmt.Errorf("tor server didn't report its " +
+			"bootstrap phase")
+	}
+	bootstrapInfo.BootstrapProgress, bootstrapInfo.BootstrapSummary, err =
+		parseBootstrapPhase(phase)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bootstrap phase %q: %v", phase,
+			err)
+	}
+
+	return bootstrapInfo, nil
+}
+
+// listenerPort returns the port of the first TCP listener within the given
+// space-delimited list of quoted listener addresses, or zero if there isn't
+// any.
+func listenerPort(listeners string) (int, error) {
+	for _, listener := range strings.Fields(listeners) {
+		listener = strings.Trim(listener, "\"")
+
+		// Listeners on unix sockets don't have a port.
+		if strings.HasPrefix(listener, "unix:") {
+			continue
+		}
+
+		_, portStr, err := net.SplitHostPort(listener)
+		if err != nil {
+			return 0, err
+		}
+
+		port, err := strconv.ParseUint(portStr, 10, 16)
+		if err != nil {
+			return 0, err
+		}
+
+		return int(port), nil
+	}
+
+	return 0, nil
+}
+
+// parseBootstrapPhase parses the progress and summary of a bootstrap phase
+// status event, which is of the form:
+//
+//	NOTICE BOOTSTRAP PROGRESS=100 TAG=done SUMMARY="Done"
+func parseBootstrapPhase(phase string) (int, string, error) {
+	const progressKey, summaryKey = "PROGRESS=", "SUMMARY=\""
+
+	progress := -1
+	for _, field := range strings.Fields(phase) {
+		if !strings.HasPrefix(field, progressKey) {
+			continue
+		}
+
+		p, err := strconv.ParseUint(
+			strings.TrimPrefix(field, progressKey), 10, 8,
+		)
+		if err != nil || p > 100 {
+			return 0, "", fmt.Errorf("invalid progress %q", field)
+		}
+		progress = int(p)
+	}
+	if progress < 0 {
+		return 0, "", fmt.Errorf("missing progress")
+	}
+
+	// The summary is quoted, as it may contain spaces.
+	var summary string
+	if i := strings.Index(phase, summaryKey); i >= 0 {
+		summary = phase[i+len(summaryKey):]
+		if j := strings.Index(summary, "\""); j >= 0 {
+			summary = summary[:j]
+		}
+	}
+
+	return progress, summary, nil
+}
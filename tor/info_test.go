@@ -0,0 +1,91 @@
+package tor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestBootstrapInfo ensures that the bootstrap information of the Tor server is
+// parsed from its GETINFO replies, and that missing or malformed values are
+// rejected.
+func TestBootstrapInfo(t *testing.T) {
+	t.Parallel()
+
+	c, proxy := newTestController(t)
+	defer proxy.close()
+
+	const cmd = "GETINFO version net/listeners/socks net/listeners/dns " +
+		"status/bootstrap-phase"
+
+	go func() {
+		proxy.expect(t, cmd,
+			"250-version=0.4.5.7",
+			"250-net/listeners/socks=\"127.0.0.1:9050\" "+
+				"\"[::1]:9050\"",
+			"250-net/listeners/dns=\"127.0.0.1:5353\"",
+			"250-status/bootstrap-phase=NOTICE BOOTSTRAP "+
+				"PROGRESS=100 TAG=done SUMMARY=\"Done\"",
+			"250 OK",
+		)
+		proxy.expect(t, cmd,
+			"250-version=0.4.5.7",
+			"250-net/listeners/socks=\"unix:/run/tor/socks\" "+
+				"\"127.0.0.1:9150\"",
+			"250-net/listeners/dns=",
+			"250-status/bootstrap-phase=NOTICE BOOTSTRAP "+
+				"PROGRESS=45 TAG=requesting_descriptors "+
+				"SUMMARY=\"Asking for relay descriptors\"",
+			"250 OK",
+		)
+		proxy.expect(t, cmd,
+			"250-version=0.4.5.7",
+			"250-net/listeners/socks=",
+			"250-net/listeners/dns=",
+			"250-status/bootstrap-phase=NOTICE BOOTSTRAP "+
+				"PROGRESS=100 TAG=done SUMMARY=\"Done\"",
+			"250 OK",
+		)
+		proxy.expect(t, cmd,
+			"250-version=0.4.5.7",
+			"250-net/listeners/socks=\"127.0.0.1:9050\"",
+			"250-net/listeners/dns=",
+			"250-status/bootstrap-phase=NOTICE BOOTSTRAP",
+			"250 OK",
+		)
+		proxy.expect(t, cmd, "552 Unrecognized key")
+	}()
+
+	info, err := c.BootstrapInfo()
+	require.NoError(t, err)
+	require.Equal(t, &BootstrapInfo{
+		Version:           "0.4.5.7",
+		SOCKSPort:         9050,
+		DNSPort:           5353,
+		BootstrapProgress: 100,
+		BootstrapSummary:  "Done",
+	}, info)
+
+	// Unix socket listeners are skipped, and the DNS port is optional.
+	info, err = c.BootstrapInfo()
+	require.NoError(t, err)
+	require.Equal(t, &BootstrapInfo{
+		Version:           "0.4.5.7",
+		SOCKSPort:         9150,
+		BootstrapProgress: 45,
+		BootstrapSummary:  "Asking for relay descriptors",
+	}, info)
+
+	// Without a SOCKS port, connections can't be routed through the
+	// server.
+	_, err = c.BootstrapInfo()
+	require.Error(t, err)
+
+	// A bootstrap phase without its progress can't be interpreted.
+	_, err = c.BootstrapInfo()
+	require.Error(t, err)
+
+	// An error reply from the server should be surfaced to the caller.
+	_, err = c.BootstrapInfo()
+	require.Error(t, err)
+}